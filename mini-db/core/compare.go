@@ -0,0 +1,96 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Compare orders two non-NULL values of compatible types, returning -1, 0
+// or +1. Numbers compare across INT, FLOAT and DECIMAL; DATE and TIMESTAMP
+// compare with each other; other types only with themselves.
+func Compare(a, b any) (int, error) {
+	switch x := a.(type) {
+	case int64:
+		switch y := b.(type) {
+		case int64:
+			return cmpOrdered(x, y), nil
+		case float64:
+			return cmpOrdered(float64(x), y), nil
+		case Decimal:
+			return NewDecimal(x, 0).Cmp(y), nil
+		}
+
+	case float64:
+		switch y := b.(type) {
+		case int64:
+			return cmpOrdered(x, float64(y)), nil
+		case float64:
+			return cmpOrdered(x, y), nil
+		case Decimal:
+			return cmpOrdered(x, y.Float64()), nil
+		}
+
+	case Decimal:
+		switch y := b.(type) {
+		case int64:
+			return x.Cmp(NewDecimal(y, 0)), nil
+		case float64:
+			return cmpOrdered(x.Float64(), y), nil
+		case Decimal:
+			return x.Cmp(y), nil
+		}
+
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), nil
+		}
+
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0, nil
+			case !x:
+				return -1, nil
+			default:
+				return 1, nil
+			}
+		}
+
+	case Date:
+		switch y := b.(type) {
+		case Date:
+			return cmpOrdered(x, y), nil
+		case Timestamp:
+			return cmpOrdered(TimestampOf(x.Time()), y), nil
+		}
+
+	case Timestamp:
+		switch y := b.(type) {
+		case Timestamp:
+			return cmpOrdered(x, y), nil
+		case Date:
+			return cmpOrdered(x, TimestampOf(y.Time())), nil
+		}
+	}
+
+	return 0, fmt.Errorf("cannot compare %s with %s", TypeName(a), TypeName(b))
+}
+
+// Equal reports whether two non-NULL values are equal. Values that cannot
+// be compared are simply not equal.
+func Equal(a, b any) bool {
+	c, err := Compare(a, b)
+	return err == nil && c == 0
+}
+
+func cmpOrdered[T int64 | float64 | Date | Timestamp](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	dateLayout      = "2006-01-02"
+	timestampLayout = "2006-01-02 15:04:05.999999"
+)
+
+// Date is a calendar day, stored as days since 1970-01-01.
+type Date int64
+
+// Timestamp is a point in time in UTC, stored as microseconds since
+// 1970-01-01 00:00:00.
+type Timestamp int64
+
+func DateOf(t time.Time) Date {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return Date(midnight.Unix() / 86400)
+}
+
+func TimestampOf(t time.Time) Timestamp {
+	return Timestamp(t.UTC().UnixMicro())
+}
+
+func (d Date) Time() time.Time {
+	return time.Unix(int64(d)*86400, 0).UTC()
+}
+
+func (d Date) String() string {
+	return d.Time().Format(dateLayout)
+}
+
+func (ts Timestamp) Time() time.Time {
+	return time.UnixMicro(int64(ts)).UTC()
+}
+
+func (ts Timestamp) String() string {
+	return ts.Time().Format(timestampLayout)
+}
+
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(dateLayout, strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid DATE value: '%s'", s)
+	}
+	return DateOf(t), nil
+}
+
+// ParseTimestamp accepts '2006-01-02 15:04:05[.ffffff]', RFC 3339 and a
+// bare date, which means midnight.
+func ParseTimestamp(s string) (Timestamp, error) {
+	str := strings.TrimSpace(s)
+
+	for _, layout := range []string{timestampLayout, time.RFC3339Nano, "2006-01-02T15:04:05", dateLayout} {
+		if t, err := time.Parse(layout, str); err == nil {
+			return TimestampOf(t), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid TIMESTAMP value: '%s'", s)
+}
@@ -0,0 +1,187 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// MaxDecimalPrecision is the most digits a DECIMAL can hold; the unscaled
+// value has to fit in an int64.
+const MaxDecimalPrecision = 18
+
+var errDecimalRange = errors.New("decimal value out of range")
+
+// Decimal is an exact fixed-point number: Unscaled / 10^Scale.
+type Decimal struct {
+	Unscaled int64
+	Scale    int
+}
+
+func NewDecimal(unscaled int64, scale int) Decimal {
+	return Decimal{Unscaled: unscaled, Scale: scale}
+}
+
+// ParseDecimal reads a plain decimal number such as "-12.50".
+func ParseDecimal(s string) (Decimal, error) {
+	str := strings.TrimSpace(s)
+	if str == "" {
+		return Decimal{}, fmt.Errorf("invalid DECIMAL value: '%s'", s)
+	}
+
+	intPart, fracPart, _ := strings.Cut(str, ".")
+	digits := intPart + fracPart
+	if digits == "" || digits == "-" || digits == "+" || strings.ContainsAny(fracPart, "+-") {
+		return Decimal{}, fmt.Errorf("invalid DECIMAL value: '%s'", s)
+	}
+
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return Decimal{}, errDecimalRange
+		}
+		return Decimal{}, fmt.Errorf("invalid DECIMAL value: '%s'", s)
+	}
+
+	return Decimal{Unscaled: n, Scale: len(fracPart)}, nil
+}
+
+func (d Decimal) String() string {
+	if d.Scale <= 0 {
+		return strconv.FormatInt(d.Unscaled, 10)
+	}
+
+	neg := d.Unscaled < 0
+	abs := strconv.FormatUint(absInt64(d.Unscaled), 10)
+	if len(abs) <= d.Scale {
+		abs = strings.Repeat("0", d.Scale-len(abs)+1) + abs
+	}
+
+	s := abs[:len(abs)-d.Scale] + "." + abs[len(abs)-d.Scale:]
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
+
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// Rescale returns d with exactly scale fractional digits, rounding half
+// away from zero when digits are dropped.
+func (d Decimal) Rescale(scale int) (Decimal, error) {
+	r := new(big.Int).Set(d.big())
+
+	switch {
+	case scale > d.Scale:
+		r.Mul(r, pow10(scale-d.Scale))
+	case scale < d.Scale:
+		div := pow10(d.Scale - scale)
+		q, m := new(big.Int).QuoRem(r, div, new(big.Int))
+		if m.Abs(m).Mul(m, big.NewInt(2)).Cmp(div) >= 0 {
+			if r.Sign() < 0 {
+				q.Sub(q, big.NewInt(1))
+			} else {
+				q.Add(q, big.NewInt(1))
+			}
+		}
+		r = q
+	}
+
+	if !r.IsInt64() {
+		return Decimal{}, errDecimalRange
+	}
+	return Decimal{Unscaled: r.Int64(), Scale: scale}, nil
+}
+
+// Digits is the number of significant digits left of the decimal point
+// plus the fractional digits, as DECIMAL(p, s) precision counts them.
+func (d Decimal) Digits() int {
+	return len(strconv.FormatUint(absInt64(d.Unscaled), 10))
+}
+
+// Cmp compares d and o exactly, whatever their scales.
+func (d Decimal) Cmp(o Decimal) int {
+	a, b := d.big(), o.big()
+	switch {
+	case d.Scale > o.Scale:
+		b.Mul(b, pow10(d.Scale-o.Scale))
+	case o.Scale > d.Scale:
+		a.Mul(a, pow10(o.Scale-d.Scale))
+	}
+	return a.Cmp(b)
+}
+
+// Normalize drops trailing fractional zeros, so equal values have equal
+// representations: 1.50 and 1.5 both become 1.5.
+func (d Decimal) Normalize() Decimal {
+	for d.Scale > 0 && d.Unscaled%10 == 0 {
+		d.Unscaled /= 10
+		d.Scale--
+	}
+	return d
+}
+
+// Add returns d + o exactly, at the larger of the two scales.
+func (d Decimal) Add(o Decimal) (Decimal, error) {
+	scale := max(d.Scale, o.Scale)
+	a, b := d.big(), o.big()
+	a.Mul(a, pow10(scale-d.Scale))
+	b.Mul(b, pow10(scale-o.Scale))
+
+	sum := a.Add(a, b)
+	if !sum.IsInt64() {
+		return Decimal{}, errDecimalRange
+	}
+	return Decimal{Unscaled: sum.Int64(), Scale: scale}, nil
+}
+
+// QuoInt returns d / n with the given scale, rounding half away from zero.
+func (d Decimal) QuoInt(n int64, scale int) (Decimal, error) {
+	if n == 0 {
+		return Decimal{}, errors.New("division by zero")
+	}
+
+	// Divide with extra digits to spare and let Rescale do the rounding.
+	work := max(scale+2, d.Scale)
+	r := d.big()
+	r.Mul(r, pow10(work-d.Scale))
+	r.Quo(r, big.NewInt(n))
+
+	if !r.IsInt64() {
+		return Decimal{}, errDecimalRange
+	}
+	return Decimal{Unscaled: r.Int64(), Scale: work}.Rescale(scale)
+}
+
+func (d Decimal) big() *big.Int {
+	return big.NewInt(d.Unscaled)
+}
+
+// DecimalFromFloat converts f to a decimal with the given scale.
+func DecimalFromFloat(f float64, scale int) (Decimal, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Decimal{}, errDecimalRange
+	}
+	d, err := ParseDecimal(strconv.FormatFloat(f, 'f', -1, 64))
+	if err != nil {
+		return Decimal{}, err
+	}
+	return d.Rescale(scale)
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func absInt64(n int64) uint64 {
+	if n < 0 {
+		return uint64(-(n + 1)) + 1
+	}
+	return uint64(n)
+}
@@ -1,21 +1,202 @@
 package core
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
 
 type DataType string
 
 const (
-	IntType  DataType = "INT"
-	TextType DataType = "TEXT"
+	IntType       DataType = "INT"
+	TextType      DataType = "TEXT"
+	BoolType      DataType = "BOOLEAN"
+	FloatType     DataType = "FLOAT"
+	DecimalType   DataType = "DECIMAL"
+	DateType      DataType = "DATE"
+	TimestampType DataType = "TIMESTAMP"
 )
 
 func ParseDataType(s string) (DataType, error) {
-	switch s {
-	case "INT":
+	switch strings.ToUpper(s) {
+	case "INT", "INTEGER":
 		return IntType, nil
 	case "TEXT":
 		return TextType, nil
+	case "BOOLEAN", "BOOL":
+		return BoolType, nil
+	case "FLOAT", "REAL":
+		return FloatType, nil
+	case "DECIMAL", "NUMERIC":
+		return DecimalType, nil
+	case "DATE":
+		return DateType, nil
+	case "TIMESTAMP":
+		return TimestampType, nil
 	default:
 		return "", fmt.Errorf("unknown data type: %s", s)
 	}
 }
+
+// Coerce converts v to the Go representation of t:
+//
+//	INT       int64
+//	TEXT      string
+//	BOOLEAN   bool
+//	FLOAT     float64
+//	DECIMAL   Decimal
+//	DATE      Date
+//	TIMESTAMP Timestamp
+//
+// Numbers convert between the numeric types as long as no integer is
+// given a fractional part, and a quoted literal may spell a value of any
+// non-TEXT type ('42', 'true', '2024-01-31'). Anything else that does not
+// match is an error. NULL (nil) is a valid value of every type.
+func Coerce(v any, t DataType) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	switch t {
+	case IntType:
+		switch x := v.(type) {
+		case int64:
+			return x, nil
+		case int:
+			return int64(x), nil
+		case float64:
+			if x == math.Trunc(x) && math.Abs(x) < math.MaxInt64 {
+				return int64(x), nil
+			}
+		case Decimal:
+			if d, err := x.Rescale(0); err == nil && d.Cmp(x) == 0 {
+				return d.Unscaled, nil
+			}
+		case string:
+			n, err := strconv.ParseInt(strings.TrimSpace(x), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid INT value: '%s'", x)
+			}
+			return n, nil
+		}
+
+	case TextType:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+
+	case BoolType:
+		switch x := v.(type) {
+		case bool:
+			return x, nil
+		case string:
+			switch strings.ToLower(strings.TrimSpace(x)) {
+			case "true", "t":
+				return true, nil
+			case "false", "f":
+				return false, nil
+			}
+			return nil, fmt.Errorf("invalid BOOLEAN value: '%s'", x)
+		}
+
+	case FloatType:
+		switch x := v.(type) {
+		case float64:
+			return x, nil
+		case int64:
+			return float64(x), nil
+		case int:
+			return float64(x), nil
+		case Decimal:
+			return x.Float64(), nil
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid FLOAT value: '%s'", x)
+			}
+			return f, nil
+		}
+
+	case DecimalType:
+		switch x := v.(type) {
+		case Decimal:
+			return x, nil
+		case int64:
+			return NewDecimal(x, 0), nil
+		case int:
+			return NewDecimal(int64(x), 0), nil
+		case float64:
+			return DecimalFromFloat(x, 6)
+		case string:
+			return ParseDecimal(x)
+		}
+
+	case DateType:
+		switch x := v.(type) {
+		case Date:
+			return x, nil
+		case Timestamp:
+			return DateOf(x.Time()), nil
+		case string:
+			return ParseDate(x)
+		}
+
+	case TimestampType:
+		switch x := v.(type) {
+		case Timestamp:
+			return x, nil
+		case Date:
+			return TimestampOf(x.Time()), nil
+		case string:
+			return ParseTimestamp(x)
+		}
+	}
+
+	return nil, fmt.Errorf("cannot use %s value %v as %s", TypeName(v), v, t)
+}
+
+// TypeName names the SQL type of a Go value, for error messages.
+func TypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "NULL"
+	case int64, int:
+		return string(IntType)
+	case string:
+		return string(TextType)
+	case bool:
+		return string(BoolType)
+	case float64:
+		return string(FloatType)
+	case Decimal:
+		return string(DecimalType)
+	case Date:
+		return string(DateType)
+	case Timestamp:
+		return string(TimestampType)
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// IsNumeric reports whether values of t compare with the other numeric
+// types.
+func IsNumeric(t DataType) bool {
+	return t == IntType || t == FloatType || t == DecimalType
+}
+
+// Comparable reports whether values of a and b can be compared with each
+// other, e.g. in a join condition.
+func Comparable(a, b DataType) bool {
+	switch {
+	case a == b:
+		return true
+	case IsNumeric(a) && IsNumeric(b):
+		return true
+	case (a == DateType || a == TimestampType) && (b == DateType || b == TimestampType):
+		return true
+	}
+	return false
+}
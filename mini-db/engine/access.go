@@ -0,0 +1,306 @@
+package engine
+
+import (
+	"math"
+
+	"fastabiz-mini-rdbms/mini-db/core"
+	"fastabiz-mini-rdbms/mini-db/index"
+	"fastabiz-mini-rdbms/mini-db/storage"
+)
+
+// accessPath reads a table through one of its indexes: by looking up keys,
+// or, when scan is set, by walking rng in index order.
+type accessPath struct {
+	index tableIndex
+	keys  []index.Key
+
+	scan  bool
+	rng   index.Range
+	desc  bool
+	conds []Expr // the conditions rng comes from
+}
+
+// chooseAccess picks how a single-table statement reads its rows. Looking
+// up the few keys where allows is best; failing that, an index whose
+// leading columns where bounds is scanned over that range. The scan also
+// yields the rows in index order, so when that is the order asked for
+// sorted is set and no sort is needed; with a LIMIT that makes even a
+// scan of the whole index worthwhile, as it stops early. ok is false when
+// the table has to be scanned.
+func chooseAccess(table *storage.Table, where Expr, order []OrderTerm, limited bool) (best accessPath, sorted, ok bool) {
+	if ix, keys, ok := chooseIndex(table, where); ok {
+		return accessPath{index: ix, keys: keys}, false, true
+	}
+
+	bestScore := 0
+	for _, ix := range tableIndexes(table) {
+		path, prefix := indexRange(table, ix, where)
+		desc, sorts := indexOrder(ix, prefix, order)
+
+		// More conditions narrow the scan further; avoiding the sort
+		// decides between equally narrow scans.
+		score := 2 * len(path.conds)
+		if sorts && (score > 0 || limited) {
+			score++
+		} else {
+			sorts = false
+		}
+
+		if score > bestScore {
+			path.desc = desc
+			best, sorted, bestScore = path, sorts, score
+		}
+	}
+	return best, sorted, bestScore > 0
+}
+
+// indexRange works out the range of an index that rows satisfying where
+// fall in. Conjuncts `col = v` on its leading columns fix a prefix of the
+// key, and then <, <=, >, >= or BETWEEN may bound the next column; prefix
+// is the number of fixed columns. Without any such condition the range is
+// the whole index.
+func indexRange(table *storage.Table, ix tableIndex, where Expr) (path accessPath, prefix int) {
+	terms := conjuncts(where)
+	path = accessPath{index: ix, scan: true}
+
+	var key index.Key
+	for _, name := range ix.columns {
+		cond, val, ok := equality(table.ColumnMap[name], terms)
+		if !ok {
+			break
+		}
+		key = append(key, val)
+		path.conds = append(path.conds, cond)
+	}
+	prefix = len(key)
+	path.rng = index.Range{Low: key, High: key}
+	if prefix == len(ix.columns) {
+		return path, prefix
+	}
+
+	col := table.ColumnMap[ix.columns[prefix]]
+	var hasLow, hasHigh bool
+	for _, term := range terms {
+		low, high, ok := columnBounds(col, term)
+		if !ok {
+			continue
+		}
+		used := false
+		if low != nil && !hasLow {
+			path.rng.Low = append(key[:prefix:prefix], low.val)
+			path.rng.LowExclusive = low.exclusive
+			hasLow, used = true, true
+		}
+		if high != nil && !hasHigh {
+			path.rng.High = append(key[:prefix:prefix], high.val)
+			path.rng.HighExclusive = high.exclusive
+			hasHigh, used = true, true
+		}
+		if used {
+			path.conds = append(path.conds, term)
+		}
+	}
+
+	// NULLs sort first in the index but satisfy no bound.
+	if hasHigh && !hasLow {
+		path.rng.Low = append(key[:prefix:prefix], nil)
+		path.rng.LowExclusive = true
+	}
+	return path, prefix
+}
+
+// indexOrder reports whether an index yields rows in the order terms ask
+// for, once its first prefix columns are fixed: the terms have to name its
+// next columns, all in one direction, with NULLs where the index keeps them
+// (first, or last when read backwards).
+func indexOrder(ix tableIndex, prefix int, terms []OrderTerm) (desc, ok bool) {
+	if len(terms) == 0 || prefix+len(terms) > len(ix.columns) {
+		return false, false
+	}
+	desc = terms[0].Desc
+	for i, t := range terms {
+		if t.Agg != nil || t.Column != ix.columns[prefix+i] || t.Desc != desc || t.nullsFirst() == desc {
+			return false, false
+		}
+	}
+	return desc, true
+}
+
+// conjuncts splits an expression into the terms ANDed together in it.
+func conjuncts(expr Expr) []Expr {
+	if b, ok := expr.(*BinaryExpr); ok && b.Op == AND {
+		return append(conjuncts(b.Left), conjuncts(b.Right)...)
+	}
+	if expr == nil {
+		return nil
+	}
+	return []Expr{expr}
+}
+
+// equality finds a term `col = v` and returns it with v as a value of
+// col's type.
+func equality(col storage.Column, terms []Expr) (Expr, any, bool) {
+	for _, term := range terms {
+		b, ok := term.(*BinaryExpr)
+		if !ok || b.Op != EQ {
+			continue
+		}
+		other := b.Right
+		if isColumn(col, b.Right) {
+			other = b.Left
+		} else if !isColumn(col, b.Left) {
+			continue
+		}
+		if val, ok := boundValue(col, other); ok {
+			return term, val, true
+		}
+	}
+	return nil, nil, false
+}
+
+type bound struct {
+	val       any
+	exclusive bool
+}
+
+// columnBounds reads the lower and upper bounds a term puts on col.
+func columnBounds(col storage.Column, term Expr) (low, high *bound, ok bool) {
+	switch x := term.(type) {
+	case *BinaryExpr:
+		op, other := x.Op, x.Right
+		if isColumn(col, x.Right) {
+			other = x.Left
+			switch op { // 5 < col is col > 5
+			case LT:
+				op = GT
+			case LTE:
+				op = GTE
+			case GT:
+				op = LT
+			case GTE:
+				op = LTE
+			}
+		} else if !isColumn(col, x.Left) {
+			return nil, nil, false
+		}
+
+		switch op {
+		case GT, GTE:
+			low, ok := rangeBound(col, other, true, op == GT)
+			return low, nil, ok
+		case LT, LTE:
+			high, ok := rangeBound(col, other, false, op == LT)
+			return nil, high, ok
+		}
+
+	case *BetweenExpr:
+		if x.Not || !isColumn(col, x.Expr) {
+			return nil, nil, false
+		}
+		lo, lok := rangeBound(col, x.Low, true, false)
+		hi, hok := rangeBound(col, x.High, false, false)
+		if lok && hok {
+			return lo, hi, true
+		}
+	}
+	return nil, nil, false
+}
+
+// boundValue turns a constant into a value of col's type. NULL, and
+// values the column cannot hold exactly, are left to the filter.
+func boundValue(col storage.Column, expr Expr) (any, bool) {
+	lit, ok := expr.(*Literal)
+	if !ok || lit.Value == nil {
+		return nil, false
+	}
+	return keyValue(col, lit.Value)
+}
+
+// rangeBound turns a constant bounding col from below (lower) or above
+// into a bound on its keys. A number the column cannot hold exactly is
+// rounded to a neighbouring key, and the bound made to take in the same
+// keys: id > 1.5 on an INT column becomes id >= 2, and id <= 2.5 id <= 2.
+func rangeBound(col storage.Column, expr Expr, lower, exclusive bool) (*bound, bool) {
+	lit, ok := expr.(*Literal)
+	if !ok || lit.Value == nil {
+		return nil, false
+	}
+	key, err := col.Coerce(roundForType(col.Type, lit.Value))
+	if err != nil {
+		return nil, false
+	}
+	c, err := core.Compare(key, lit.Value)
+	switch {
+	case err != nil:
+		return nil, false
+	case c == 0:
+		return &bound{key, exclusive}, true
+	case lower:
+		return &bound{key, c < 0}, true
+	default:
+		return &bound{key, c > 0}, true
+	}
+}
+
+// roundForType rounds a FLOAT or DECIMAL value compared with an INT column
+// to the nearest integer. DECIMAL and FLOAT columns round values on their
+// own as they coerce them.
+func roundForType(t core.DataType, val any) any {
+	if t != core.IntType {
+		return val
+	}
+	switch x := val.(type) {
+	case float64:
+		return math.Round(x)
+	case core.Decimal:
+		if d, err := x.Rescale(0); err == nil {
+			return d
+		}
+	}
+	return val
+}
+
+// open starts reading the path.
+func (p accessPath) open() *pathIterator {
+	it := &pathIterator{path: p}
+	if p.scan {
+		it.scan = p.index.index.Scan(p.rng, p.desc)
+	} else {
+		it.seen = make(map[int]bool, len(p.keys))
+	}
+	return it
+}
+
+// pathIterator yields the row IDs an access path reaches, each once.
+type pathIterator struct {
+	path accessPath
+	scan index.Iterator
+
+	pos     int
+	pending []int // row IDs found for the current key
+	seen    map[int]bool
+}
+
+func (it *pathIterator) next() (int, bool) {
+	if it.scan != nil {
+		_, rowID, ok := it.scan.Next()
+		return rowID, ok
+	}
+
+	for {
+		for len(it.pending) > 0 {
+			rowID := it.pending[0]
+			it.pending = it.pending[1:]
+			if !it.seen[rowID] {
+				it.seen[rowID] = true
+				return rowID, true
+			}
+		}
+
+		if it.pos == len(it.path.keys) {
+			return 0, false
+		}
+		it.pending = it.path.index.index.Lookup(it.path.keys[it.pos])
+		it.pos++
+	}
+}
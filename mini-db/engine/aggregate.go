@@ -0,0 +1,359 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"fastabiz-mini-rdbms/mini-db/core"
+	"fastabiz-mini-rdbms/mini-db/storage"
+)
+
+type AggFunc string
+
+const (
+	AggCount AggFunc = "COUNT"
+	AggSum   AggFunc = "SUM"
+	AggAvg   AggFunc = "AVG"
+	AggMin   AggFunc = "MIN"
+	AggMax   AggFunc = "MAX"
+)
+
+// Aggregate is one aggregate call such as COUNT(*) or SUM(price). Column is
+// empty for COUNT(*).
+type Aggregate struct {
+	Func     AggFunc
+	Column   string
+	Distinct bool
+}
+
+// String renders the call as written, e.g. COUNT(DISTINCT city); it is
+// also the column name the result is stored under.
+func (a Aggregate) String() string {
+	arg := a.Column
+	switch {
+	case arg == "":
+		arg = "*"
+	case a.Distinct:
+		arg = "DISTINCT " + arg
+	}
+	return fmt.Sprintf("%s(%s)", a.Func, arg)
+}
+
+// resultType is the type of the aggregate's value, given the type of the
+// column it reads.
+func (a Aggregate) resultType(colType core.DataType) core.DataType {
+	switch a.Func {
+	case AggCount:
+		return core.IntType
+	case AggAvg:
+		if colType == core.DecimalType {
+			return core.DecimalType
+		}
+		return core.FloatType
+	}
+	return colType
+}
+
+func parseAggFunc(name string) (AggFunc, bool) {
+	switch f := AggFunc(strings.ToUpper(name)); f {
+	case AggCount, AggSum, AggAvg, AggMin, AggMax:
+		return f, true
+	}
+	return "", false
+}
+
+// AggregateExpr refers to an aggregate inside HAVING or ORDER BY. It reads
+// the value computed for the current group.
+type AggregateExpr struct {
+	Aggregate
+}
+
+func (a *AggregateExpr) Eval(row storage.Row) (any, error) {
+	val, ok := row[a.String()]
+	if !ok {
+		return nil, fmt.Errorf("aggregate %s used outside of an aggregate query", a)
+	}
+	return val, nil
+}
+
+// checkAggregate validates an aggregate call against the column types it
+// reads: SUM and AVG need numbers, MIN and MAX anything orderable.
+func checkAggregate(agg Aggregate, colType func(string) (core.DataType, bool)) error {
+	if agg.Column == "" {
+		if agg.Func != AggCount {
+			return fmt.Errorf("%s(*) is not allowed, only COUNT(*)", agg.Func)
+		}
+		return nil
+	}
+
+	t, ok := colType(agg.Column)
+	if !ok {
+		return fmt.Errorf("column %s does not exist", agg.Column)
+	}
+	if (agg.Func == AggSum || agg.Func == AggAvg) && !core.IsNumeric(t) {
+		return fmt.Errorf("%s needs a numeric column, %s is %s", agg.Func, agg.Column, t)
+	}
+	return nil
+}
+
+// aggregateOp groups its input by the groupBy keys and computes aggs for
+// every group, returning one row per group holding the group columns and
+// each aggregate under its String() name. Groups come out in the order
+// they were first seen. Without GROUP BY all rows form a single group,
+// which exists even when there are no rows, so COUNT(*) can report 0.
+//
+// Only the groups are kept in memory, not the rows read. Rows are plain
+// column->value maps, so the input may come from a single table or from a
+// join.
+type aggregateOp struct {
+	input   operator
+	groupBy []string
+	aggs    []Aggregate
+	out     []storage.Row
+}
+
+func (o *aggregateOp) Open() error {
+	if err := o.input.Open(); err != nil {
+		return err
+	}
+
+	type group struct {
+		key  storage.Row
+		accs []*accumulator
+	}
+
+	groups := make(map[string]*group)
+	var order []*group
+
+	newGroup := func(row storage.Row) *group {
+		g := &group{key: make(storage.Row, len(o.groupBy)), accs: make([]*accumulator, len(o.aggs))}
+		for _, col := range o.groupBy {
+			g.key[col] = row[col]
+		}
+		for i, agg := range o.aggs {
+			g.accs[i] = newAccumulator(agg)
+		}
+		order = append(order, g)
+		return g
+	}
+
+	if len(o.groupBy) == 0 {
+		groups[""] = newGroup(nil)
+	}
+
+	for {
+		row, ok, err := o.input.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+
+		key := groupKey(row, o.groupBy)
+		g, ok := groups[key]
+		if !ok {
+			g = newGroup(row)
+			groups[key] = g
+		}
+
+		for _, acc := range g.accs {
+			if err := acc.add(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	o.out = make([]storage.Row, 0, len(order))
+	for _, g := range order {
+		row := make(storage.Row, len(o.groupBy)+len(o.aggs))
+		for col, val := range g.key {
+			row[col] = val
+		}
+		for i, agg := range o.aggs {
+			val, err := g.accs[i].result()
+			if err != nil {
+				return err
+			}
+			row[agg.String()] = val
+		}
+		o.out = append(o.out, row)
+	}
+	return nil
+}
+
+func (o *aggregateOp) Next() (storage.Row, bool, error) {
+	if len(o.out) == 0 {
+		return nil, false, nil
+	}
+	row := o.out[0]
+	o.out = o.out[1:]
+	return row, true, nil
+}
+
+func (o *aggregateOp) Close() error {
+	o.out = nil
+	return o.input.Close()
+}
+
+// estimate is exact without GROUP BY. With it, there are no statistics on
+// how many distinct keys the input holds, so it guesses a group for every
+// ten input rows.
+func (o *aggregateOp) estimate() int {
+	if len(o.groupBy) == 0 {
+		return 1
+	}
+	return (o.input.estimate() + 9) / 10
+}
+
+func (o *aggregateOp) describe() string {
+	aggs := make([]string, len(o.aggs))
+	for i, agg := range o.aggs {
+		aggs[i] = agg.String()
+	}
+	s := "Hash Aggregate"
+	if len(aggs) > 0 {
+		s += ": " + strings.Join(aggs, ", ")
+	}
+	if len(o.groupBy) > 0 {
+		s += " group by " + strings.Join(o.groupBy, ", ")
+	}
+	return s
+}
+
+func (o *aggregateOp) inputs() []*operator { return []*operator{&o.input} }
+
+// groupKey encodes the group column values of row as a map key. Each value
+// is tagged with its Go type and quoted, so 'a|b' and ('a', 'b') or 1 and
+// '1' never collide; every NULL lands in the same group. Decimals are
+// normalised first so 1.5 and 1.50 group together.
+func groupKey(row storage.Row, cols []string) string {
+	var b strings.Builder
+	for _, col := range cols {
+		val := row[col]
+		if d, ok := val.(core.Decimal); ok {
+			val = d.Normalize()
+		}
+		fmt.Fprintf(&b, "%T:%s;", val, strconv.Quote(fmt.Sprint(val)))
+	}
+	return b.String()
+}
+
+// accumulator folds one aggregate over the rows of a group. NULLs are
+// skipped by every aggregate except COUNT(*).
+type accumulator struct {
+	agg   Aggregate
+	count int64
+	sum   any
+	best  any
+	seen  map[any]bool // COUNT(DISTINCT ...)
+}
+
+func newAccumulator(agg Aggregate) *accumulator {
+	acc := &accumulator{agg: agg}
+	if agg.Distinct {
+		acc.seen = make(map[any]bool)
+	}
+	return acc
+}
+
+func (a *accumulator) add(row storage.Row) error {
+	if a.agg.Column == "" {
+		a.count++
+		return nil
+	}
+
+	val := row[a.agg.Column]
+	if val == nil {
+		return nil
+	}
+
+	if a.seen != nil {
+		if a.seen[val] {
+			return nil
+		}
+		a.seen[val] = true
+	}
+	a.count++
+
+	switch a.agg.Func {
+	case AggSum, AggAvg:
+		sum, err := addNumbers(a.sum, val)
+		if err != nil {
+			return fmt.Errorf("%s: %w", a.agg, err)
+		}
+		a.sum = sum
+
+	case AggMin, AggMax:
+		if a.best == nil {
+			a.best = val
+			return nil
+		}
+		c, err := core.Compare(val, a.best)
+		if err != nil {
+			return err
+		}
+		if (a.agg.Func == AggMin && c < 0) || (a.agg.Func == AggMax && c > 0) {
+			a.best = val
+		}
+	}
+	return nil
+}
+
+// result is the aggregate's value: COUNT is never NULL, the others are
+// NULL for a group without any non-NULL input.
+func (a *accumulator) result() (any, error) {
+	switch a.agg.Func {
+	case AggCount:
+		return a.count, nil
+	case AggSum:
+		return a.sum, nil
+	case AggMin, AggMax:
+		return a.best, nil
+	}
+
+	// AVG is a DECIMAL for DECIMAL input, with four more digits than the
+	// column, and a FLOAT otherwise.
+	switch sum := a.sum.(type) {
+	case nil:
+		return nil, nil
+	case core.Decimal:
+		return sum.QuoInt(a.count, min(sum.Scale+4, core.MaxDecimalPrecision))
+	case int64:
+		return float64(sum) / float64(a.count), nil
+	case float64:
+		return sum / float64(a.count), nil
+	}
+	return nil, fmt.Errorf("cannot average %s values", core.TypeName(a.sum))
+}
+
+// addNumbers adds a value of a numeric column to a running sum, keeping
+// the column's type; sum is nil before the first value.
+func addNumbers(sum, val any) (any, error) {
+	if sum == nil {
+		return val, nil
+	}
+
+	switch s := sum.(type) {
+	case int64:
+		v, ok := val.(int64)
+		if !ok {
+			break
+		}
+		if (v > 0 && s > math.MaxInt64-v) || (v < 0 && s < math.MinInt64-v) {
+			return nil, fmt.Errorf("integer overflow")
+		}
+		return s + v, nil
+	case float64:
+		if v, ok := val.(float64); ok {
+			return s + v, nil
+		}
+	case core.Decimal:
+		if v, ok := val.(core.Decimal); ok {
+			return s.Add(v)
+		}
+	}
+	return nil, fmt.Errorf("cannot add %s to %s", core.TypeName(val), core.TypeName(sum))
+}
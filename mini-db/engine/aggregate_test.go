@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAggregates(t *testing.T) {
+	e := NewEngine()
+	mustRun(t, e,
+		"CREATE TABLE sales (id INT PRIMARY KEY, city TEXT, item TEXT, qty INT, price DECIMAL(6,2))",
+		"INSERT INTO sales (id, city, item, qty, price) VALUES (1, 'oslo', 'pen', 2, 1.50)",
+		"INSERT INTO sales (id, city, item, qty, price) VALUES (2, 'oslo', 'ink', 1, 4.00)",
+		"INSERT INTO sales (id, city, item, qty, price) VALUES (3, 'oslo', 'pen', 5, 1.25)",
+		"INSERT INTO sales (id, city, item, qty, price) VALUES (4, 'rome', 'pen', NULL, 2.00)",
+		"INSERT INTO sales (id, city, item, qty, price) VALUES (5, 'rome', 'pad', 3, NULL)",
+		"INSERT INTO sales (id, city, item, qty, price) VALUES (6, NULL, 'pad', 4, 3.00)",
+		"CREATE TABLE empty (id INT PRIMARY KEY, n INT)",
+	)
+
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"SELECT COUNT(*), COUNT(qty), COUNT(city), SUM(qty), MIN(price), MAX(price) FROM sales",
+			[]string{"6 5 5 15 1.25 4.00"}},
+		{"SELECT AVG(qty), AVG(price) FROM sales", []string{"3 2.350000"}},
+		{"SELECT COUNT(DISTINCT item), COUNT(DISTINCT city), COUNT(item) FROM sales", []string{"3 2 6"}},
+		{"SELECT COUNT(*), SUM(n), AVG(n), MIN(n) FROM empty", []string{"0 <nil> <nil> <nil>"}},
+
+		{"SELECT city, COUNT(*), SUM(qty) FROM sales GROUP BY city ORDER BY city",
+			[]string{"<nil> 1 4", "oslo 3 8", "rome 2 3"}},
+		{"SELECT city, item, COUNT(*) FROM sales GROUP BY city, item ORDER BY city, item",
+			[]string{"<nil> pad 1", "oslo ink 1", "oslo pen 2", "rome pad 1", "rome pen 1"}},
+		{"SELECT item, COUNT(DISTINCT city) FROM sales GROUP BY item ORDER BY item",
+			[]string{"ink 1", "pad 1", "pen 2"}},
+		{"SELECT city, MAX(qty) FROM sales WHERE item = 'pen' GROUP BY city ORDER BY city",
+			[]string{"oslo 5", "rome <nil>"}},
+		{"SELECT city FROM sales GROUP BY city ORDER BY city", []string{"<nil>", "oslo", "rome"}},
+
+		{"SELECT city, COUNT(*) FROM sales GROUP BY city HAVING COUNT(*) > 1 ORDER BY city",
+			[]string{"oslo 3", "rome 2"}},
+		{"SELECT city, SUM(qty) FROM sales GROUP BY city HAVING SUM(qty) >= 4 AND city IS NOT NULL",
+			[]string{"oslo 8"}},
+		{"SELECT item FROM sales GROUP BY item HAVING MIN(price) < 2 OR COUNT(DISTINCT city) = 0",
+			[]string{"pen"}},
+		{"SELECT city, COUNT(*) FROM sales GROUP BY city ORDER BY COUNT(*) DESC LIMIT 2",
+			[]string{"oslo 3", "rome 2"}},
+	}
+	for _, tt := range tests {
+		if got := query(t, e, tt.sql); !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+
+	for _, sql := range []string{
+		"SELECT city, COUNT(*) FROM sales",
+		"SELECT item, COUNT(*) FROM sales GROUP BY city",
+		"SELECT SUM(city) FROM sales",
+		"SELECT AVG(item) FROM sales",
+		"SELECT SUM(*) FROM sales",
+		"SELECT COUNT(nope) FROM sales",
+		"SELECT city FROM sales GROUP BY nope",
+		"SELECT city FROM sales WHERE COUNT(*) > 1 GROUP BY city",
+		"SELECT id FROM sales HAVING id > 1",
+	} {
+		mustFail(t, e, sql)
+	}
+}
+
+// Result columns are named after their alias, column or aggregate, and no
+// two may share a name.
+func TestOutputNames(t *testing.T) {
+	e := NewEngine()
+	mustRun(t, e,
+		"CREATE TABLE t (id INT PRIMARY KEY, s TEXT)",
+		"CREATE TABLE u (id INT PRIMARY KEY, s TEXT)",
+		"INSERT INTO t (id, s) VALUES (1, 'a')",
+		"INSERT INTO u (id, s) VALUES (1, 'b')",
+	)
+
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"SELECT id AS s, s AS id FROM t", []string{"1 a"}},
+		{"SELECT t.s, u.s FROM t JOIN u ON t.id = u.id", []string{"a b"}},
+		{"SELECT COUNT(*), COUNT(*) AS n FROM t", []string{"1 1"}},
+		{"SELECT id AS x FROM t UNION SELECT id FROM u", []string{"1"}},
+	}
+	for _, tt := range tests {
+		if got := query(t, e, tt.sql); !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+
+	for _, sql := range []string{
+		"SELECT id AS s, s FROM t",
+		"SELECT id, id FROM t",
+		"SELECT id AS y, s AS y FROM t",
+		"SELECT *, id FROM t",
+		"SELECT COUNT(*), COUNT(*) FROM t",
+		"SELECT t.*, t.id FROM t JOIN u ON t.id = u.id",
+		"SELECT DISTINCT s, s FROM t",
+		"SELECT id, s AS id FROM t UNION SELECT id, s FROM u",
+	} {
+		res, err := run(e, sql)
+		if err == nil || !strings.Contains(err.Error(), "specified more than once") {
+			t.Errorf("%s: got %v, %v, want a duplicate column name error", sql, res, err)
+		}
+	}
+}
@@ -0,0 +1,436 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"fastabiz-mini-rdbms/mini-db/core"
+	"fastabiz-mini-rdbms/mini-db/storage"
+)
+
+// AlterTable changes the schema of a table and rewrites its rows to match.
+// The rewritten rows are worked out and checked against the new schema
+// before anything is logged, and logged along with the change, so the
+// statement applies in full or not at all.
+func (e *Engine) AlterTable(cmd AlterTableCommand) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	table, ok := e.Tables[cmd.TableName]
+	if !ok {
+		return errors.New("table does not exist")
+	}
+	if err := e.checkAlter(table, cmd); err != nil {
+		return err
+	}
+
+	altered, err := e.alteredTable(table, &cmd)
+	if err != nil {
+		return err
+	}
+	if err := e.checkAlteredIndexes(table, altered); err != nil {
+		return err
+	}
+
+	changes, err := alterRows(table, altered, cmd)
+	if err != nil {
+		return err
+	}
+	if cmd.Action == AlterColumnType {
+		if err := e.checkReferencedType(table, altered.ColumnMap[cmd.Column], changes); err != nil {
+			return err
+		}
+	}
+
+	// The rows are checked as the altered table: its indexes start out
+	// empty and every row is among the changes.
+	e.Tables[table.Name] = altered
+	err = e.checkChanges(changes)
+	e.Tables[table.Name] = table
+	if err != nil {
+		return err
+	}
+
+	return e.commit(&logRecord{Kind: recAlterTable, Alter: &cmd, Changes: changes})
+}
+
+// checkAlter makes sure the column or table cmd names is there, that a new
+// name is free, and that nothing depends on a column being dropped.
+func (e *Engine) checkAlter(table *storage.Table, cmd AlterTableCommand) error {
+	if cmd.Action == RenameTable {
+		if _, exists := e.Tables[cmd.NewName]; exists {
+			return fmt.Errorf("table %s already exists", cmd.NewName)
+		}
+		return nil
+	}
+
+	_, exists := table.ColumnMap[cmd.Column]
+	switch {
+	case cmd.Action == AddColumn && exists:
+		return fmt.Errorf("column %s already exists in table %s", cmd.Column, table.Name)
+	case cmd.Action != AddColumn && !exists:
+		return fmt.Errorf("column %s does not exist in table %s", cmd.Column, table.Name)
+	}
+
+	switch cmd.Action {
+	case RenameColumn:
+		if _, exists := table.ColumnMap[cmd.NewName]; exists {
+			return fmt.Errorf("column %s already exists in table %s", cmd.NewName, table.Name)
+		}
+
+	case AlterColumnType:
+		if cmd.Def.AutoIncrement {
+			return fmt.Errorf("cannot change column %s to SERIAL", cmd.Column)
+		}
+
+	case DropColumn:
+		return e.checkDropColumn(table, cmd.Column)
+	}
+	return nil
+}
+
+func (e *Engine) checkDropColumn(table *storage.Table, column string) error {
+	if len(table.Columns) == 1 {
+		return fmt.Errorf("cannot drop column %s: it is the only column of table %s", column, table.Name)
+	}
+	if slices.Contains(table.PrimaryKey, column) {
+		return fmt.Errorf("cannot drop column %s: it is part of the primary key of table %s", column, table.Name)
+	}
+	for _, ref := range e.referencing(table.Name) {
+		if slices.Contains(ref.fk.RefColumns, column) {
+			return fmt.Errorf("cannot drop column %s: foreign key %s of table %s depends on it", column, ref.fk.Name, ref.child.Name)
+		}
+	}
+
+	// The column's own check and foreign keys go with it; any other check
+	// must not use it.
+	for _, check := range table.Checks {
+		if check.Column == column {
+			continue
+		}
+		uses, err := checkUses(table, check, column)
+		if err != nil {
+			return err
+		}
+		if uses {
+			return fmt.Errorf("cannot drop column %s: check constraint %s depends on it", column, check.Name)
+		}
+	}
+	return nil
+}
+
+// checkUses reports whether a CHECK constraint of table refers to column.
+func checkUses(table *storage.Table, check storage.CheckConstraint, column string) (bool, error) {
+	expr, err := parseCheckExpr(check)
+	if err != nil {
+		return false, fmt.Errorf("check constraint %s: %w", check.Name, err)
+	}
+	uses := false
+	walkExpr(expr, func(x Expr) error {
+		if ref, ok := x.(*ColumnRef); ok {
+			qual, name := splitColumnRef(ref.Name)
+			uses = uses || (name == column && (qual == "" || qual == table.Name))
+		}
+		return nil
+	})
+	return uses, nil
+}
+
+// alteredTable builds the table cmd leaves behind, through newTable so
+// that the new schema is checked like that of a new table and its CHECK
+// constraints are bound to it afresh. It shares the store of table; its
+// indexes, those from CREATE INDEX included, start out empty. Indexes on
+// a dropped column are dropped with it.
+func (e *Engine) alteredTable(table *storage.Table, cmd *AlterTableCommand) (*storage.Table, error) {
+	def := CreateTableCommand{
+		TableName:   table.Name,
+		Columns:     unmarkPrimary(table.Columns),
+		PrimaryKey:  slices.Clone(table.PrimaryKey),
+		ForeignKeys: slices.Clone(table.ForeignKeys),
+		Checks:      slices.Clone(table.Checks),
+		Engine:      table.Engine,
+	}
+	indexes := make([]CreateIndexCommand, 0, len(table.Indexes))
+	for _, ix := range table.Indexes {
+		if !ix.Constraint {
+			indexes = append(indexes, CreateIndexCommand{IndexName: ix.Name, Columns: slices.Clone(ix.Columns), Unique: ix.Unique})
+		}
+	}
+
+	// rename rewrites a column reference, qualified or not, for RENAME
+	// COLUMN and RENAME TO.
+	rename := func(qual, name string) string {
+		if qual != "" && qual != table.Name {
+			return name
+		}
+		switch {
+		case cmd.Action == RenameTable && qual != "":
+			qual = cmd.NewName
+		case cmd.Action == RenameColumn && name == cmd.Column:
+			name = cmd.NewName
+		}
+		if qual == "" {
+			return name
+		}
+		return qual + "." + name
+	}
+	renameAll := func(names []string) []string {
+		renamed := make([]string, len(names))
+		for i, name := range names {
+			renamed[i] = rename("", name)
+		}
+		return renamed
+	}
+
+	switch cmd.Action {
+	case AddColumn:
+		def.Columns = append(def.Columns, cmd.Def)
+		def.ForeignKeys = append(def.ForeignKeys, cmd.ForeignKeys...)
+		def.Checks = append(def.Checks, cmd.Checks...)
+
+	case DropColumn:
+		def.Columns = slices.DeleteFunc(def.Columns, func(col storage.Column) bool { return col.Name == cmd.Column })
+		def.Checks = slices.DeleteFunc(def.Checks, func(check storage.CheckConstraint) bool { return check.Column == cmd.Column })
+		def.ForeignKeys = slices.DeleteFunc(def.ForeignKeys, func(fk storage.ForeignKey) bool { return slices.Contains(fk.Columns, cmd.Column) })
+		indexes = slices.DeleteFunc(indexes, func(ix CreateIndexCommand) bool { return slices.Contains(ix.Columns, cmd.Column) })
+
+	case RenameColumn, RenameTable:
+		if cmd.Action == RenameTable {
+			def.TableName = cmd.NewName
+		}
+		for i := range def.Columns {
+			def.Columns[i].Name = rename("", def.Columns[i].Name)
+		}
+		def.PrimaryKey = renameAll(def.PrimaryKey)
+		for i := range def.ForeignKeys {
+			fk := &def.ForeignKeys[i]
+			fk.Columns = renameAll(fk.Columns)
+			if fk.RefTable == table.Name {
+				fk.RefTable = def.TableName
+				fk.RefColumns = renameAll(fk.RefColumns)
+			}
+		}
+		for i := range def.Checks {
+			check, err := renameInCheck(def.Checks[i], rename)
+			if err != nil {
+				return nil, err
+			}
+			check.Column = rename("", check.Column)
+			def.Checks[i] = check
+		}
+		for i := range indexes {
+			indexes[i].Columns = renameAll(indexes[i].Columns)
+		}
+
+	case AlterColumnType:
+		i := slices.IndexFunc(def.Columns, func(col storage.Column) bool { return col.Name == cmd.Column })
+		col := &def.Columns[i]
+		col.Type, col.Precision, col.Scale = cmd.Def.Type, cmd.Def.Precision, cmd.Def.Scale
+		if col.Default != nil && col.Default.Value != nil {
+			val, err := convertValue(*col, col.Default.Value)
+			if err != nil {
+				return nil, fmt.Errorf("cannot convert default of column %s to %s: %w", col.Name, col.TypeString(), err)
+			}
+			col.Default = &storage.Default{Value: val}
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown ALTER TABLE action %s", cmd.Action)
+	}
+
+	altered, err := newTable(def)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.resolveForeignKeys(altered); err != nil {
+		return nil, err
+	}
+	altered.Store, altered.File, altered.AutoInc = table.Store, table.File, table.AutoInc
+	for _, ix := range indexes {
+		ix.TableName = altered.Name
+		altered.Indexes = append(altered.Indexes, newSecondaryIndex(ix))
+	}
+	return altered, nil
+}
+
+// renameInCheck rewrites every column a CHECK constraint refers to with
+// fn, which gets the qualifier and the name of each.
+func renameInCheck(check storage.CheckConstraint, fn func(qual, name string) string) (storage.CheckConstraint, error) {
+	expr, err := parseCheckExpr(check)
+	if err != nil {
+		return check, fmt.Errorf("check constraint %s: %w", check.Name, err)
+	}
+	walkExpr(expr, func(x Expr) error {
+		if ref, ok := x.(*ColumnRef); ok {
+			ref.Name = fn(splitColumnRef(ref.Name))
+		}
+		return nil
+	})
+	check.Expr = expr.String()
+	return check, nil
+}
+
+// checkAlteredIndexes makes sure the indexes of the altered table, whose
+// UNIQUE constraint indexes are named after it and its columns, do not
+// clash with those of other tables.
+func (e *Engine) checkAlteredIndexes(table, altered *storage.Table) error {
+	for _, ix := range altered.Indexes {
+		if other, _, exists := e.findIndex(ix.Name); exists && other != table {
+			return fmt.Errorf("index %s already exists", ix.Name)
+		}
+	}
+	return nil
+}
+
+// checkReferencedType makes sure the foreign keys that refer to a column
+// can still do so once its type is col's. The conversion must also leave
+// every value of the column as it was: a rounded DECIMAL or a '01' read
+// as 1 would leave the rows referring to the old value pointing at
+// nothing.
+func (e *Engine) checkReferencedType(table *storage.Table, col storage.Column, changes []rowChange) error {
+	var fk *storage.ForeignKey
+	for _, ref := range e.referencing(table.Name) {
+		if !slices.Contains(ref.fk.RefColumns, col.Name) {
+			continue
+		}
+		fk = ref.fk
+		if ref.child == table {
+			continue // types checked with the table's own keys
+		}
+		for j, name := range ref.fk.RefColumns {
+			child := ref.child.ColumnMap[ref.fk.Columns[j]]
+			if name == col.Name && !hashCompatible(child.Type, col.Type) {
+				return fmt.Errorf("foreign key %s: column %s of type %s cannot reference %s.%s of type %s",
+					ref.fk.Name, child.Name, child.TypeString(), table.Name, col.Name, col.TypeString())
+			}
+		}
+	}
+	if fk == nil {
+		return nil
+	}
+
+	for _, c := range changes {
+		old, _, err := table.Store.Get(c.RowID)
+		if err != nil {
+			return err
+		}
+		before, after := old[col.Name], c.Row[col.Name]
+		if (before != nil || after != nil) && !core.Equal(before, after) {
+			return fmt.Errorf("cannot convert column %s to %s: foreign key %s refers to it and value %v would become %v",
+				col.Name, col.TypeString(), fk.Name, before, after)
+		}
+	}
+	return nil
+}
+
+// alterRows works out the rows of table as cmd rewrites them: with a new
+// column filled from its default (or numbered, for AUTOINCREMENT), without
+// a dropped one, with a renamed one under its new name, or with a column
+// converted to its new type. Renaming the table leaves the rows alone.
+func alterRows(table, altered *storage.Table, cmd AlterTableCommand) ([]rowChange, error) {
+	if cmd.Action == RenameTable {
+		return nil, nil
+	}
+
+	col := altered.ColumnMap[cmd.Column]
+	now := time.Now()
+	autoInc := altered.AutoInc
+
+	var changes []rowChange
+	err := storage.ForEach(table.Store, func(id storage.RowID, row storage.Row) error {
+		updated := make(storage.Row, len(row)+1)
+		for name, val := range row {
+			updated[name] = val
+		}
+
+		switch cmd.Action {
+		case AddColumn:
+			if col.AutoIncrement {
+				updated[col.Name] = int64(autoInc)
+				autoInc++
+				break
+			}
+			val, err := columnDefault(col, now)
+			if err != nil {
+				return err
+			}
+			updated[col.Name] = val
+
+		case DropColumn:
+			delete(updated, cmd.Column)
+
+		case RenameColumn:
+			updated[cmd.NewName] = updated[cmd.Column]
+			delete(updated, cmd.Column)
+
+		case AlterColumnType:
+			val, err := convertValue(col, row[col.Name])
+			if err != nil {
+				return fmt.Errorf("cannot convert column %s to %s: %w", col.Name, col.TypeString(), err)
+			}
+			updated[col.Name] = val
+		}
+
+		changes = append(changes, rowChange{Kind: changeUpdate, Table: table.Name, RowID: id, Row: updated})
+		return nil
+	})
+	return changes, err
+}
+
+// convertValue converts a stored value to col's type. Anything converts to
+// TEXT, as it prints; other types take what Coerce accepts.
+func convertValue(col storage.Column, val any) (any, error) {
+	if col.Type == core.TextType && val != nil {
+		return fmt.Sprint(val), nil
+	}
+	return col.Coerce(val)
+}
+
+// applyAlterTable puts the altered table in place of the old one, makes the
+// foreign keys of other tables follow a rename, and writes the rewritten
+// rows. While recovering the indexes are left empty, as Open rebuilds
+// every index after replay.
+func (e *Engine) applyAlterTable(cmd *AlterTableCommand, changes []rowChange, lsn uint64) error {
+	table, ok := e.Tables[cmd.TableName]
+	if !ok {
+		return fmt.Errorf("table %s does not exist", cmd.TableName)
+	}
+	altered, err := e.alteredTable(table, cmd)
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range e.referencing(table.Name) {
+		if ref.child == table {
+			continue
+		}
+		switch cmd.Action {
+		case RenameTable:
+			ref.fk.RefTable = cmd.NewName
+		case RenameColumn:
+			refColumns := slices.Clone(ref.fk.RefColumns)
+			for j, name := range refColumns {
+				if name == cmd.Column {
+					refColumns[j] = cmd.NewName
+				}
+			}
+			ref.fk.RefColumns = refColumns
+		}
+	}
+	delete(e.Tables, table.Name)
+	e.Tables[altered.Name] = altered
+
+	for _, c := range changes {
+		if err := altered.Store.Update(c.RowID, c.Row, lsn); err != nil {
+			return err
+		}
+		advanceAutoInc(altered, c.Row)
+	}
+
+	if e.recovering {
+		return nil
+	}
+	return rebuildIndexes(altered)
+}
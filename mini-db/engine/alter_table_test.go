@@ -0,0 +1,124 @@
+package engine
+
+import "testing"
+
+func TestAlterTable(t *testing.T) {
+	e := NewEngine()
+	mustRun(t, e,
+		"CREATE TABLE users (id INT PRIMARY KEY, name TEXT, age TEXT CHECK (age <> '0'))",
+		"CREATE TABLE orders (id INT PRIMARY KEY, user_id INT REFERENCES users (id), total INT)",
+		"CREATE INDEX users_name ON users (name)",
+		"INSERT INTO users (id, name, age) VALUES (1, 'ann', '30')",
+		"INSERT INTO users (id, name, age) VALUES (2, 'bob', NULL)",
+		"INSERT INTO orders (id, user_id, total) VALUES (10, 1, 5)",
+	)
+
+	mustRun(t, e,
+		"ALTER TABLE users ADD COLUMN city TEXT DEFAULT 'Nairobi'",
+		"ALTER TABLE users RENAME COLUMN name TO full_name",
+		"ALTER TABLE users ALTER COLUMN age TYPE INT",
+		"ALTER TABLE orders DROP COLUMN total",
+		"ALTER TABLE orders RENAME TO purchases",
+	)
+	checkQuery(t, e, "SELECT id, full_name, age, city FROM users ORDER BY id", "1 ann 30 Nairobi", "2 bob <nil> Nairobi")
+	checkQuery(t, e, "SELECT * FROM purchases", "10 1")
+	checkQuery(t, e, "SELECT id FROM users WHERE full_name = 'bob'", "2")
+	if plan := planOf(explain(t, e, "EXPLAIN SELECT id FROM users WHERE full_name = 'bob'")); plan[2] != "Index Scan on users using users_name (full_name), 1 key(s)" {
+		t.Errorf("the renamed column's index is not used: %q", plan)
+	}
+
+	// The renamed table keeps its foreign key, and new rows follow the
+	// new schema.
+	failsWith(t, e, "INSERT INTO purchases (id, user_id) VALUES (11, 3)", "foreign key constraint")
+	failsWith(t, e, "INSERT INTO users (id, full_name, age) VALUES (3, 'cy', 'old')", "invalid INT value")
+	failsWith(t, e, "INSERT INTO users (id, full_name, age) VALUES (3, 'cy', 0)", "check constraint users_age_check")
+	mustRun(t, e, "INSERT INTO users (id, full_name, age) VALUES (3, 'cy', 40)")
+	checkQuery(t, e, "SELECT city FROM users WHERE id = 3", "Nairobi")
+
+	tests := []struct {
+		sql, msg string
+	}{
+		{"ALTER TABLE nope ADD COLUMN x INT", "table does not exist"},
+		{"ALTER TABLE users ADD COLUMN city TEXT", "column city already exists"},
+		{"ALTER TABLE users ADD COLUMN n INT NOT NULL", "violates NOT NULL"},
+		{"ALTER TABLE users RENAME COLUMN age TO city", "column city already exists"},
+		{"ALTER TABLE users DROP COLUMN nope", "column nope does not exist"},
+		{"ALTER TABLE users DROP COLUMN id", "part of the primary key"},
+		{"ALTER TABLE users RENAME TO purchases", "table purchases already exists"},
+		{"ALTER TABLE users ALTER COLUMN full_name TYPE INT", "cannot convert column full_name to INT"},
+		{"ALTER TABLE users ALTER COLUMN age TYPE SERIAL", "cannot change column age to SERIAL"},
+	}
+	for _, tt := range tests {
+		failsWith(t, e, tt.sql, tt.msg)
+	}
+
+	// A refused ALTER leaves the table as it was.
+	checkQuery(t, e, "SELECT id, full_name, age, city FROM users ORDER BY id",
+		"1 ann 30 Nairobi", "2 bob <nil> Nairobi", "3 cy 40 Nairobi")
+}
+
+// Rewritten rows must keep the primary key unique: '1' and '01' are both
+// 1 as INT.
+func TestAlterTypeKeepsPrimaryKeyUnique(t *testing.T) {
+	dir := t.TempDir()
+	e := openEngine(t, dir)
+	mustRun(t, e,
+		"CREATE TABLE t (id TEXT PRIMARY KEY, v INT)",
+		"INSERT INTO t (id, v) VALUES ('1', 1)",
+		"INSERT INTO t (id, v) VALUES ('01', 2)",
+	)
+	mustFail(t, e, "ALTER TABLE t ALTER COLUMN id TYPE INT")
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	e = openEngine(t, dir)
+	defer e.Close()
+	checkRecovered(t, e, "SELECT id, v FROM t ORDER BY v", []string{"1 1", "01 2"})
+}
+
+// A retyped column's DEFAULT is converted along with its values.
+func TestAlterTypeConvertsDefault(t *testing.T) {
+	dir := t.TempDir()
+	e := openEngine(t, dir)
+	mustRun(t, e,
+		"CREATE TABLE t (id INT PRIMARY KEY, c INT DEFAULT 7, d TEXT DEFAULT 'x')",
+		"ALTER TABLE t ALTER COLUMN c TYPE TEXT",
+		"INSERT INTO t (id) VALUES (1)",
+	)
+	mustFail(t, e, "ALTER TABLE t ALTER COLUMN d TYPE INT")
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	e = openEngine(t, dir)
+	defer e.Close()
+	mustRun(t, e, "INSERT INTO t (id) VALUES (2)")
+	checkRecovered(t, e, "SELECT id, c, d FROM t ORDER BY id", []string{"1 7 x", "2 7 x"})
+	if c := e.Tables["t"].ColumnMap["c"].Default.Value; c != "7" {
+		t.Errorf("default of c is %#v, want \"7\"", c)
+	}
+}
+
+// A key other rows refer to may change type only if none of its values
+// change, or those rows would be left referring to nothing.
+func TestAlterTypeOfReferencedKey(t *testing.T) {
+	e := NewEngine()
+	mustRun(t, e,
+		"CREATE TABLE p (k DECIMAL(6,2) PRIMARY KEY)",
+		"CREATE TABLE c (id INT PRIMARY KEY, pk DECIMAL(6,2) REFERENCES p (k))",
+		"CREATE TABLE s (id DECIMAL(6,2) PRIMARY KEY, parent DECIMAL(6,2) REFERENCES s (id))",
+		"INSERT INTO p (k) VALUES (1.25)",
+		"INSERT INTO c (id, pk) VALUES (1, 1.25)",
+		"INSERT INTO s (id, parent) VALUES (1.25, NULL)",
+		"INSERT INTO s (id, parent) VALUES (2.25, 1.25)",
+	)
+
+	mustFail(t, e, "ALTER TABLE p ALTER COLUMN k TYPE DECIMAL(6,1)")
+	mustFail(t, e, "ALTER TABLE s ALTER COLUMN id TYPE DECIMAL(6,1)")
+	mustRun(t, e, "ALTER TABLE p ALTER COLUMN k TYPE DECIMAL(8,2)")
+
+	if got := query(t, e, "SELECT c.id, p.k FROM c JOIN p ON c.pk = p.k"); len(got) != 1 {
+		t.Errorf("child rows matching their parent: %q, want 1", got)
+	}
+}
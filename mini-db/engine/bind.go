@@ -0,0 +1,226 @@
+package engine
+
+import (
+	"fmt"
+
+	"fastabiz-mini-rdbms/mini-db/core"
+	"fastabiz-mini-rdbms/mini-db/storage"
+)
+
+// bindValues checks column names against the table schema and converts
+// each value to its column's type.
+func bindValues(table *storage.Table, values map[string]any) (storage.Row, error) {
+	row := make(storage.Row, len(values))
+
+	for name, val := range values {
+		col, ok := table.ColumnMap[name]
+		if !ok {
+			return nil, fmt.Errorf("column %s does not exist in table %s", name, table.Name)
+		}
+
+		typed, err := col.Coerce(val)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", name, err)
+		}
+		row[name] = typed
+	}
+
+	return row, nil
+}
+
+// bindWhere binds a WHERE clause, which is evaluated row by row and so
+// cannot use aggregates.
+func bindWhere(sc *scope, where Expr) (Expr, error) {
+	return bindCondition(sc, where, "WHERE")
+}
+
+// bindCondition binds a row-level condition of the named clause, such as
+// WHERE or a join's ON.
+func bindCondition(sc *scope, cond Expr, clause string) (Expr, error) {
+	err := walkExpr(cond, func(e Expr) error {
+		if agg, ok := e.(*AggregateExpr); ok {
+			return fmt.Errorf("aggregate %s is not allowed in %s", agg, clause)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bindExpr(sc, cond)
+}
+
+// bindExpr checks an expression against the tables in scope and returns a
+// copy in which column references are replaced by their row keys and every
+// literal compared with a column has been converted
+// to that column's type, so '2024-01-31' compares as a DATE against a DATE
+// column and PK lookups use keys of the indexed type. Numeric literals are
+// left alone against numeric columns: comparisons across INT, FLOAT and
+// DECIMAL are exact, and rounding 1.5 to an INT would change the meaning.
+func bindExpr(sc *scope, expr Expr) (Expr, error) {
+	switch x := expr.(type) {
+	case nil:
+		return nil, nil
+
+	case *Literal:
+		return x, nil
+
+	case *AggregateExpr:
+		agg, err := bindAggregate(sc, x.Aggregate)
+		if err != nil {
+			return nil, err
+		}
+		return &AggregateExpr{Aggregate: agg}, nil
+
+	case *ColumnRef:
+		key, _, err := sc.resolve(x.Name)
+		if err != nil {
+			return nil, err
+		}
+		return &ColumnRef{Name: key}, nil
+
+	case *BinaryExpr:
+		left, err := bindExpr(sc, x.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := bindExpr(sc, x.Right)
+		if err != nil {
+			return nil, err
+		}
+		if x.Op != AND && x.Op != OR {
+			if left, right, err = bindPair(sc, left, right); err != nil {
+				return nil, err
+			}
+		}
+		return &BinaryExpr{Op: x.Op, Left: left, Right: right}, nil
+
+	case *NotExpr:
+		inner, err := bindExpr(sc, x.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpr{Expr: inner}, nil
+
+	case *IsNullExpr:
+		inner, err := bindExpr(sc, x.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return &IsNullExpr{Expr: inner, Not: x.Not}, nil
+
+	case *InExpr:
+		val, err := bindExpr(sc, x.Expr)
+		if err != nil {
+			return nil, err
+		}
+		list := make([]Expr, len(x.List))
+		for i, item := range x.List {
+			if list[i], err = bindExpr(sc, item); err != nil {
+				return nil, err
+			}
+			if _, list[i], err = bindPair(sc, val, list[i]); err != nil {
+				return nil, err
+			}
+		}
+		return &InExpr{Expr: val, List: list, Not: x.Not}, nil
+
+	case *BetweenExpr:
+		val, err := bindExpr(sc, x.Expr)
+		if err != nil {
+			return nil, err
+		}
+		low, err := bindExpr(sc, x.Low)
+		if err != nil {
+			return nil, err
+		}
+		high, err := bindExpr(sc, x.High)
+		if err != nil {
+			return nil, err
+		}
+		if _, low, err = bindPair(sc, val, low); err != nil {
+			return nil, err
+		}
+		if _, high, err = bindPair(sc, val, high); err != nil {
+			return nil, err
+		}
+		return &BetweenExpr{Expr: val, Low: low, High: high, Not: x.Not}, nil
+
+	case *LikeExpr:
+		val, err := bindExpr(sc, x.Expr)
+		if err != nil {
+			return nil, err
+		}
+		pattern, err := bindExpr(sc, x.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, side := range []Expr{val, pattern} {
+			if ref, ok := side.(*ColumnRef); ok && sc.columns[ref.Name].Type != core.TextType {
+				return nil, fmt.Errorf("LIKE needs a TEXT column, %s is %s", ref.Name, sc.columns[ref.Name].TypeString())
+			}
+		}
+		return &LikeExpr{Expr: val, Pattern: pattern, Not: x.Not}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported expression %T", expr)
+}
+
+// bindPair prepares the two operands of a comparison: a literal facing a
+// column takes the column's type, and two columns must be comparable.
+func bindPair(sc *scope, left, right Expr) (Expr, Expr, error) {
+	lcol, lok := left.(*ColumnRef)
+	rcol, rok := right.(*ColumnRef)
+
+	switch {
+	case lok && rok:
+		lt, rt := sc.columns[lcol.Name].Type, sc.columns[rcol.Name].Type
+		if !core.Comparable(lt, rt) {
+			return nil, nil, fmt.Errorf("cannot compare %s (%s) with %s (%s)", lcol.Name, lt, rcol.Name, rt)
+		}
+	case lok:
+		lit, err := bindLiteral(sc.columns[lcol.Name], right)
+		return left, lit, err
+	case rok:
+		lit, err := bindLiteral(sc.columns[rcol.Name], left)
+		return lit, right, err
+	}
+	return left, right, nil
+}
+
+func bindLiteral(col storage.Column, expr Expr) (Expr, error) {
+	lit, ok := expr.(*Literal)
+	if !ok || lit.Value == nil {
+		return expr, nil
+	}
+
+	switch lit.Value.(type) {
+	case int64, float64, core.Decimal:
+		if core.IsNumeric(col.Type) {
+			return lit, nil
+		}
+	}
+
+	val, err := core.Coerce(lit.Value, col.Type)
+	if err != nil {
+		return nil, fmt.Errorf("column %s: %w", col.Name, err)
+	}
+	return &Literal{Value: val}, nil
+}
+
+// bindAggregate resolves the aggregate's column to its row key and checks
+// the call against the column's type.
+func bindAggregate(sc *scope, agg Aggregate) (Aggregate, error) {
+	if agg.Column != "" {
+		key, _, err := sc.resolve(agg.Column)
+		if err != nil {
+			return agg, err
+		}
+		agg.Column = key
+	}
+
+	err := checkAggregate(agg, func(key string) (core.DataType, bool) {
+		col, ok := sc.columns[key]
+		return col.Type, ok
+	})
+	return agg, err
+}
@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// Checkpoint writes a snapshot of every table and truncates the WAL, so
+// the next startup only has to replay what happened after this point.
+func (e *Engine) Checkpoint() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.checkpoint()
+}
+
+func (e *Engine) checkpoint() error {
+	if e.wal == nil {
+		return errors.New("checkpoint requires a data directory")
+	}
+
+	lsn := e.wal.LastLSN()
+	if lsn == e.checkpointLSN {
+		return nil // nothing logged since the last checkpoint
+	}
+
+	if err := e.writeSnapshot(lsn); err != nil {
+		return err
+	}
+	for _, table := range e.Tables {
+		if err := table.Store.Checkpoint(lsn); err != nil {
+			return err
+		}
+	}
+
+	// Once the snapshot is durable the log records it covers are no longer
+	// needed. A crash before Truncate is harmless: replay skips records at
+	// or below the snapshot LSN.
+	if err := e.wal.Truncate(); err != nil {
+		return err
+	}
+	e.checkpointLSN = lsn
+
+	return e.removeSnapshotsBefore(lsn)
+}
+
+// StartCheckpointer checkpoints every interval in the background until
+// Close is called.
+func (e *Engine) StartCheckpointer(interval time.Duration) {
+	if e.wal == nil || interval <= 0 {
+		return
+	}
+
+	e.stopCheckpoint = make(chan struct{})
+	e.checkpointDone = make(chan struct{})
+
+	go func() {
+		defer close(e.checkpointDone)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := e.Checkpoint(); err != nil {
+					log.Println("checkpoint:", err)
+				}
+			case <-e.stopCheckpoint:
+				return
+			}
+		}
+	}()
+}
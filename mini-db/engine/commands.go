@@ -3,8 +3,12 @@ package engine
 import "fastabiz-mini-rdbms/mini-db/storage"
 
 type CreateTableCommand struct {
-	TableName string
-	Columns   []storage.Column
+	TableName   string
+	Columns     []storage.Column
+	PrimaryKey  []string // a PRIMARY KEY (col, ...) clause
+	ForeignKeys []storage.ForeignKey
+	Checks      []storage.CheckConstraint
+	Engine      storage.EngineKind
 }
 
 type InsertCommand struct {
@@ -12,32 +16,147 @@ type InsertCommand struct {
 	Values    storage.Row
 }
 
-type WhereClause struct {
-	Column string
-	Value  any
-}
+type JoinType string
+
+const (
+	InnerJoin JoinType = "INNER"
+	LeftJoin  JoinType = "LEFT"
+	RightJoin JoinType = "RIGHT"
+	FullJoin  JoinType = "FULL"
+	CrossJoin JoinType = "CROSS"
+)
 
+// JoinSpec describes a join of two tables on LeftColumn = RightColumn, for
+// Engine.Join. An empty Type is an inner join; a cross join has no columns.
 type JoinSpec struct {
+	Type        JoinType
 	LeftTable   string
 	RightTable  string
 	LeftColumn  string
 	RightColumn string
 }
 
+// JoinClause is one `JOIN table [alias] ON condition` of a FROM clause. An
+// empty Type is an inner join; a cross join has no condition.
+type JoinClause struct {
+	Type  JoinType
+	Table string
+	Alias string
+	On    Expr
+}
+
+// SelectItem is one entry of a select list: a column, *, alias.* or an
+// aggregate, optionally renamed with AS. Columns may be qualified by a
+// table name or alias, as in u.name.
+type SelectItem struct {
+	Column string
+	Agg    *Aggregate
+	Alias  string
+}
+
+// Name is the item's column name in the result.
+func (it SelectItem) Name() string {
+	switch {
+	case it.Alias != "":
+		return it.Alias
+	case it.Agg != nil:
+		return it.Agg.String()
+	}
+	return it.Column
+}
+
+// SelectCommand reads from TableName, optionally under Alias, joined left
+// to right with each of Joins.
 type SelectCommand struct {
 	TableName string
-	Columns   []string
-	Join      *JoinSpec
-	Where     *WhereClause
+	Alias     string
+	Distinct  bool
+	Columns   []SelectItem
+	Joins     []JoinClause
+	Where     Expr
+	GroupBy   []string
+	Having    Expr
+	OrderBy   []OrderTerm
+	Limit     *int // nil means no LIMIT
+	Offset    int
+}
+
+type SetOp string
+
+const (
+	SetUnion     SetOp = "UNION"
+	SetUnionAll  SetOp = "UNION ALL"
+	SetIntersect SetOp = "INTERSECT"
+	SetExcept    SetOp = "EXCEPT"
+)
+
+// CompoundSelectCommand combines SELECTs with set operators, left to right:
+// Ops[i] joins Selects[i+1] onto the result so far. ORDER BY, LIMIT and
+// OFFSET apply to the combined rows and name the first SELECT's columns.
+type CompoundSelectCommand struct {
+	Selects []SelectCommand
+	Ops     []SetOp
+	OrderBy []OrderTerm
+	Limit   *int
+	Offset  int
 }
 
 type DeleteCommand struct {
 	TableName string
-	Where     *WhereClause
+	Where     Expr
 }
 
 type UpdateCommand struct {
 	TableName string
 	Set       map[string]any
-	Where     *WhereClause
-}
\ No newline at end of file
+	Where     Expr
+}
+
+type CheckpointCommand struct{}
+
+// CreateIndexCommand is CREATE [UNIQUE] INDEX name ON table (col, ...).
+type CreateIndexCommand struct {
+	IndexName string
+	TableName string
+	Columns   []string
+	Unique    bool
+}
+
+type DropIndexCommand struct {
+	IndexName string
+}
+
+type AlterAction string
+
+const (
+	AddColumn       AlterAction = "ADD COLUMN"
+	DropColumn      AlterAction = "DROP COLUMN"
+	RenameColumn    AlterAction = "RENAME COLUMN"
+	RenameTable     AlterAction = "RENAME TO"
+	AlterColumnType AlterAction = "ALTER COLUMN TYPE"
+)
+
+// AlterTableCommand is ALTER TABLE with one action on Column, or on the
+// table itself for RenameTable. Def is the column ADD COLUMN adds, with
+// the foreign keys and checks it declares, or holds the new type for
+// AlterColumnType.
+type AlterTableCommand struct {
+	TableName string
+	Action    AlterAction
+	Column    string
+	NewName   string // RenameColumn and RenameTable
+
+	Def         storage.Column
+	ForeignKeys []storage.ForeignKey
+	Checks      []storage.CheckConstraint
+}
+
+// ExplainCommand shows the plan of a SELECT, or of the UPDATE or DELETE
+// set instead. With Analyze the query is run as well, and each plan node
+// reports the rows it returned and the time spent in it.
+type ExplainCommand struct {
+	Analyze bool
+	Select  SelectCommand
+	Update  *UpdateCommand
+	Delete  *DeleteCommand
+}
@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	"fastabiz-mini-rdbms/mini-db/storage"
+)
+
+// commit logs rec (when the engine is durable) and then applies it.
+// Callers must have validated the statement already: anything that reaches
+// the log has to apply cleanly on replay.
+func (e *Engine) commit(rec *logRecord) error {
+	var lsn uint64
+
+	if e.wal != nil {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(rec); err != nil {
+			return err
+		}
+
+		var err error
+		if lsn, err = e.wal.Append(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return e.apply(rec, lsn)
+}
+
+func (e *Engine) apply(rec *logRecord, lsn uint64) error {
+	switch rec.Kind {
+	case recCreateTable:
+		table, err := newTable(*rec.Create)
+		if err != nil {
+			return err
+		}
+		if err := e.openStore(table); err != nil {
+			return err
+		}
+		e.Tables[table.Name] = table
+
+	case recCreateIndex:
+		return e.applyCreateIndex(rec.CreateIndex)
+
+	case recDropIndex:
+		return e.applyDropIndex(rec.DropIndex)
+
+	case recAlterTable:
+		return e.applyAlterTable(rec.Alter, rec.Changes, lsn)
+
+	case recWrite:
+		// Old row images leave the indexes before any new one goes in, so
+		// a statement that swaps two unique values never trips over
+		// itself.
+		if !e.recovering {
+			for _, c := range rec.Changes {
+				if err := e.unindexChange(c); err != nil {
+					return err
+				}
+			}
+		}
+		for _, c := range rec.Changes {
+			if err := e.applyChange(c, lsn); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("unknown record kind %d", rec.Kind)
+	}
+
+	return nil
+}
+
+// applyChange writes one row change to the table's store and adds the new
+// row image to its indexes. While recovering, index maintenance is
+// skipped: Open rebuilds the indexes once replay is done.
+func (e *Engine) applyChange(c rowChange, lsn uint64) error {
+	table, ok := e.Tables[c.Table]
+	if !ok {
+		return fmt.Errorf("table %s does not exist", c.Table)
+	}
+
+	switch c.Kind {
+	case changeInsert:
+		if !e.recovering {
+			if err := indexRow(table, c.RowID, c.Row); err != nil {
+				return err
+			}
+		}
+		advanceAutoInc(table, c.Row)
+		return table.Store.Insert(c.RowID, c.Row, lsn)
+
+	case changeUpdate:
+		if !e.recovering {
+			if err := indexRow(table, c.RowID, c.Row); err != nil {
+				return err
+			}
+		}
+		return table.Store.Update(c.RowID, c.Row, lsn)
+
+	case changeDelete:
+		return table.Store.Delete(c.RowID, lsn)
+
+	default:
+		return fmt.Errorf("unknown change kind %d", c.Kind)
+	}
+}
+
+// advanceAutoInc moves the table's counter past an inserted row's primary
+// key, given or generated, so it never hands out a key twice: not after a
+// delete, and not after a restart, as replaying the insert advances it
+// again.
+func advanceAutoInc(table *storage.Table, row storage.Row) {
+	if len(table.PrimaryKey) != 1 || !table.ColumnMap[table.PrimaryKey[0]].AutoIncrement {
+		return
+	}
+	if id, ok := row[table.PrimaryKey[0]].(int64); ok && id >= int64(table.AutoInc) {
+		table.AutoInc = int(id) + 1
+	}
+}
+
+// unindexChange takes the row an update or delete replaces out of the
+// table's indexes.
+func (e *Engine) unindexChange(c rowChange) error {
+	if c.Kind == changeInsert {
+		return nil
+	}
+
+	table, ok := e.Tables[c.Table]
+	if !ok {
+		return fmt.Errorf("table %s does not exist", c.Table)
+	}
+	row, ok, err := table.Store.Get(c.RowID)
+	if err != nil || !ok {
+		return err
+	}
+	unindexRow(table, c.RowID, row)
+	return nil
+}
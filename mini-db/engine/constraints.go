@@ -0,0 +1,151 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"fastabiz-mini-rdbms/mini-db/core"
+	"fastabiz-mini-rdbms/mini-db/storage"
+)
+
+// checkChanges enforces the constraints of every table a statement
+// writes to, before anything is logged.
+func (e *Engine) checkChanges(changes []rowChange) error {
+	var tables []string
+	byTable := make(map[string][]rowChange)
+	for _, c := range changes {
+		if _, ok := byTable[c.Table]; !ok {
+			tables = append(tables, c.Table)
+		}
+		byTable[c.Table] = append(byTable[c.Table], c)
+	}
+
+	for _, name := range tables {
+		if err := checkRows(e.Tables[name], byTable[name]); err != nil {
+			return err
+		}
+	}
+	return e.checkReferences(changes)
+}
+
+// columnDefault is the value an INSERT that leaves col out stores in it.
+func columnDefault(col storage.Column, now time.Time) (any, error) {
+	if col.Default == nil {
+		return nil, nil
+	}
+
+	val := col.Default.Value
+	switch col.Default.Func {
+	case "CURRENT_TIMESTAMP":
+		val = core.TimestampOf(now)
+	case "CURRENT_DATE":
+		val = core.DateOf(now)
+	}
+	return col.Coerce(val)
+}
+
+// checkRows enforces the constraints of table on the rows a statement
+// writes to it: NOT NULL, CHECK and unique keys. It also makes sure the
+// table's store can hold them.
+func checkRows(table *storage.Table, changes []rowChange) error {
+	for _, c := range changes {
+		if c.Kind == changeDelete {
+			continue
+		}
+		for _, col := range table.Columns {
+			if col.NotNull && c.Row[col.Name] == nil {
+				return fmt.Errorf("null value in column %s violates NOT NULL constraint of table %s", col.Name, table.Name)
+			}
+		}
+		for i, check := range table.CheckExprs {
+			val, err := check.Eval(c.Row)
+			if err == nil {
+				val, err = truth(val)
+			}
+			if err != nil {
+				return fmt.Errorf("check constraint %s: %w", table.Checks[i].Name, err)
+			}
+			if val == false {
+				return fmt.Errorf("new row for table %s violates check constraint %s", table.Name, table.Checks[i].Name)
+			}
+		}
+		if err := table.Store.Check(c.RowID, c.Row); err != nil {
+			return err
+		}
+	}
+	return checkUnique(table, changes)
+}
+
+// newChecks names the CHECK constraints of a new table that have no name,
+// users_age_check for a check on users.age and users_check for a
+// table-level one, numbering them apart when needed. Each must bind
+// against the table's columns; the bound expressions are kept in
+// table.CheckExprs, so writes need not parse them again.
+func newChecks(table *storage.Table, checks []storage.CheckConstraint) ([]storage.CheckConstraint, error) {
+	taken := make(map[string]bool)
+	for _, fk := range table.ForeignKeys {
+		taken[fk.Name] = true
+	}
+	for _, check := range checks {
+		if check.Name != "" {
+			if taken[check.Name] {
+				return nil, fmt.Errorf("constraint %s already exists", check.Name)
+			}
+			taken[check.Name] = true
+		}
+	}
+
+	named := make([]storage.CheckConstraint, len(checks))
+	for i, check := range checks {
+		if check.Name == "" {
+			base := table.Name + "_check"
+			if check.Column != "" {
+				base = table.Name + "_" + check.Column + "_check"
+			}
+			check.Name = base
+			for n := 1; taken[check.Name]; n++ {
+				check.Name = fmt.Sprintf("%s%d", base, n)
+			}
+			taken[check.Name] = true
+		}
+		named[i] = check
+	}
+
+	table.Checks = named
+	exprs, err := bindChecks(table)
+	if err != nil {
+		return nil, err
+	}
+	table.CheckExprs = exprs
+	return named, nil
+}
+
+// parseCheckExpr parses the SQL text of a CHECK constraint.
+func parseCheckExpr(check storage.CheckConstraint) (Expr, error) {
+	tokens, err := Tokenize(check.Expr)
+	if err != nil {
+		return nil, err
+	}
+	p := NewParser(tokens)
+	expr, err := p.parseExpr()
+	if err == nil && p.current().Type != EOF {
+		err = fmt.Errorf("unexpected token %s", p.current().Literal)
+	}
+	return expr, err
+}
+
+// bindChecks parses and binds the CHECK constraints of table, in order.
+func bindChecks(table *storage.Table) ([]storage.Predicate, error) {
+	var checks []storage.Predicate
+	for _, check := range table.Checks {
+		expr, err := parseCheckExpr(check)
+		if err == nil {
+			expr, err = bindCondition(tableScope(table, ""), expr, "CHECK")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("check constraint %s: %w", check.Name, err)
+		}
+		checks = append(checks, expr)
+	}
+	return checks, nil
+}
@@ -0,0 +1,303 @@
+package engine
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// failsWith executes a statement that is expected to be refused with an
+// error mentioning msg.
+func failsWith(t *testing.T, e *Engine, sql, msg string) {
+	t.Helper()
+	if _, err := run(e, sql); err == nil || !strings.Contains(err.Error(), msg) {
+		t.Fatalf("%s: got error %v, want one mentioning %q", sql, err, msg)
+	}
+}
+
+func checkQuery(t *testing.T, e *Engine, sql string, want ...string) {
+	t.Helper()
+	if got := query(t, e, sql); !slices.Equal(got, want) {
+		t.Fatalf("%s:\ngot  %q\nwant %q", sql, got, want)
+	}
+}
+
+func TestColumnConstraints(t *testing.T) {
+	e := NewEngine()
+	mustRun(t, e,
+		"CREATE TABLE products (id INT PRIMARY KEY, sku TEXT UNIQUE NOT NULL, price INT, sale INT)",
+		"INSERT INTO products (id, sku, price) VALUES (1, 'a', 10)",
+		"INSERT INTO products (id, sku, price, sale) VALUES (2, 'b', 10, 5)",
+	)
+
+	tests := []struct {
+		sql, msg string
+	}{
+		{"INSERT INTO products (id, sku, price) VALUES (1, 'c', 1)", "duplicate primary key"},
+		{"INSERT INTO products (id, price) VALUES (3, 1)", "column sku violates NOT NULL"},
+		{"INSERT INTO products (id, sku, price) VALUES (3, NULL, 1)", "column sku violates NOT NULL"},
+		{"INSERT INTO products (id, sku, price) VALUES (3, 'a', 1)", "unique constraint products_sku_key"},
+		{"UPDATE products SET sku = 'b' WHERE id = 1", "unique constraint products_sku_key"},
+		{"UPDATE products SET sku = NULL WHERE id = 2", "column sku violates NOT NULL"},
+		{"UPDATE products SET sku = 'z'", "unique constraint products_sku_key"},
+	}
+	for _, tt := range tests {
+		failsWith(t, e, tt.sql, tt.msg)
+	}
+
+	// A NULL may repeat in a unique index.
+	mustRun(t, e,
+		"INSERT INTO products (id, sku) VALUES (3, 'c')",
+		"CREATE UNIQUE INDEX products_sale ON products (sale)",
+		"UPDATE products SET sku = 'a' WHERE id = 1",
+	)
+	failsWith(t, e, "UPDATE products SET sale = 5 WHERE id = 1", "unique index products_sale")
+
+	// A refused statement changes nothing.
+	checkQuery(t, e, "SELECT id, sku, price, sale FROM products ORDER BY id",
+		"1 a 10 <nil>", "2 b 10 5", "3 c <nil> <nil>")
+}
+
+// The constraints are part of the schema a snapshot keeps.
+func TestColumnConstraintsRecovered(t *testing.T) {
+	dir := t.TempDir()
+	e := openEngine(t, dir)
+	mustRun(t, e,
+		"CREATE TABLE m (id INT PRIMARY KEY, s TEXT UNIQUE NOT NULL)",
+		"CREATE TABLE d (id INT PRIMARY KEY, s TEXT UNIQUE NOT NULL) ENGINE = disk",
+		"INSERT INTO m (id, s) VALUES (1, 'a')",
+		"INSERT INTO d (id, s) VALUES (1, 'a')",
+		"CHECKPOINT",
+	)
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	e = openEngine(t, dir)
+	defer e.Close()
+	for _, table := range []string{"m", "d"} {
+		failsWith(t, e, "INSERT INTO "+table+" (id, s) VALUES (2, 'a')", "unique constraint "+table+"_s_key")
+		failsWith(t, e, "INSERT INTO "+table+" (id) VALUES (2)", "column s violates NOT NULL")
+	}
+}
+
+func TestForeignKeys(t *testing.T) {
+	e := NewEngine()
+	mustRun(t, e,
+		"CREATE TABLE users (id INT PRIMARY KEY, email TEXT UNIQUE, name TEXT)",
+		`CREATE TABLE orders (id INT PRIMARY KEY, user_id INT, email TEXT,
+			CONSTRAINT orders_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+			CONSTRAINT orders_email FOREIGN KEY (email) REFERENCES users (email) ON DELETE SET NULL ON UPDATE CASCADE)`,
+		"CREATE TABLE notes (id INT PRIMARY KEY, order_id INT REFERENCES orders (id))",
+		"INSERT INTO users (id, email) VALUES (1, 'a@x')",
+		"INSERT INTO users (id, email) VALUES (2, 'b@x')",
+		"INSERT INTO orders (id, user_id, email) VALUES (10, 1, 'b@x')",
+		"INSERT INTO orders (id, user_id, email) VALUES (11, 2, 'b@x')",
+		"INSERT INTO orders (id, user_id) VALUES (12, NULL)",
+	)
+
+	creates := []struct {
+		sql, msg string
+	}{
+		{"CREATE TABLE x (id INT PRIMARY KEY, u INT REFERENCES nope (id))", "referenced table nope does not exist"},
+		{"CREATE TABLE x (id INT PRIMARY KEY, u TEXT REFERENCES users (name))", "no unique index of table users covers (name)"},
+		{"CREATE TABLE x (id INT PRIMARY KEY, u TEXT REFERENCES users (id))", "column u of type TEXT cannot reference users.id"},
+		{"CREATE TABLE x (id INT PRIMARY KEY, u INT NOT NULL REFERENCES users (id) ON DELETE SET NULL)", "cannot SET NULL"},
+		{"CREATE TABLE x (id INT PRIMARY KEY, u INT REFERENCES users (id) ON UPDATE CASCADE)", "primary key of table users cannot be updated"},
+		{"CREATE TABLE x (id INT PRIMARY KEY, u INT REFERENCES users ON UPDATE SET NULL)", "cannot ON UPDATE SET NULL"},
+		{"CREATE TABLE x (id INT PRIMARY KEY, p INT REFERENCES x ON UPDATE CASCADE)", "primary key of table x cannot be updated"},
+	}
+	for _, tt := range creates {
+		failsWith(t, e, tt.sql, tt.msg)
+	}
+
+	failsWith(t, e, "INSERT INTO orders (id, user_id) VALUES (13, 3)", "foreign key constraint orders_user")
+	failsWith(t, e, "UPDATE orders SET email = 'c@x' WHERE id = 10", "foreign key constraint orders_email")
+
+	// ON UPDATE CASCADE carries a new key to the referencing rows.
+	mustRun(t, e, "UPDATE users SET email = 'c@x' WHERE id = 2")
+	checkQuery(t, e, "SELECT id, user_id, email FROM orders ORDER BY id", "10 1 c@x", "11 2 c@x", "12 <nil> <nil>")
+
+	// Deleting user 2 cascades to order 11 and nulls the emails matching
+	// it; the default RESTRICT of notes holds the delete back while a note
+	// refers to the order.
+	mustRun(t, e, "INSERT INTO notes (id, order_id) VALUES (1, 11)")
+	failsWith(t, e, "DELETE FROM users WHERE id = 2", "violates foreign key constraint")
+	checkQuery(t, e, "SELECT id FROM users ORDER BY id", "1", "2")
+
+	mustRun(t, e,
+		"DELETE FROM notes WHERE id = 1",
+		"DELETE FROM users WHERE id = 2",
+	)
+	checkQuery(t, e, "SELECT id, user_id, email FROM orders ORDER BY id", "10 1 <nil>", "12 <nil> <nil>")
+}
+
+func TestDefaults(t *testing.T) {
+	e := NewEngine()
+	mustRun(t, e,
+		`CREATE TABLE log (id INT PRIMARY KEY, kind TEXT DEFAULT 'info', n INT DEFAULT -1,
+			ok BOOLEAN DEFAULT TRUE, note TEXT, at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, day DATE DEFAULT CURRENT_DATE)`,
+		"INSERT INTO log (id) VALUES (1)",
+		"INSERT INTO log (id, kind, n, ok, note) VALUES (2, 'warn', 5, FALSE, 'x')",
+		"INSERT INTO log (id, kind, n) VALUES (3, NULL, NULL)",
+	)
+	checkQuery(t, e, "SELECT id, kind, n, ok, note FROM log ORDER BY id",
+		"1 info -1 true <nil>", "2 warn 5 false x", "3 <nil> <nil> true <nil>")
+	checkQuery(t, e, "SELECT COUNT(*) FROM log WHERE at IS NULL OR day IS NULL", "0")
+
+	creates := []struct {
+		sql, msg string
+	}{
+		{"CREATE TABLE x (id INT PRIMARY KEY, n INT DEFAULT 'abc')", "invalid default for column n"},
+		{"CREATE TABLE x (id INT PRIMARY KEY, d DATE DEFAULT 'soon')", "invalid default for column d"},
+		{"CREATE TABLE x (id SERIAL PRIMARY KEY DEFAULT 1)", "both DEFAULT and AUTOINCREMENT"},
+		{"CREATE TABLE x (id INT PRIMARY KEY, n INT AUTOINCREMENT)", "must be an INT primary key"},
+		{"CREATE TABLE x (id TEXT PRIMARY KEY AUTOINCREMENT)", "must be an INT primary key"},
+	}
+	for _, tt := range creates {
+		failsWith(t, e, tt.sql, tt.msg)
+	}
+}
+
+// An AUTOINCREMENT key is never handed out twice: not after a delete, not
+// after an explicit key skips ahead, and not after a restart.
+func TestAutoIncrement(t *testing.T) {
+	dir := t.TempDir()
+	e := openEngine(t, dir)
+	mustRun(t, e,
+		"CREATE TABLE m (id SERIAL PRIMARY KEY, s TEXT)",
+		"CREATE TABLE d (id INT PRIMARY KEY AUTOINCREMENT, s TEXT) ENGINE = disk",
+	)
+	for _, table := range []string{"m", "d"} {
+		mustRun(t, e,
+			"INSERT INTO "+table+" (s) VALUES ('a')",
+			"INSERT INTO "+table+" (s) VALUES ('b')",
+			"DELETE FROM "+table+" WHERE id = 2",
+			"INSERT INTO "+table+" (s) VALUES ('c')",
+			"INSERT INTO "+table+" (id, s) VALUES (10, 'd')",
+			"INSERT INTO "+table+" (s) VALUES ('e')",
+		)
+		failsWith(t, e, "INSERT INTO "+table+" (id, s) VALUES (11, 'f')", "duplicate primary key")
+	}
+	mustRun(t, e, "CHECKPOINT", "INSERT INTO m (s) VALUES ('f')", "DELETE FROM d WHERE id = 11")
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	e = openEngine(t, dir)
+	defer e.Close()
+	mustRun(t, e, "INSERT INTO m (s) VALUES ('g')", "INSERT INTO d (s) VALUES ('g')")
+	checkQuery(t, e, "SELECT id, s FROM m ORDER BY id", "1 a", "3 c", "10 d", "11 e", "12 f", "13 g")
+	checkQuery(t, e, "SELECT id, s FROM d ORDER BY id", "1 a", "3 c", "10 d", "12 g")
+}
+
+func TestCheckConstraints(t *testing.T) {
+	dir := t.TempDir()
+	e := openEngine(t, dir)
+	mustRun(t, e,
+		`CREATE TABLE products (id INT PRIMARY KEY, price INT CHECK (price >= 0), sale INT,
+			CONSTRAINT sale_below_price CHECK (sale IS NULL OR sale < price))`,
+		"INSERT INTO products (id, price) VALUES (1, 10)",
+		"INSERT INTO products (id, price, sale) VALUES (2, 10, 5)",
+	)
+
+	tests := []struct {
+		sql, msg string
+	}{
+		{"INSERT INTO products (id, price) VALUES (3, -1)", "check constraint products_price_check"},
+		{"INSERT INTO products (id, price, sale) VALUES (3, 5, 5)", "check constraint sale_below_price"},
+		{"UPDATE products SET price = 4 WHERE id = 2", "check constraint sale_below_price"},
+		{"UPDATE products SET price = -5", "check constraint products_price_check"},
+		{"CREATE TABLE x (id INT PRIMARY KEY, n INT CHECK (m > 0))", "column m does not exist"},
+		{"CREATE TABLE x (id INT PRIMARY KEY, n INT CONSTRAINT c CHECK (n > 0), CONSTRAINT c CHECK (n < 9))", "constraint c already exists"},
+	}
+	for _, tt := range tests {
+		failsWith(t, e, tt.sql, tt.msg)
+	}
+
+	// A CHECK that comes out NULL passes.
+	mustRun(t, e, "INSERT INTO products (id, price, sale) VALUES (3, NULL, 1)")
+	checkQuery(t, e, "SELECT id, price, sale FROM products ORDER BY id", "1 10 <nil>", "2 10 5", "3 <nil> 1")
+
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	e = openEngine(t, dir)
+	defer e.Close()
+	failsWith(t, e, "INSERT INTO products (id, price) VALUES (4, -1)", "check constraint products_price_check")
+	failsWith(t, e, "UPDATE products SET sale = 20 WHERE id = 1", "check constraint sale_below_price")
+}
+
+// TestChecksBoundOnce checks CHECK constraints are bound when their table
+// is built rather than on every write, and bound again when ALTER TABLE
+// rebuilds the table.
+func TestChecksBoundOnce(t *testing.T) {
+	e := NewEngine()
+	mustRun(t, e, "CREATE TABLE t (id INT PRIMARY KEY, n INT CHECK (n > 0))")
+
+	// Text that no longer parses goes unnoticed by writes.
+	e.Tables["t"].Checks[0].Expr = "n >"
+	failsWith(t, e, "INSERT INTO t (id, n) VALUES (1, 0)", "violates check constraint t_n_check")
+	e.Tables["t"].Checks[0].Expr = "n > 0"
+
+	mustRun(t, e, "ALTER TABLE t RENAME COLUMN n TO m")
+	failsWith(t, e, "INSERT INTO t (id, m) VALUES (1, 0)", "violates check constraint t_n_check")
+	mustRun(t, e, "INSERT INTO t (id, m) VALUES (1, 1)")
+}
+
+func TestPrimaryKeys(t *testing.T) {
+	dir := t.TempDir()
+	e := openEngine(t, dir)
+	mustRun(t, e,
+		"CREATE TABLE orders (id INT PRIMARY KEY)",
+		`CREATE TABLE items (order_id INT REFERENCES orders ON DELETE CASCADE, product_id INT, qty INT,
+			PRIMARY KEY (order_id, product_id)) ENGINE = disk`,
+		"CREATE TABLE log (msg TEXT, n INT)",
+		"INSERT INTO orders (id) VALUES (1)",
+		"INSERT INTO orders (id) VALUES (2)",
+		"INSERT INTO items (order_id, product_id, qty) VALUES (1, 10, 1)",
+		"INSERT INTO items (order_id, product_id, qty) VALUES (1, 11, 2)",
+		"INSERT INTO items (order_id, product_id, qty) VALUES (2, 10, 3)",
+		"INSERT INTO log (msg, n) VALUES ('a', 1)",
+		"INSERT INTO log (msg, n) VALUES ('a', 1)",
+		"INSERT INTO log (msg) VALUES (NULL)",
+	)
+
+	tests := []struct {
+		sql, msg string
+	}{
+		{"INSERT INTO items (order_id, product_id, qty) VALUES (1, 10, 5)", "duplicate primary key"},
+		{"INSERT INTO items (order_id, qty) VALUES (1, 5)", "primary key missing"},
+		{"INSERT INTO items (order_id, product_id, qty) VALUES (1, NULL, 5)", "primary key cannot be NULL"},
+		{"UPDATE items SET product_id = 12 WHERE qty = 1", "cannot update primary key"},
+		{"CREATE TABLE x (a INT, PRIMARY KEY (a, b))", "primary key column b does not exist"},
+		{"CREATE TABLE x (a INT, b INT, PRIMARY KEY (a, a))", "column a appears twice in primary key"},
+		{"CREATE TABLE x (a INT PRIMARY KEY, b INT PRIMARY KEY)", "multiple primary keys not allowed"},
+		{"CREATE TABLE x (a INT PRIMARY KEY, PRIMARY KEY (a))", "multiple primary keys not allowed"},
+		{"CREATE TABLE x (a INT PRIMARY KEY, b INT, PRIMARY KEY (a, b))", "multiple primary keys not allowed"},
+		{"CREATE TABLE x (a SERIAL, b INT, PRIMARY KEY (a, b))", "must be an INT primary key"},
+	}
+	for _, tt := range tests {
+		failsWith(t, e, tt.sql, tt.msg)
+	}
+
+	mustRun(t, e,
+		"UPDATE items SET qty = 9 WHERE order_id = 1 AND product_id = 11",
+		"UPDATE log SET n = 2 WHERE msg = 'a'",
+		"DELETE FROM orders WHERE id = 2",
+		"CHECKPOINT",
+		"INSERT INTO items (order_id, product_id, qty) VALUES (1, 12, 4)",
+		"INSERT INTO log (msg, n) VALUES ('b', 3)",
+	)
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	e = openEngine(t, dir)
+	defer e.Close()
+	checkQuery(t, e, "SELECT order_id, product_id, qty FROM items ORDER BY order_id, product_id", "1 10 1", "1 11 9", "1 12 4")
+	checkQuery(t, e, "SELECT msg, n FROM log ORDER BY n", "<nil> <nil>", "a 2", "a 2", "b 3")
+	failsWith(t, e, "INSERT INTO items (order_id, product_id, qty) VALUES (1, 11, 5)", "duplicate primary key")
+	mustRun(t, e, "INSERT INTO log (msg, n) VALUES ('b', 3)")
+	checkQuery(t, e, "SELECT COUNT(*) FROM log WHERE msg = 'b'", "2")
+}
@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+
+	"fastabiz-mini-rdbms/mini-db/storage"
+)
+
+// CreateIndex adds a secondary index on one or more columns of a table and
+// fills it from the rows already there. Index names are shared by all
+// tables.
+func (e *Engine) CreateIndex(cmd CreateIndexCommand) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	table, ok := e.Tables[cmd.TableName]
+	if !ok {
+		return errors.New("table does not exist")
+	}
+	if _, _, exists := e.findIndex(cmd.IndexName); exists {
+		return fmt.Errorf("index %s already exists", cmd.IndexName)
+	}
+	if len(cmd.Columns) == 0 {
+		return errors.New("index needs at least one column")
+	}
+
+	seen := make(map[string]bool, len(cmd.Columns))
+	for _, col := range cmd.Columns {
+		if _, ok := table.ColumnMap[col]; !ok {
+			return fmt.Errorf("column %s does not exist in table %s", col, table.Name)
+		}
+		if seen[col] {
+			return fmt.Errorf("column %s appears twice in index %s", col, cmd.IndexName)
+		}
+		seen[col] = true
+	}
+
+	// A unique index must not start out with duplicates: build it once
+	// here, before logging, to find out.
+	if cmd.Unique {
+		trial := newSecondaryIndex(cmd)
+		if err := buildIndex(table, trial); err != nil {
+			return err
+		}
+	}
+
+	return e.commit(&logRecord{Kind: recCreateIndex, CreateIndex: &cmd})
+}
+
+func (e *Engine) DropIndex(cmd DropIndexCommand) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	table, i, exists := e.findIndex(cmd.IndexName)
+	if !exists {
+		return fmt.Errorf("index %s does not exist", cmd.IndexName)
+	}
+	if table.Indexes[i].Constraint {
+		return fmt.Errorf("cannot drop index %s: it enforces a UNIQUE constraint of table %s", cmd.IndexName, table.Name)
+	}
+	for _, ref := range e.referencing(table.Name) {
+		if ix, _ := referencedIndex(table, ref.fk.RefColumns); ix.name == cmd.IndexName {
+			return fmt.Errorf("cannot drop index %s: foreign key %s of table %s depends on it", cmd.IndexName, ref.fk.Name, ref.child.Name)
+		}
+	}
+	return e.commit(&logRecord{Kind: recDropIndex, DropIndex: &cmd})
+}
+
+// findIndex finds a secondary index by name, returning its table and its
+// position in the table's Indexes.
+func (e *Engine) findIndex(name string) (*storage.Table, int, bool) {
+	for _, table := range e.Tables {
+		for i, ix := range table.Indexes {
+			if ix.Name == name {
+				return table, i, true
+			}
+		}
+	}
+	return nil, 0, false
+}
+
+func newSecondaryIndex(cmd CreateIndexCommand) *storage.SecondaryIndex {
+	return &storage.SecondaryIndex{
+		Name:    cmd.IndexName,
+		Columns: cmd.Columns,
+		Unique:  cmd.Unique,
+		Index:   newIndex(cmd.Unique),
+	}
+}
+
+// applyCreateIndex adds the index to its table. While recovering it is
+// left empty, as Open rebuilds every index after replay.
+func (e *Engine) applyCreateIndex(cmd *CreateIndexCommand) error {
+	table, ok := e.Tables[cmd.TableName]
+	if !ok {
+		return fmt.Errorf("table %s does not exist", cmd.TableName)
+	}
+
+	ix := newSecondaryIndex(*cmd)
+	if !e.recovering {
+		if err := buildIndex(table, ix); err != nil {
+			return err
+		}
+	}
+	table.Indexes = append(table.Indexes, ix)
+	return nil
+}
+
+func (e *Engine) applyDropIndex(cmd *DropIndexCommand) error {
+	table, i, ok := e.findIndex(cmd.IndexName)
+	if !ok {
+		return fmt.Errorf("index %s does not exist", cmd.IndexName)
+	}
+	table.Indexes = append(table.Indexes[:i:i], table.Indexes[i+1:]...)
+	return nil
+}
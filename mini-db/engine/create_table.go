@@ -2,50 +2,209 @@ package engine
 
 import (
 	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"fastabiz-mini-rdbms/mini-db/core"
 	"fastabiz-mini-rdbms/mini-db/index"
 	"fastabiz-mini-rdbms/mini-db/storage"
 )
 
 func (e *Engine) CreateTable(cmd CreateTableCommand) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	if _, exists := e.Tables[cmd.TableName]; exists {
 		return errors.New("table already exists")
 	}
 
+	// Validate before logging; apply builds the table again from cmd.
+	table, err := newTable(cmd)
+	if err != nil {
+		return err
+	}
+	if err := e.resolveForeignKeys(table); err != nil {
+		return err
+	}
+	cmd.ForeignKeys = table.ForeignKeys
+	for _, ix := range table.Indexes {
+		if _, _, exists := e.findIndex(ix.Name); exists {
+			return fmt.Errorf("index %s already exists", ix.Name)
+		}
+	}
+	if cmd.Engine == storage.DiskEngine && e.pool == nil {
+		return errors.New("disk tables require a data directory")
+	}
+
+	return e.commit(&logRecord{Kind: recCreateTable, Create: &cmd})
+}
+
+// newTable builds the schema side of a table. The caller attaches a store
+// with openStore.
+func newTable(cmd CreateTableCommand) (*storage.Table, error) {
 	columnMap := make(map[string]storage.Column)
-	var primaryKey string
-	var primaryKeyIndex *index.PKIndex
+	var declared []string // columns declared PRIMARY KEY themselves
 
 	for _, col := range cmd.Columns {
 		if _, exists := columnMap[col.Name]; exists {
-			return errors.New("duplicate column: " + col.Name)
+			return nil, errors.New("duplicate column: " + col.Name)
 		}
-
 		if col.Primary {
-			if primaryKey != "" {
-				return errors.New("multiple primary keys not allowed")
+			declared = append(declared, col.Name)
+		}
+		columnMap[col.Name] = col
+	}
+
+	primaryKey, err := newPrimaryKey(cmd.PrimaryKey, declared, columnMap)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every primary key column is marked, so the columns alone tell which
+	// ones a key covers.
+	columns := slices.Clone(cmd.Columns)
+	for i := range columns {
+		col := &columns[i]
+		col.Primary = slices.Contains(primaryKey, col.Name)
+		columnMap[col.Name] = *col
+
+		if col.AutoIncrement && (len(primaryKey) != 1 || !col.Primary || col.Type != core.IntType) {
+			return nil, fmt.Errorf("AUTOINCREMENT column %s must be an INT primary key", col.Name)
+		}
+		if col.Default != nil {
+			if col.AutoIncrement {
+				return nil, fmt.Errorf("column %s cannot have both DEFAULT and AUTOINCREMENT", col.Name)
+			}
+			if _, err := columnDefault(*col, time.Now()); err != nil {
+				return nil, fmt.Errorf("invalid default for column %s: %w", col.Name, err)
 			}
-			primaryKey = col.Name
-			primaryKeyIndex = index.NewPKIndex()
 		}
+	}
 
-		columnMap[col.Name] = col
+	var primaryKeyIndex index.Index
+	if len(primaryKey) > 0 {
+		primaryKeyIndex = newIndex(true)
+	}
+
+	engine := cmd.Engine
+	if engine == "" {
+		engine = storage.MemoryEngine
+	}
+
+	// Each UNIQUE column is enforced by a unique index of its own, unless
+	// it is the primary key, which already has one.
+	var indexes []*storage.SecondaryIndex
+	for _, col := range columns {
+		if col.Unique && !slices.Equal(primaryKey, []string{col.Name}) {
+			indexes = append(indexes, &storage.SecondaryIndex{
+				Name:       uniqueConstraintName(cmd.TableName, col.Name),
+				Columns:    []string{col.Name},
+				Unique:     true,
+				Constraint: true,
+				Index:      newIndex(true),
+			})
+		}
 	}
 
-	if primaryKey == "" {
-		return errors.New("primary key required")
+	foreignKeys, err := newForeignKeys(cmd, columnMap)
+	if err != nil {
+		return nil, err
 	}
 
 	table := &storage.Table{
-		Name:       cmd.TableName,
-		Columns:    cmd.Columns,
-		ColumnMap:  columnMap,
-		Rows:       make(map[storage.RowID]storage.Row),
-		PrimaryKey: primaryKey,
-		AutoInc:    1,
-		PKIndex:    primaryKeyIndex,
+		Name:        cmd.TableName,
+		Columns:     columns,
+		ColumnMap:   columnMap,
+		Engine:      engine,
+		PrimaryKey:  primaryKey,
+		AutoInc:     1,
+		PKIndex:     primaryKeyIndex,
+		Indexes:     indexes,
+		ForeignKeys: foreignKeys,
+	}
+	if table.Checks, err = newChecks(table, cmd.Checks); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// newPrimaryKey works out a table's primary key, from its PRIMARY KEY
+// (col, ...) clause or the one column declared PRIMARY KEY. A table may
+// have none, and is then only addressed by row ID.
+func newPrimaryKey(clause, declared []string, columns map[string]storage.Column) ([]string, error) {
+	if len(clause) == 0 {
+		if len(declared) > 1 {
+			return nil, errors.New("multiple primary keys not allowed")
+		}
+		return declared, nil
+	}
+
+	if len(declared) > 0 {
+		return nil, errors.New("multiple primary keys not allowed")
+	}
+	for i, name := range clause {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("primary key column %s does not exist", name)
+		}
+		if slices.Contains(clause[:i], name) {
+			return nil, fmt.Errorf("column %s appears twice in primary key", name)
+		}
+	}
+	return slices.Clone(clause), nil
+}
+
+// unmarkPrimary returns columns without the PRIMARY KEY marks newTable
+// puts on them, so that a table rebuilt from its schema has its key in
+// the PRIMARY KEY clause alone.
+func unmarkPrimary(columns []storage.Column) []storage.Column {
+	columns = slices.Clone(columns)
+	for i := range columns {
+		columns[i].Primary = false
 	}
+	return columns
+}
+
+// uniqueConstraintName names the constraint, and index, behind a UNIQUE
+// column: users_email_key for users.email.
+func uniqueConstraintName(table, column string) string {
+	return table + "_" + column + "_key"
+}
 
-	e.Tables[cmd.TableName] = table
+func (e *Engine) openStore(table *storage.Table) error {
+	switch table.Engine {
+	case storage.DiskEngine:
+		if e.pool == nil {
+			return errors.New("disk tables require a data directory")
+		}
+		if table.File == "" {
+			table.File = e.heapFile(table.Name)
+		}
+		store, err := storage.OpenDiskStore(filepath.Join(e.dir, table.File), e.pool, e.checkpointLSN)
+		if err != nil {
+			return err
+		}
+		table.Store = store
+
+	default:
+		table.Store = storage.NewMemStore()
+	}
 	return nil
+}
 
+// heapFile names the heap file of a new disk table after the table. A
+// renamed table keeps its file, so the name may be taken; a number then
+// tells the two apart.
+func (e *Engine) heapFile(name string) string {
+	taken := make(map[string]bool, len(e.Tables))
+	for _, t := range e.Tables {
+		taken[t.File] = true
+	}
+
+	file := name + ".heap"
+	for n := 2; taken[file]; n++ {
+		file = fmt.Sprintf("%s_%d.heap", name, n)
+	}
+	return file
 }
@@ -2,59 +2,52 @@ package engine
 
 import (
 	"errors"
-	"fastabiz-mini-rdbms/mini-db/index"
 	"fastabiz-mini-rdbms/mini-db/storage"
 )
 
 func (e *Engine) Delete(cmd *DeleteCommand) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	table, ok := e.Tables[cmd.TableName]
 	if !ok {
 		return 0, errors.New("table does not exist")
 	}
 
-	// DELETE without WHERE -> delete all rows
-	if cmd.Where == nil {
-		count := len(table.Rows)
-		table.Rows = make(map[storage.RowID]storage.Row)
-		table.NextRowID = 0
-		table.PKIndex = index.NewPKIndex()
-		return count, nil
+	where, err := bindWhere(tableScope(table, ""), cmd.Where)
+	if err != nil {
+		return 0, err
 	}
 
-	// Fast path: PK-based deletion
-	if cmd.Where.Column == table.PrimaryKey && table.PKIndex != nil {
-		return e.deleteByPk(table, cmd.Where.Value), nil
+	var changes []rowChange
+	err = forEachMatch(table, where, func(rowID storage.RowID, _ storage.Row) error {
+		changes = append(changes, deleteChange(table, rowID))
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	return e.deleteByScan(table, cmd.Where), nil
-}
-
-func (e *Engine) deleteByPk(table *storage.Table, value any) int {
-	rowID, ok := table.PKIndex.Get(value)
-	if !ok {
-		return 0
+	if len(changes) == 0 {
+		return 0, nil
 	}
+	n := len(changes)
 
-	delete(table.Rows, storage.RowID(rowID))
-	table.PKIndex.Delete(value)
-	return 1
-}
-
-func (e *Engine) deleteByScan(table *storage.Table, where *WhereClause) int {
-	deleted := 0
-
-	for rowID, row := range table.Rows {
-		if row[where.Column] == where.Value {
-
-			if table.PrimaryKey != "" {
-				pkVal := row[table.PrimaryKey]
-				table.PKIndex.Delete(pkVal)
-			}
+	// Rows referencing the deleted ones go, or lose their reference, in
+	// the same record.
+	if changes, err = e.cascade(changes); err != nil {
+		return 0, err
+	}
+	if err := e.checkChanges(changes); err != nil {
+		return 0, err
+	}
 
-			delete(table.Rows, rowID)
-			deleted++
-		}
+	if err := e.commit(&logRecord{Kind: recWrite, Changes: changes}); err != nil {
+		return 0, err
 	}
+	return n, nil
+}
 
-	return deleted
-}
\ No newline at end of file
+func deleteChange(table *storage.Table, rowID storage.RowID) rowChange {
+	return rowChange{Kind: changeDelete, Table: table.Name, RowID: rowID}
+}
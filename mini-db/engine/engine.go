@@ -1,15 +1,49 @@
 package engine
 
 import (
+	"sync"
+
 	"fastabiz-mini-rdbms/mini-db/storage"
+	"fastabiz-mini-rdbms/mini-db/wal"
 )
 
 type Engine struct {
-	Tables  map[string]*storage.Table
+	Tables map[string]*storage.Table
+
+	// mu serialises statements against the background checkpointer.
+	mu sync.Mutex
+
+	dir           string
+	wal           *wal.Log
+	pool          *storage.BufferPool
+	checkpointLSN uint64
+	recovering    bool
+
+	stopCheckpoint chan struct{}
+	checkpointDone chan struct{}
+}
+
+const DefaultBufferPoolPages = 256
+
+type Options struct {
+	// BufferPoolPages is the number of pages cached in memory for
+	// disk-backed tables.
+	BufferPoolPages int
 }
 
+// NewEngine returns a purely in-memory engine. Use Open for one that
+// persists its changes.
 func NewEngine() *Engine {
 	return &Engine{
-		Tables:  make(map[string]*storage.Table),
+		Tables: make(map[string]*storage.Table),
+	}
+}
+
+// BufferPoolStats reports the buffer pool counters. ok is false for an
+// in-memory engine, which has no pool.
+func (e *Engine) BufferPoolStats() (stats storage.BufferPoolStats, ok bool) {
+	if e.pool == nil {
+		return stats, false
 	}
+	return e.pool.Stats(), true
 }
@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"fastabiz-mini-rdbms/mini-db/core"
+)
+
+func parse(sql string) (any, error) {
+	tokens, err := Tokenize(sql)
+	if err != nil {
+		return nil, err
+	}
+	return NewParser(tokens).Parse()
+}
+
+// run parses and executes one statement the way the REPL does.
+func run(e *Engine, sql string) (*core.Result, error) {
+	cmd, err := parse(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c := cmd.(type) {
+	case *CreateTableCommand:
+		return nil, e.CreateTable(*c)
+	case *InsertCommand:
+		return nil, e.Insert(*c)
+	case *SelectCommand:
+		return e.Select(*c)
+	case *CompoundSelectCommand:
+		return e.SelectCompound(*c)
+	case *DeleteCommand:
+		n, err := e.Delete(c)
+		return &core.Result{Affected: n}, err
+	case *UpdateCommand:
+		n, err := e.Update(*c)
+		return &core.Result{Affected: n}, err
+	case *CreateIndexCommand:
+		return nil, e.CreateIndex(*c)
+	case *DropIndexCommand:
+		return nil, e.DropIndex(*c)
+	case *AlterTableCommand:
+		return nil, e.AlterTable(*c)
+	case *CheckpointCommand:
+		return nil, e.Checkpoint()
+	}
+	return nil, fmt.Errorf("unexpected command %T", cmd)
+}
+
+// mustRun executes statements that are expected to succeed.
+func mustRun(t *testing.T, e *Engine, stmts ...string) {
+	t.Helper()
+	for _, sql := range stmts {
+		if _, err := run(e, sql); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+}
+
+// mustFail executes a statement that is expected to be refused.
+func mustFail(t *testing.T, e *Engine, sql string) {
+	t.Helper()
+	if _, err := run(e, sql); err == nil {
+		t.Fatalf("%s: expected an error", sql)
+	}
+}
+
+// query returns the rows of a SELECT, one string per row holding the
+// selected values, in result order.
+func query(t *testing.T, e *Engine, sql string) []string {
+	t.Helper()
+	res, err := run(e, sql)
+	if err != nil {
+		t.Fatalf("%s: %v", sql, err)
+	}
+
+	rows := make([]string, len(res.Rows))
+	for i, row := range res.Rows {
+		vals := make([]string, len(res.Columns))
+		for j, col := range res.Columns {
+			vals[j] = fmt.Sprint(row[col])
+		}
+		rows[i] = strings.Join(vals, " ")
+	}
+	return rows
+}
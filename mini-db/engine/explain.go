@@ -0,0 +1,184 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"fastabiz-mini-rdbms/mini-db/storage"
+)
+
+// Explain returns the plan of a SELECT, UPDATE or DELETE, one line per
+// plan node, indented under the node that reads from it. Every node shows
+// the planner's row estimate. EXPLAIN ANALYZE runs the query too and adds
+// the rows each node actually returned and the time spent in it, its
+// inputs included.
+func (e *Engine) Explain(cmd ExplainCommand) ([]string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if cmd.Update != nil || cmd.Delete != nil {
+		op, err := e.planWrite(cmd)
+		if err != nil {
+			return nil, err
+		}
+		return explainLines(op, nil), nil
+	}
+
+	op, _, err := e.planSelect(cmd.Select)
+	if err != nil {
+		return nil, err
+	}
+	if !cmd.Analyze {
+		if err := refineJoins(op); err != nil {
+			return nil, err
+		}
+		return explainLines(op, nil), nil
+	}
+
+	instrument(&op)
+	defer op.Close()
+
+	start := time.Now()
+	if err := op.Open(); err != nil {
+		return nil, err
+	}
+	rows, err := drain(op)
+	if err != nil {
+		return nil, err
+	}
+	elapsed := time.Since(start)
+
+	return explainLines(op, []string{
+		fmt.Sprintf("Rows: %d", len(rows)),
+		fmt.Sprintf("Execution time: %s", elapsed),
+	}), nil
+}
+
+// refineJoins settles the algorithm of every join in the plan the way
+// opening it would, so that EXPLAIN shows the join that actually runs.
+// That means reading the inputs of a hash join built on the left. Joins
+// are refined from the top, as reading a join's inputs refines the joins
+// below it.
+func refineJoins(op operator) error {
+	if j, ok := op.(*joinOp); ok {
+		if err := j.refine(); err != nil {
+			return err
+		}
+	}
+	for _, input := range op.inputs() {
+		if err := refineJoins(*input); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// planWrite builds the plan of an UPDATE or DELETE: its rows are found as
+// forEachMatch finds them, through an index where the WHERE clause allows.
+func (e *Engine) planWrite(cmd ExplainCommand) (operator, error) {
+	kind, name, cond := "Update", "", Expr(nil)
+	if cmd.Update != nil {
+		name, cond = cmd.Update.TableName, cmd.Update.Where
+	} else {
+		kind, name, cond = "Delete", cmd.Delete.TableName, cmd.Delete.Where
+	}
+
+	table, ok := e.Tables[name]
+	if !ok {
+		return nil, errors.New("table does not exist")
+	}
+	if cmd.Update != nil {
+		if _, err := bindValues(table, cmd.Update.Set); err != nil {
+			return nil, err
+		}
+	}
+
+	sc := tableScope(table, "")
+	where, err := bindWhere(sc, cond)
+	if err != nil {
+		return nil, err
+	}
+	input, _ := scanPlan(sc, nil, where, nil, false)
+	return &writeOp{kind: kind, table: table, input: input}, nil
+}
+
+// writeOp is the root of an UPDATE or DELETE plan. It only appears in
+// EXPLAIN: the statements themselves collect their rows with forEachMatch.
+type writeOp struct {
+	kind  string
+	table *storage.Table
+	input operator
+}
+
+func (o *writeOp) Open() error { return o.input.Open() }
+
+func (o *writeOp) Next() (storage.Row, bool, error) { return o.input.Next() }
+
+func (o *writeOp) Close() error { return o.input.Close() }
+
+func (o *writeOp) estimate() int { return o.input.estimate() }
+
+func (o *writeOp) describe() string { return o.kind + " on " + o.table.Name }
+
+func (o *writeOp) inputs() []*operator { return []*operator{&o.input} }
+
+func explainLines(root operator, footer []string) []string {
+	var lines []string
+
+	var visit func(op operator, depth int)
+	visit = func(op operator, depth int) {
+		line := op.describe() + fmt.Sprintf("  (rows=%d", op.estimate())
+		if a, ok := op.(*analyzeOp); ok {
+			line += fmt.Sprintf(", actual rows=%d, time=%s", a.rows, a.elapsed)
+		}
+		line += ")"
+
+		if depth > 0 {
+			line = strings.Repeat("   ", depth-1) + "-> " + line
+		}
+		lines = append(lines, line)
+
+		for _, input := range op.inputs() {
+			visit(*input, depth+1)
+		}
+	}
+	visit(root, 0)
+
+	return append(lines, footer...)
+}
+
+// analyzeOp wraps a plan node to count the rows it returns and the time
+// spent in its Open and Next calls.
+type analyzeOp struct {
+	operator
+	rows    int
+	elapsed time.Duration
+}
+
+// instrument wraps *op and every node below it in an analyzeOp.
+func instrument(op *operator) {
+	inner := *op
+	*op = &analyzeOp{operator: inner}
+	for _, input := range inner.inputs() {
+		instrument(input)
+	}
+}
+
+func (a *analyzeOp) Open() error {
+	start := time.Now()
+	err := a.operator.Open()
+	a.elapsed += time.Since(start)
+	return err
+}
+
+func (a *analyzeOp) Next() (row storage.Row, ok bool, err error) {
+	start := time.Now()
+	row, ok, err = a.operator.Next()
+	a.elapsed += time.Since(start)
+	if ok {
+		a.rows++
+	}
+	return row, ok, err
+}
@@ -0,0 +1,173 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func explain(t *testing.T, e *Engine, sql string) []string {
+	t.Helper()
+	tokens, err := Tokenize(sql)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd, err := NewParser(tokens).Parse()
+	if err != nil {
+		t.Fatalf("%s: %v", sql, err)
+	}
+	lines, err := e.Explain(*cmd.(*ExplainCommand))
+	if err != nil {
+		t.Fatalf("%s: %v", sql, err)
+	}
+	return lines
+}
+
+// planOf strips the row estimates and indentation from EXPLAIN lines.
+func planOf(lines []string) []string {
+	plan := make([]string, len(lines))
+	for i, line := range lines {
+		line = strings.TrimLeft(line, " ->")
+		line, _, _ = strings.Cut(line, "  (")
+		plan[i] = line
+	}
+	return plan
+}
+
+func TestExplainSelect(t *testing.T) {
+	e := NewEngine()
+	mustRun(t, e, "CREATE TABLE t (id INT PRIMARY KEY, v INT)")
+	for i := 0; i < 20; i++ {
+		mustRun(t, e, fmt.Sprintf("INSERT INTO t (id, v) VALUES (%d, %d)", i, i%4))
+	}
+
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"EXPLAIN SELECT id FROM t WHERE id = 3", []string{
+			"Project: id", "Filter: id = 3", "Index Scan on t using primary key (id), 1 key(s)",
+		}},
+		{"EXPLAIN SELECT v FROM t WHERE v > 1 ORDER BY v LIMIT 2", []string{
+			"Project: v", "Limit: 2", "Top-N Sort (keep 2): v", "Filter: v > 1", "Seq Scan on t",
+		}},
+		{"EXPLAIN SELECT v, COUNT(*) FROM t GROUP BY v", []string{
+			"Project: v, COUNT(*)", "Hash Aggregate: COUNT(*) group by v", "Seq Scan on t",
+		}},
+	}
+	for _, tt := range tests {
+		if got := planOf(explain(t, e, tt.sql)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.sql, got, tt.want)
+		}
+	}
+
+	lines := explain(t, e, "EXPLAIN ANALYZE SELECT id FROM t WHERE v = 1")
+	if !strings.Contains(lines[1], "Filter: v = 1  (rows=7, actual rows=5,") {
+		t.Errorf("EXPLAIN ANALYZE filter line is %q, want 5 actual rows", lines[1])
+	}
+	if lines[len(lines)-2] != "Rows: 5" {
+		t.Errorf("EXPLAIN ANALYZE footer is %q, want Rows: 5", lines[len(lines)-2:])
+	}
+}
+
+// rowEstimates pulls the planner's row estimate out of each EXPLAIN line.
+func rowEstimates(t *testing.T, lines []string) []int {
+	t.Helper()
+	var rows []int
+	for _, line := range lines {
+		_, est, ok := strings.Cut(line, "(rows=")
+		if !ok {
+			continue
+		}
+		var n int
+		if _, err := fmt.Sscanf(est, "%d", &n); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+		rows = append(rows, n)
+	}
+	return rows
+}
+
+// A filter or a GROUP BY is estimated to return fewer rows than it reads.
+func TestExplainEstimates(t *testing.T) {
+	e := NewEngine()
+	mustRun(t, e, "CREATE TABLE t (id INT PRIMARY KEY, v INT)")
+	for i := 0; i < 60; i++ {
+		mustRun(t, e, fmt.Sprintf("INSERT INTO t (id, v) VALUES (%d, %d)", i, i%4))
+	}
+
+	tests := []struct {
+		sql  string
+		want []int
+	}{
+		{"EXPLAIN SELECT id FROM t", []int{60, 60}},
+		{"EXPLAIN SELECT id FROM t WHERE v = 1", []int{20, 20, 60}},
+		{"EXPLAIN SELECT id FROM t WHERE id = 3", []int{1, 1, 1}},
+		{"EXPLAIN SELECT COUNT(*) FROM t WHERE v = 1", []int{1, 1, 20, 60}},
+		{"EXPLAIN SELECT v, COUNT(*) FROM t GROUP BY v", []int{6, 6, 60}},
+		{"EXPLAIN SELECT v FROM t GROUP BY v HAVING COUNT(*) > 1", []int{2, 2, 6, 60}},
+		{"EXPLAIN SELECT v, COUNT(*) FROM t WHERE id < 10 GROUP BY v", []int{1, 1, 7, 20}},
+	}
+	for _, tt := range tests {
+		if got := rowEstimates(t, explain(t, e, tt.sql)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: estimates %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestExplainWrite(t *testing.T) {
+	e := NewEngine()
+	mustRun(t, e, "CREATE TABLE t (id INT PRIMARY KEY, v INT)")
+	for i := 0; i < 20; i++ {
+		mustRun(t, e, fmt.Sprintf("INSERT INTO t (id, v) VALUES (%d, %d)", i, i))
+	}
+
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"EXPLAIN UPDATE t SET v = 0 WHERE id = 3", []string{
+			"Update on t", "Filter: id = 3", "Index Scan on t using primary key (id), 1 key(s)",
+		}},
+		{"EXPLAIN DELETE FROM t WHERE id < 5", []string{
+			"Delete on t", "Filter: id < 5", "Index Range Scan on t using primary key (id): id < 5",
+		}},
+		{"EXPLAIN DELETE FROM t WHERE v = 1", []string{
+			"Delete on t", "Filter: v = 1", "Seq Scan on t",
+		}},
+	}
+	for _, tt := range tests {
+		if got := planOf(explain(t, e, tt.sql)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.sql, got, tt.want)
+		}
+	}
+
+	// EXPLAIN changes nothing.
+	if got := query(t, e, "SELECT COUNT(*) FROM t"); !slices.Equal(got, []string{"20"}) {
+		t.Errorf("table has %v rows after EXPLAIN, want 20", got)
+	}
+}
+
+// Plain EXPLAIN shows the join algorithm that runs, including the switch
+// from a hash join to a merge join made once the inputs are read.
+func TestExplainRefinedJoin(t *testing.T) {
+	e := NewEngine()
+	mustRun(t, e,
+		"CREATE TABLE a (id INT PRIMARY KEY, v INT)",
+		"CREATE TABLE b (id INT PRIMARY KEY, w INT)",
+	)
+	for i := 0; i < 60; i++ {
+		if i < 30 {
+			mustRun(t, e, fmt.Sprintf("INSERT INTO a (id, v) VALUES (%d, %d)", i, i))
+		}
+		mustRun(t, e, fmt.Sprintf("INSERT INTO b (id, w) VALUES (%d, %d)", i, i))
+	}
+
+	const sql = "SELECT * FROM a JOIN b ON a.id = b.id"
+	plain := planOf(explain(t, e, "EXPLAIN "+sql))
+	analyzed := planOf(explain(t, e, "EXPLAIN ANALYZE "+sql))
+	if plain[1] != "Merge Join (INNER): a.id = b.id" || plain[1] != analyzed[1] {
+		t.Errorf("EXPLAIN shows %q, EXPLAIN ANALYZE %q", plain[1], analyzed[1])
+	}
+}
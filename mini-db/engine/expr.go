@@ -0,0 +1,401 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"fastabiz-mini-rdbms/mini-db/core"
+	"fastabiz-mini-rdbms/mini-db/storage"
+)
+
+// Expr is a node of a WHERE expression tree. Eval returns the value of the
+// expression for one row; for predicates that is true, false or nil, which
+// stands for SQL's UNKNOWN. String renders the expression as SQL, for
+// EXPLAIN.
+type Expr interface {
+	Eval(row storage.Row) (any, error)
+	String() string
+}
+
+type Literal struct {
+	Value any
+}
+
+// ColumnRef names a column as written, possibly qualified (u.name). Binding
+// replaces Name with the key the column has in the rows being evaluated.
+type ColumnRef struct {
+	Name string
+}
+
+// BinaryExpr is a comparison (=, <>, <, <=, >, >=) or AND / OR.
+type BinaryExpr struct {
+	Op    TokenType
+	Left  Expr
+	Right Expr
+}
+
+type NotExpr struct {
+	Expr Expr
+}
+
+type IsNullExpr struct {
+	Expr Expr
+	Not  bool
+}
+
+type InExpr struct {
+	Expr Expr
+	List []Expr
+	Not  bool
+}
+
+type BetweenExpr struct {
+	Expr Expr
+	Low  Expr
+	High Expr
+	Not  bool
+}
+
+type LikeExpr struct {
+	Expr    Expr
+	Pattern Expr
+	Not     bool
+}
+
+func (l *Literal) Eval(storage.Row) (any, error) {
+	return l.Value, nil
+}
+
+func (c *ColumnRef) Eval(row storage.Row) (any, error) {
+	val, ok := row[c.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown column %s", c.Name)
+	}
+	return val, nil
+}
+
+func (b *BinaryExpr) Eval(row storage.Row) (any, error) {
+	left, err := b.Left.Eval(row)
+	if err != nil {
+		return nil, err
+	}
+
+	// AND and OR can settle on one side alone: FALSE AND x is FALSE and
+	// TRUE OR x is TRUE, even when x is UNKNOWN.
+	switch b.Op {
+	case AND:
+		if left == false {
+			return false, nil
+		}
+	case OR:
+		if left == true {
+			return true, nil
+		}
+	}
+
+	right, err := b.Right.Eval(row)
+	if err != nil {
+		return nil, err
+	}
+
+	switch b.Op {
+	case AND:
+		return and3(left, right)
+	case OR:
+		return or3(left, right)
+	}
+
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	c, err := core.Compare(left, right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch b.Op {
+	case EQ:
+		return c == 0, nil
+	case NEQ:
+		return c != 0, nil
+	case LT:
+		return c < 0, nil
+	case LTE:
+		return c <= 0, nil
+	case GT:
+		return c > 0, nil
+	case GTE:
+		return c >= 0, nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", b.Op)
+}
+
+func (n *NotExpr) Eval(row storage.Row) (any, error) {
+	val, err := n.Expr.Eval(row)
+	if err != nil {
+		return nil, err
+	}
+	return not3(val)
+}
+
+func (i *IsNullExpr) Eval(row storage.Row) (any, error) {
+	val, err := i.Expr.Eval(row)
+	if err != nil {
+		return nil, err
+	}
+	return (val == nil) != i.Not, nil
+}
+
+// Eval follows SQL: x IN (a, b) is x = a OR x = b, so a NULL in the list
+// turns a miss into UNKNOWN rather than FALSE.
+func (in *InExpr) Eval(row storage.Row) (any, error) {
+	val, err := in.Expr.Eval(row)
+	if err != nil || val == nil {
+		return nil, err
+	}
+
+	var result any = false
+	for _, item := range in.List {
+		v, err := item.Eval(row)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			result = nil
+			continue
+		}
+
+		c, err := core.Compare(val, v)
+		if err != nil {
+			return nil, err
+		}
+		if c == 0 {
+			result = true
+			break
+		}
+	}
+
+	if in.Not {
+		return not3(result)
+	}
+	return result, nil
+}
+
+func (b *BetweenExpr) Eval(row storage.Row) (any, error) {
+	ge := &BinaryExpr{Op: GTE, Left: b.Expr, Right: b.Low}
+	le := &BinaryExpr{Op: LTE, Left: b.Expr, Right: b.High}
+
+	result, err := (&BinaryExpr{Op: AND, Left: ge, Right: le}).Eval(row)
+	if err != nil || !b.Not {
+		return result, err
+	}
+	return not3(result)
+}
+
+func (l *LikeExpr) Eval(row storage.Row) (any, error) {
+	val, err := l.Expr.Eval(row)
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := l.Pattern.Eval(row)
+	if err != nil {
+		return nil, err
+	}
+	if val == nil || pattern == nil {
+		return nil, nil
+	}
+
+	s, ok1 := val.(string)
+	p, ok2 := pattern.(string)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("LIKE needs TEXT operands, got %s and %s", core.TypeName(val), core.TypeName(pattern))
+	}
+
+	return likeMatch(s, p) != l.Not, nil
+}
+
+// likeMatch matches s against a LIKE pattern: % is any run of characters,
+// _ exactly one.
+func likeMatch(s, pattern string) bool {
+	str, pat := []rune(s), []rune(pattern)
+
+	// Classic wildcard matching with backtracking to the last %.
+	si, pi := 0, 0
+	starPi, starSi := -1, 0
+
+	for si < len(str) {
+		switch {
+		case pi < len(pat) && (pat[pi] == '_' || pat[pi] == str[si]):
+			si++
+			pi++
+		case pi < len(pat) && pat[pi] == '%':
+			starPi, starSi = pi, si
+			pi++
+		case starPi >= 0:
+			starSi++
+			si, pi = starSi, starPi+1
+		default:
+			return false
+		}
+	}
+
+	for pi < len(pat) && pat[pi] == '%' {
+		pi++
+	}
+	return pi == len(pat)
+}
+
+func and3(a, b any) (any, error) {
+	x, err := truth(a)
+	if err != nil {
+		return nil, err
+	}
+	y, err := truth(b)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case x == false || y == false:
+		return false, nil
+	case x == nil || y == nil:
+		return nil, nil
+	}
+	return true, nil
+}
+
+func or3(a, b any) (any, error) {
+	x, err := truth(a)
+	if err != nil {
+		return nil, err
+	}
+	y, err := truth(b)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case x == true || y == true:
+		return true, nil
+	case x == nil || y == nil:
+		return nil, nil
+	}
+	return false, nil
+}
+
+func not3(a any) (any, error) {
+	x, err := truth(a)
+	if err != nil || x == nil {
+		return nil, err
+	}
+	return !x.(bool), nil
+}
+
+// truth checks that v is a truth value: true, false or nil (UNKNOWN).
+func truth(v any) (any, error) {
+	switch v.(type) {
+	case nil, bool:
+		return v, nil
+	}
+	return nil, fmt.Errorf("expected a BOOLEAN condition, got %s", core.TypeName(v))
+}
+
+// walkExpr calls fn for expr and every expression nested in it, stopping
+// at the first error.
+func walkExpr(expr Expr, fn func(Expr) error) error {
+	if expr == nil {
+		return nil
+	}
+	if err := fn(expr); err != nil {
+		return err
+	}
+
+	var children []Expr
+	switch x := expr.(type) {
+	case *BinaryExpr:
+		children = []Expr{x.Left, x.Right}
+	case *NotExpr:
+		children = []Expr{x.Expr}
+	case *IsNullExpr:
+		children = []Expr{x.Expr}
+	case *InExpr:
+		children = append([]Expr{x.Expr}, x.List...)
+	case *BetweenExpr:
+		children = []Expr{x.Expr, x.Low, x.High}
+	case *LikeExpr:
+		children = []Expr{x.Expr, x.Pattern}
+	}
+
+	for _, child := range children {
+		if err := walkExpr(child, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Literal) String() string {
+	switch v := l.Value.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case core.Date:
+		return "DATE '" + v.String() + "'"
+	case core.Timestamp:
+		return "TIMESTAMP '" + v.String() + "'"
+	}
+	return fmt.Sprint(l.Value)
+}
+
+func (c *ColumnRef) String() string { return c.Name }
+
+// String puts parentheses around an OR inside an AND, where they are
+// needed.
+func (b *BinaryExpr) String() string {
+	operand := func(e Expr) string {
+		if x, ok := e.(*BinaryExpr); ok && b.Op == AND && x.Op == OR {
+			return "(" + x.String() + ")"
+		}
+		return e.String()
+	}
+	return fmt.Sprintf("%s %s %s", operand(b.Left), b.Op, operand(b.Right))
+}
+
+func (n *NotExpr) String() string { return "NOT (" + n.Expr.String() + ")" }
+
+func (i *IsNullExpr) String() string {
+	if i.Not {
+		return i.Expr.String() + " IS NOT NULL"
+	}
+	return i.Expr.String() + " IS NULL"
+}
+
+func (in *InExpr) String() string {
+	items := make([]string, len(in.List))
+	for i, item := range in.List {
+		items[i] = item.String()
+	}
+	return fmt.Sprintf("%s %sIN (%s)", in.Expr, notPrefix(in.Not), strings.Join(items, ", "))
+}
+
+func (b *BetweenExpr) String() string {
+	return fmt.Sprintf("%s %sBETWEEN %s AND %s", b.Expr, notPrefix(b.Not), b.Low, b.High)
+}
+
+func (l *LikeExpr) String() string {
+	return fmt.Sprintf("%s %sLIKE %s", l.Expr, notPrefix(l.Not), l.Pattern)
+}
+
+func notPrefix(not bool) string {
+	if not {
+		return "NOT "
+	}
+	return ""
+}
@@ -0,0 +1,198 @@
+package engine
+
+import "fmt"
+
+// Expression grammar, lowest precedence first:
+//
+//	expr      := and (OR and)*
+//	and       := not (AND not)*
+//	not       := NOT not | predicate
+//	predicate := operand [ cmp operand
+//	                     | IS [NOT] NULL
+//	                     | [NOT] IN ( operand, ... )
+//	                     | [NOT] BETWEEN operand AND operand
+//	                     | [NOT] LIKE operand ]
+//	operand   := literal | column | aggregate | ( expr )
+func (p *Parser) parseExpr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.current().Type == OR {
+		p.advance() // OR
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: OR, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *Parser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.current().Type == AND {
+		p.advance() // AND
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: AND, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *Parser) parseNot() (Expr, error) {
+	if p.current().Type == NOT {
+		p.advance() // NOT
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpr{Expr: inner}, nil
+	}
+	return p.parsePredicate()
+}
+
+func (p *Parser) parsePredicate() (Expr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	switch op := p.current().Type; op {
+	case EQ, NEQ, LT, LTE, GT, GTE:
+		p.advance()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &BinaryExpr{Op: op, Left: left, Right: right}, nil
+
+	case IS:
+		p.advance() // IS
+		not := false
+		if p.current().Type == NOT {
+			p.advance() // NOT
+			not = true
+		}
+		if _, err := p.expect(NULL); err != nil {
+			return nil, err
+		}
+		return &IsNullExpr{Expr: left, Not: not}, nil
+	}
+
+	not := false
+	if p.current().Type == NOT {
+		p.advance() // NOT
+		not = true
+	}
+
+	switch p.current().Type {
+	case IN:
+		p.advance() // IN
+		list, err := p.parseExprList()
+		if err != nil {
+			return nil, err
+		}
+		return &InExpr{Expr: left, List: list, Not: not}, nil
+
+	case BETWEEN:
+		p.advance() // BETWEEN
+		low, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(AND); err != nil {
+			return nil, err
+		}
+		high, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &BetweenExpr{Expr: left, Low: low, High: high, Not: not}, nil
+
+	case LIKE:
+		p.advance() // LIKE
+		pattern, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &LikeExpr{Expr: left, Pattern: pattern, Not: not}, nil
+	}
+
+	if not {
+		return nil, fmt.Errorf("expected IN, BETWEEN or LIKE after NOT, got %s", p.current().Type)
+	}
+	return left, nil
+}
+
+func (p *Parser) parseOperand() (Expr, error) {
+	switch p.current().Type {
+	case LPAREN:
+		p.advance() // (
+		inner, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(RPAREN); err != nil {
+			return nil, err
+		}
+		return inner, nil
+
+	case IDENT:
+		if p.peek().Type == LPAREN {
+			agg, err := p.parseAggregate()
+			if err != nil {
+				return nil, err
+			}
+			return &AggregateExpr{Aggregate: agg}, nil
+		}
+		// DATE '...' and TIMESTAMP '...' are literals, not columns.
+		if p.peek().Type != STRING {
+			name, err := p.parseColumnName()
+			if err != nil {
+				return nil, err
+			}
+			return &ColumnRef{Name: name}, nil
+		}
+	}
+
+	val, err := p.parseLiteral()
+	if err != nil {
+		return nil, err
+	}
+	return &Literal{Value: val}, nil
+}
+
+// parseExprList reads a parenthesised, comma-separated list of operands.
+func (p *Parser) parseExprList() ([]Expr, error) {
+	if _, err := p.expect(LPAREN); err != nil {
+		return nil, err
+	}
+
+	var list []Expr
+	for {
+		item, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+
+		if p.current().Type == COMMA {
+			p.advance()
+			continue
+		}
+		break
+	}
+
+	if _, err := p.expect(RPAREN); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
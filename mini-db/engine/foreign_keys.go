@@ -0,0 +1,376 @@
+package engine
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"fastabiz-mini-rdbms/mini-db/index"
+	"fastabiz-mini-rdbms/mini-db/storage"
+)
+
+// newForeignKeys checks the foreign keys of a new table against its own
+// columns and fills in their defaults: a name such as
+// orders_user_id_fkey, and RESTRICT for a missing action.
+func newForeignKeys(cmd CreateTableCommand, columns map[string]storage.Column) ([]storage.ForeignKey, error) {
+	var foreignKeys []storage.ForeignKey
+	names := make(map[string]bool)
+
+	for _, fk := range cmd.ForeignKeys {
+		if fk.Name == "" {
+			fk.Name = cmd.TableName + "_" + fk.Columns[0] + "_fkey"
+		}
+		if names[fk.Name] {
+			return nil, fmt.Errorf("constraint %s already exists", fk.Name)
+		}
+		names[fk.Name] = true
+
+		if fk.OnDelete == "" {
+			fk.OnDelete = storage.RestrictAction
+		}
+		if fk.OnUpdate == "" {
+			fk.OnUpdate = storage.RestrictAction
+		}
+
+		for _, name := range fk.Columns {
+			col, ok := columns[name]
+			if !ok {
+				return nil, fmt.Errorf("column %s does not exist in table %s", name, cmd.TableName)
+			}
+			setNull := fk.OnDelete == storage.SetNullAction || fk.OnUpdate == storage.SetNullAction
+			if setNull && (col.NotNull || col.Primary) {
+				return nil, fmt.Errorf("foreign key %s cannot SET NULL: column %s is NOT NULL", fk.Name, name)
+			}
+		}
+
+		foreignKeys = append(foreignKeys, fk)
+	}
+	return foreignKeys, nil
+}
+
+// resolveForeignKeys checks the foreign keys of a new table against the
+// tables they reference, and fills in the primary key where a key names
+// no columns.
+func (e *Engine) resolveForeignKeys(table *storage.Table) error {
+	for i := range table.ForeignKeys {
+		fk := &table.ForeignKeys[i]
+
+		parent, ok := e.Tables[fk.RefTable]
+		if fk.RefTable == table.Name {
+			parent, ok = table, true
+		}
+		if !ok {
+			return fmt.Errorf("referenced table %s does not exist", fk.RefTable)
+		}
+
+		if len(fk.RefColumns) == 0 {
+			if len(parent.PrimaryKey) == 0 {
+				return fmt.Errorf("table %s has no primary key for foreign key %s to reference", parent.Name, fk.Name)
+			}
+			fk.RefColumns = slices.Clone(parent.PrimaryKey)
+		}
+		if len(fk.RefColumns) != len(fk.Columns) {
+			return fmt.Errorf("foreign key %s has %d column(s) but references %d", fk.Name, len(fk.Columns), len(fk.RefColumns))
+		}
+
+		for j, name := range fk.RefColumns {
+			ref, ok := parent.ColumnMap[name]
+			if !ok {
+				return fmt.Errorf("column %s does not exist in table %s", name, parent.Name)
+			}
+			col := table.ColumnMap[fk.Columns[j]]
+			if !hashCompatible(col.Type, ref.Type) {
+				return fmt.Errorf("foreign key %s: column %s of type %s cannot reference %s.%s of type %s",
+					fk.Name, col.Name, col.TypeString(), parent.Name, ref.Name, ref.TypeString())
+			}
+		}
+
+		if _, ok := referencedIndex(parent, fk.RefColumns); !ok {
+			return fmt.Errorf("foreign key %s: no unique index of table %s covers (%s)",
+				fk.Name, parent.Name, strings.Join(fk.RefColumns, ", "))
+		}
+
+		// A primary key is never updated, so an ON UPDATE action on a
+		// reference to it could never run.
+		primary := !slices.ContainsFunc(fk.RefColumns, func(name string) bool {
+			return !parent.ColumnMap[name].Primary
+		})
+		if primary && fk.OnUpdate != storage.RestrictAction {
+			return fmt.Errorf("foreign key %s cannot ON UPDATE %s: primary key of table %s cannot be updated",
+				fk.Name, fk.OnUpdate, parent.Name)
+		}
+	}
+	return nil
+}
+
+// referencedIndex finds the unique index a foreign key looks its parent
+// rows up in.
+func referencedIndex(parent *storage.Table, cols []string) (tableIndex, bool) {
+	for _, ix := range tableIndexes(parent) {
+		if ix.unique && slices.Equal(ix.columns, cols) {
+			return ix, true
+		}
+	}
+	return tableIndex{}, false
+}
+
+// reference is a foreign key, as seen from the table it refers to.
+type reference struct {
+	child *storage.Table
+	fk    *storage.ForeignKey
+}
+
+// referencing lists the foreign keys that refer to the named table, by
+// table name so that errors come out the same every time.
+func (e *Engine) referencing(parent string) []reference {
+	var refs []reference
+	for _, name := range slices.Sorted(maps.Keys(e.Tables)) {
+		child := e.Tables[name]
+		for i := range child.ForeignKeys {
+			if child.ForeignKeys[i].RefTable == parent {
+				refs = append(refs, reference{child: child, fk: &child.ForeignKeys[i]})
+			}
+		}
+	}
+	return refs
+}
+
+// rowRef names a row of some table.
+type rowRef struct {
+	table string
+	id    storage.RowID
+}
+
+// cascade adds to a statement's changes what the foreign keys referring to
+// the rows it deletes or rekeys ask for: each referencing row is deleted
+// or updated to follow (CASCADE) or has its key set to NULL (SET NULL),
+// and those changes cascade in turn. A RESTRICT key with a referencing
+// row fails the statement.
+func (e *Engine) cascade(changes []rowChange) ([]rowChange, error) {
+	pos := make(map[rowRef]int, len(changes)) // the change to each row
+	queue := make([]int, len(changes))
+	for i, c := range changes {
+		pos[rowRef{c.Table, c.RowID}] = i
+		queue[i] = i
+	}
+	byKey := make(map[*storage.ForeignKey]map[string][]storage.RowID)
+
+	for len(queue) > 0 {
+		c := changes[queue[0]]
+		queue = queue[1:]
+		if c.Kind == changeInsert {
+			continue
+		}
+
+		parent := e.Tables[c.Table]
+		old, ok, err := parent.Store.Get(c.RowID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		for _, ref := range e.referencing(parent.Name) {
+			fk := ref.fk
+			oldKey := indexKey(old, fk.RefColumns)
+			if oldKey.HasNull() {
+				continue
+			}
+
+			action, newKey := fk.OnDelete, index.Key(nil)
+			if c.Kind == changeUpdate {
+				newKey = indexKey(c.Row, fk.RefColumns)
+				if index.CompareKeys(oldKey, newKey) == 0 {
+					continue
+				}
+				action = fk.OnUpdate
+			}
+
+			ids, err := referencingRows(ref, oldKey, byKey)
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range ids {
+				r := rowRef{ref.child.Name, id}
+
+				// A row the statement already changes is taken as it will
+				// be, if it is still there.
+				p, pending := pos[r]
+				var row storage.Row
+				if pending {
+					if changes[p].Kind == changeDelete {
+						continue
+					}
+					row = changes[p].Row
+				} else if row, ok, err = ref.child.Store.Get(id); err != nil {
+					return nil, err
+				} else if !ok {
+					continue
+				}
+				if index.CompareKeys(indexKey(row, fk.Columns), oldKey) != 0 {
+					continue
+				}
+
+				var next rowChange
+				switch {
+				case action == storage.RestrictAction:
+					return nil, fmt.Errorf("update or delete on table %s violates foreign key constraint %s of table %s",
+						parent.Name, fk.Name, ref.child.Name)
+
+				case action == storage.CascadeAction && c.Kind == changeDelete:
+					next = deleteChange(ref.child, id)
+
+				default: // SET NULL, or CASCADE to a new key
+					updated := make(storage.Row, len(row))
+					for col, val := range row {
+						updated[col] = val
+					}
+					for j, name := range fk.Columns {
+						if action == storage.SetNullAction {
+							updated[name] = nil
+							continue
+						}
+						if updated[name], err = ref.child.ColumnMap[name].Coerce(newKey[j]); err != nil {
+							return nil, err
+						}
+					}
+					next = rowChange{Kind: changeUpdate, Table: ref.child.Name, RowID: id, Row: updated}
+				}
+
+				if !pending {
+					p = len(changes)
+					pos[r] = p
+					changes = append(changes, rowChange{})
+				}
+				changes[p] = next
+				queue = append(queue, p)
+			}
+		}
+	}
+	return changes, nil
+}
+
+// referencingRows finds the stored rows of ref's table whose foreign key
+// holds key. An index starting with the key's columns is used when there
+// is one; otherwise the table is scanned once per statement, into byKey.
+func referencingRows(ref reference, key index.Key, byKey map[*storage.ForeignKey]map[string][]storage.RowID) ([]storage.RowID, error) {
+	child, fk := ref.child, ref.fk
+
+	childKey := make(index.Key, len(key))
+	for i, name := range fk.Columns {
+		val, ok := keyValue(child.ColumnMap[name], key[i])
+		if !ok {
+			return nil, nil
+		}
+		childKey[i] = val
+	}
+
+	for _, ix := range tableIndexes(child) {
+		if len(ix.columns) < len(fk.Columns) || !slices.Equal(ix.columns[:len(fk.Columns)], fk.Columns) {
+			continue
+		}
+		var ids []storage.RowID
+		it := ix.index.Scan(index.Range{Low: childKey, High: childKey}, false)
+		for {
+			_, id, ok := it.Next()
+			if !ok {
+				return ids, nil
+			}
+			ids = append(ids, storage.RowID(id))
+		}
+	}
+
+	rows, ok := byKey[fk]
+	if !ok {
+		rows = make(map[string][]storage.RowID)
+		err := storage.ForEach(child.Store, func(id storage.RowID, row storage.Row) error {
+			if k := indexKey(row, fk.Columns); !k.HasNull() {
+				rows[keyString(k)] = append(rows[keyString(k)], id)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		byKey[fk] = rows
+	}
+	return rows[keyString(childKey)], nil
+}
+
+// checkReferences makes sure every foreign key value a statement writes
+// refers to a row, taking the statement's own changes into account: a row
+// it inserts can be referred to, one it deletes cannot.
+func (e *Engine) checkReferences(changes []rowChange) error {
+	touched := make(map[rowRef]bool, len(changes))
+	for _, c := range changes {
+		if c.Kind != changeInsert {
+			touched[rowRef{c.Table, c.RowID}] = true
+		}
+	}
+	written := make(map[*storage.ForeignKey]map[string]bool)
+
+	for _, c := range changes {
+		if c.Kind == changeDelete {
+			continue
+		}
+		table := e.Tables[c.Table]
+
+		for i := range table.ForeignKeys {
+			fk := &table.ForeignKeys[i]
+			key := indexKey(c.Row, fk.Columns)
+			if key.HasNull() {
+				continue
+			}
+			if c.Kind == changeUpdate {
+				old, ok, err := table.Store.Get(c.RowID)
+				if err != nil {
+					return err
+				}
+				if ok && index.CompareKeys(indexKey(old, fk.Columns), key) == 0 {
+					continue
+				}
+			}
+
+			if !e.parentExists(fk, key, changes, touched, written) {
+				return fmt.Errorf("insert or update on table %s violates foreign key constraint %s", table.Name, fk.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// parentExists reports whether a row of fk's parent table holds key, once
+// the statement's changes are made.
+func (e *Engine) parentExists(fk *storage.ForeignKey, key index.Key, changes []rowChange, touched map[rowRef]bool, written map[*storage.ForeignKey]map[string]bool) bool {
+	parent := e.Tables[fk.RefTable]
+
+	parentKey := make(index.Key, len(key))
+	for i, name := range fk.RefColumns {
+		val, ok := keyValue(parent.ColumnMap[name], key[i])
+		if !ok {
+			return false
+		}
+		parentKey[i] = val
+	}
+
+	ix, _ := referencedIndex(parent, fk.RefColumns)
+	for _, id := range ix.index.Lookup(parentKey) {
+		if !touched[rowRef{parent.Name, storage.RowID(id)}] {
+			return true
+		}
+	}
+
+	keys, ok := written[fk]
+	if !ok {
+		keys = make(map[string]bool)
+		for _, c := range changes {
+			if c.Table == parent.Name && c.Kind != changeDelete {
+				keys[keyString(indexKey(c.Row, fk.RefColumns))] = true
+			}
+		}
+		written[fk] = keys
+	}
+	return keys[keyString(parentKey)]
+}
@@ -0,0 +1,158 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestIndexes(t *testing.T) {
+	e := NewEngine()
+	mustRun(t, e, "CREATE TABLE t (id INT PRIMARY KEY, email TEXT, city TEXT)")
+	for i := 0; i < 20; i++ {
+		mustRun(t, e, fmt.Sprintf("INSERT INTO t (id, email, city) VALUES (%d, 'u%d', 'c%d')", i, i, i%3))
+	}
+	mustRun(t, e,
+		"INSERT INTO t (id, email, city) VALUES (20, NULL, NULL)",
+		"INSERT INTO t (id, email, city) VALUES (21, NULL, NULL)",
+		"CREATE INDEX t_city ON t (city)",
+		"CREATE UNIQUE INDEX t_email ON t (email)",
+	)
+
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"SELECT id FROM t WHERE city = 'c2' ORDER BY id", []string{"2", "5", "8", "11", "14", "17"}},
+		{"SELECT id FROM t WHERE city IN ('c0', 'x') AND id > 10 ORDER BY id", []string{"12", "15", "18"}},
+		{"SELECT id FROM t WHERE email = 'u7'", []string{"7"}},
+		{"SELECT id FROM t WHERE city IS NULL ORDER BY id", []string{"20", "21"}},
+	}
+	for _, tt := range tests {
+		if got := query(t, e, tt.sql); !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+
+	if plan := planOf(explain(t, e, "EXPLAIN SELECT id FROM t WHERE email = 'u7'")); plan[2] != "Index Scan on t using t_email (email), 1 key(s)" {
+		t.Errorf("lookup by email runs as %q", plan)
+	}
+
+	// The unique index refuses duplicates but not NULLs, and a row may
+	// keep its own value.
+	for _, sql := range []string{
+		"INSERT INTO t (id, email, city) VALUES (30, 'u1', 'c0')",
+		"UPDATE t SET email = 'u2' WHERE id = 3",
+		"UPDATE t SET email = 'same' WHERE id < 2",
+		"CREATE UNIQUE INDEX t_city2 ON t (city)",
+		"CREATE INDEX t_city ON t (id)",
+		"CREATE INDEX t_x ON t (nope)",
+		"DROP INDEX nope",
+	} {
+		mustFail(t, e, sql)
+	}
+	mustRun(t, e,
+		"INSERT INTO t (id, email, city) VALUES (22, NULL, 'c1')",
+		"UPDATE t SET email = 'u1' WHERE id = 1",
+	)
+
+	// Rows written after CREATE INDEX are found through it too.
+	mustRun(t, e,
+		"UPDATE t SET city = 'c9' WHERE id = 4",
+		"DELETE FROM t WHERE id = 8",
+	)
+	if got := query(t, e, "SELECT id FROM t WHERE city = 'c9'"); !slices.Equal(got, []string{"4"}) {
+		t.Errorf("city c9 finds %q, want [4]", got)
+	}
+	if got := query(t, e, "SELECT id FROM t WHERE city = 'c2' ORDER BY id"); !slices.Equal(got, []string{"2", "5", "11", "14", "17"}) {
+		t.Errorf("city c2 finds %q after delete", got)
+	}
+
+	// Once dropped, the index no longer enforces uniqueness.
+	mustRun(t, e,
+		"DROP INDEX t_email",
+		"INSERT INTO t (id, email, city) VALUES (30, 'u5', 'c0')",
+	)
+	mustFail(t, e, "DROP INDEX t_email")
+	if got := query(t, e, "SELECT id FROM t WHERE email = 'u5' ORDER BY id"); !slices.Equal(got, []string{"5", "30"}) {
+		t.Errorf("email u5 finds %q, want [5 30]", got)
+	}
+}
+
+// Indexes come back after a restart, whether their definition is in the
+// snapshot or only in the log.
+func TestIndexRecovery(t *testing.T) {
+	dir := t.TempDir()
+	e := openEngine(t, dir)
+	mustRun(t, e,
+		"CREATE TABLE t (id INT PRIMARY KEY, email TEXT) ENGINE = disk",
+		"INSERT INTO t (id, email) VALUES (1, 'a')",
+		"CREATE UNIQUE INDEX t_email ON t (email)",
+		"CHECKPOINT",
+		"INSERT INTO t (id, email) VALUES (2, 'b')",
+		"CREATE INDEX t_id_email ON t (id, email)",
+		"DROP INDEX t_id_email",
+	)
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	e = openEngine(t, dir)
+	defer e.Close()
+	checkRecovered(t, e, "SELECT id FROM t WHERE email = 'b'", []string{"2"})
+	mustFail(t, e, "INSERT INTO t (id, email) VALUES (3, 'a')")
+	mustFail(t, e, "DROP INDEX t_id_email")
+	mustRun(t, e, "DROP INDEX t_email", "INSERT INTO t (id, email) VALUES (3, 'a')")
+}
+
+// TestIndexRanges checks that range conditions and ORDER BY are served by
+// walking an index, and that the rows match a plain scan.
+func TestIndexRanges(t *testing.T) {
+	e := NewEngine()
+	mustRun(t, e,
+		"CREATE TABLE t (id INT PRIMARY KEY, city TEXT, age INT)",
+		"CREATE INDEX t_city_age ON t (city, age)",
+	)
+	for i := 0; i < 30; i++ {
+		mustRun(t, e, fmt.Sprintf("INSERT INTO t (id, city, age) VALUES (%d, 'c%d', %d)", i, i%3, i))
+	}
+
+	tests := []struct {
+		sql  string
+		scan string
+		want []string
+	}{
+		{"SELECT id FROM t WHERE id >= 27", "Index Range Scan on t using primary key (id): id >= 27",
+			[]string{"27", "28", "29"}},
+		{"SELECT id FROM t WHERE id BETWEEN 3 AND 5", "Index Range Scan on t using primary key (id): id BETWEEN 3 AND 5",
+			[]string{"3", "4", "5"}},
+		{"SELECT id FROM t WHERE city = 'c1' AND age < 10", "Index Range Scan on t using t_city_age (city, age): city = 'c1' AND age < 10",
+			[]string{"1", "4", "7"}},
+		{"SELECT id FROM t WHERE city = 'c2' ORDER BY age DESC LIMIT 2", "Index Range Scan Backward on t using t_city_age (city, age): city = 'c2'",
+			[]string{"29", "26"}},
+		{"SELECT id FROM t ORDER BY id DESC LIMIT 2", "Index Scan Backward on t using primary key (id)",
+			[]string{"29", "28"}},
+		{"SELECT id FROM t WHERE id < 0", "Index Range Scan on t using primary key (id): id < 0",
+			[]string{}},
+		{"SELECT id FROM t WHERE id > 26.5", "Index Range Scan on t using primary key (id): id > 26.5",
+			[]string{"27", "28", "29"}},
+		{"SELECT id FROM t WHERE id >= 27.0 AND id < 28.5", "Index Range Scan on t using primary key (id): id >= 27.0 AND id < 28.5",
+			[]string{"27", "28"}},
+		{"SELECT id FROM t WHERE id <= 1.5", "Index Range Scan on t using primary key (id): id <= 1.5",
+			[]string{"0", "1"}},
+		{"SELECT id FROM t WHERE id BETWEEN 2.5 AND 4.5", "Index Range Scan on t using primary key (id): id BETWEEN 2.5 AND 4.5",
+			[]string{"3", "4"}},
+		{"SELECT id FROM t WHERE 2.9 > id", "Index Range Scan on t using primary key (id): 2.9 > id",
+			[]string{"0", "1", "2"}},
+		{"SELECT id FROM t WHERE city = 'c0' AND age > 20.25", "Index Range Scan on t using t_city_age (city, age): city = 'c0' AND age > 20.25",
+			[]string{"21", "24", "27"}},
+	}
+	for _, tt := range tests {
+		if got := query(t, e, tt.sql); !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.sql, got, tt.want)
+		}
+		if plan := planOf(explain(t, e, "EXPLAIN "+tt.sql)); !slices.Contains(plan, tt.scan) {
+			t.Errorf("%s runs as %q, want %q", tt.sql, plan, tt.scan)
+		}
+	}
+}
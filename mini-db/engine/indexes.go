@@ -0,0 +1,166 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fastabiz-mini-rdbms/mini-db/core"
+	"fastabiz-mini-rdbms/mini-db/index"
+	"fastabiz-mini-rdbms/mini-db/storage"
+)
+
+// tableIndex is an index as the executors see it: the primary key index or
+// one made with CREATE INDEX.
+type tableIndex struct {
+	name       string // empty for the primary key
+	columns    []string
+	unique     bool
+	constraint bool // made for a UNIQUE constraint
+	index      index.Index
+}
+
+// tableIndexes lists the indexes of table, primary key first.
+func tableIndexes(table *storage.Table) []tableIndex {
+	var indexes []tableIndex
+	if table.PKIndex != nil {
+		indexes = append(indexes, tableIndex{columns: table.PrimaryKey, unique: true, index: table.PKIndex})
+	}
+	for _, ix := range table.Indexes {
+		indexes = append(indexes, tableIndex{name: ix.Name, columns: ix.Columns, unique: ix.Unique, constraint: ix.Constraint, index: ix.Index})
+	}
+	return indexes
+}
+
+func (ix tableIndex) String() string {
+	cols := strings.Join(ix.columns, ", ")
+	if ix.name == "" {
+		return "primary key (" + cols + ")"
+	}
+	return ix.name + " (" + cols + ")"
+}
+
+// indexKey is the key row has in an index over cols. Rows with NULLs are
+// indexed too, so a scan in index order sees every row, but NULL equals
+// nothing: lookups never ask for it and unique indexes let it repeat.
+//
+// Stored values always have their column's type; keys built from literals
+// must be coerced to the column types first (see keyValue).
+func indexKey(row storage.Row, cols []string) index.Key {
+	key := make(index.Key, len(cols))
+	for i, col := range cols {
+		key[i] = row[col]
+	}
+	return key
+}
+
+// keyString encodes a key for use as a map key.
+func keyString(key index.Key) string {
+	var b strings.Builder
+	for _, val := range key {
+		fmt.Fprintf(&b, "%T:%s;", val, strconv.Quote(fmt.Sprint(val)))
+	}
+	return b.String()
+}
+
+// keyValue coerces a value compared with col to col's type. ok is false
+// when no value of that type equals it, so an index lookup cannot find a
+// match.
+func keyValue(col storage.Column, val any) (any, bool) {
+	key, err := col.Coerce(val)
+	if err != nil || !core.Equal(key, val) {
+		return nil, false
+	}
+	return key, true
+}
+
+func newIndex(unique bool) index.Index {
+	return index.NewBTree(unique)
+}
+
+// indexRow adds a row to every index of its table.
+func indexRow(table *storage.Table, rowID storage.RowID, row storage.Row) error {
+	for _, ix := range tableIndexes(table) {
+		if err := ix.index.Insert(indexKey(row, ix.columns), int(rowID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unindexRow removes a row from every index of its table.
+func unindexRow(table *storage.Table, rowID storage.RowID, row storage.Row) {
+	for _, ix := range tableIndexes(table) {
+		ix.index.Delete(indexKey(row, ix.columns), int(rowID))
+	}
+}
+
+// buildIndex fills an empty secondary index from the table's rows.
+func buildIndex(table *storage.Table, ix *storage.SecondaryIndex) error {
+	return storage.ForEach(table.Store, func(id storage.RowID, row storage.Row) error {
+		if err := ix.Index.Insert(indexKey(row, ix.Columns), int(id)); err != nil {
+			return fmt.Errorf("could not create unique index %s: %w", ix.Name, err)
+		}
+		return nil
+	})
+}
+
+func rebuildIndexes(table *storage.Table) error {
+	if len(table.PrimaryKey) > 0 {
+		table.PKIndex = newIndex(true)
+	}
+	for _, ix := range table.Indexes {
+		ix.Index = newIndex(ix.Unique)
+	}
+
+	return storage.ForEach(table.Store, func(id storage.RowID, row storage.Row) error {
+		return indexRow(table, id, row)
+	})
+}
+
+// checkUnique makes sure the rows a statement writes keep the primary key
+// and every unique secondary index unique. Rows the statement itself rewrites or deletes
+// no longer hold their old keys.
+func checkUnique(table *storage.Table, changes []rowChange) error {
+	touched := make(map[storage.RowID]bool, len(changes))
+	for _, c := range changes {
+		if c.Kind != changeInsert {
+			touched[c.RowID] = true
+		}
+	}
+
+	for _, ix := range tableIndexes(table) {
+		if !ix.unique {
+			continue
+		}
+
+		written := make(map[string]bool)
+		for _, c := range changes {
+			if c.Kind == changeDelete {
+				continue
+			}
+			key := indexKey(c.Row, ix.columns)
+			if key.HasNull() {
+				continue
+			}
+
+			duplicate := written[keyString(key)]
+			for _, id := range ix.index.Lookup(key) {
+				if !touched[storage.RowID(id)] {
+					duplicate = true
+				}
+			}
+			switch {
+			case duplicate && ix.name == "":
+				return errors.New("duplicate primary key")
+			case duplicate && ix.constraint:
+				return fmt.Errorf("duplicate key violates unique constraint %s", ix.name)
+			case duplicate:
+				return fmt.Errorf("duplicate key in unique index %s", ix.name)
+			}
+			written[keyString(key)] = true
+		}
+	}
+	return nil
+}
@@ -2,35 +2,66 @@ package engine
 
 import (
 	"errors"
-	"fastabiz-mini-rdbms/mini-db/storage"
+	"time"
 )
 
 func (e *Engine) Insert(cmd InsertCommand) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	table, ok := e.Tables[cmd.TableName]
 	if !ok {
 		return errors.New("table does not exist")
 	}
 
-	row := storage.Row{}
-	for col, val := range cmd.Values {
-		row[col] = val
+	row, err := bindValues(table, cmd.Values)
+	if err != nil {
+		return err
 	}
 
-	rowID := table.NextRowID
+	// Columns left out of the INSERT take their default, or are stored as
+	// explicit NULLs.
+	now := time.Now()
+	for _, col := range table.Columns {
+		if _, ok := row[col.Name]; ok {
+			continue
+		}
+		if col.AutoIncrement {
+			row[col.Name] = int64(table.AutoInc)
+			continue
+		}
+		if row[col.Name], err = columnDefault(col, now); err != nil {
+			return err
+		}
+	}
 
 	// Primary Key enforcement
-	if table.PrimaryKey != "" {
-		pkVal, ok := row[table.PrimaryKey]
-		if !ok {
-			return errors.New("primary key missing")
+	if len(table.PrimaryKey) > 0 {
+		for _, name := range table.PrimaryKey {
+			pkCol := table.ColumnMap[name]
+			if _, ok := cmd.Values[name]; !ok && !pkCol.AutoIncrement && pkCol.Default == nil {
+				return errors.New("primary key missing")
+			}
+			if row[name] == nil {
+				return errors.New("primary key cannot be NULL")
+			}
 		}
-		if err := table.PKIndex.Insert(pkVal, rowID); err != nil {
-			return err
+		if _, exists := table.PKIndex.Get(indexKey(row, table.PrimaryKey)); exists {
+			return errors.New("duplicate primary key")
 		}
 	}
 
-	table.Rows[storage.RowID(rowID)] = row
-	table.NextRowID++
-	
-	return nil
+	rowID, err := table.Store.Allocate(row)
+	if err != nil {
+		return err
+	}
+
+	changes := []rowChange{
+		{Kind: changeInsert, Table: table.Name, RowID: rowID, Row: row},
+	}
+	if err := e.checkChanges(changes); err != nil {
+		return err
+	}
+
+	return e.commit(&logRecord{Kind: recWrite, Changes: changes})
 }
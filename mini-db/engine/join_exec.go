@@ -1,43 +1,92 @@
 package engine
 
 import (
-	"errors"
 	"fastabiz-mini-rdbms/mini-db/storage"
+	"fmt"
 )
 
+// Join runs a single two-table join and returns the combined rows keyed
+// by "table.column". Queries with joins normally go through Select; this
+// is the programmatic shorthand for one equi-join.
 func (e *Engine) Join(spec JoinSpec) ([]storage.JoinedRow, error) {
-	left, ok := e.Tables[spec.LeftTable]
-	if !ok {
-		return nil, errors.New("left table not found")
-	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
 
-	right, ok := e.Tables[spec.RightTable]
-	if !ok {
-		return nil, errors.New("right table not found")
+	join := JoinClause{Type: spec.Type, Table: spec.RightTable}
+	if spec.Type != CrossJoin {
+		join.On = &BinaryExpr{
+			Op:    EQ,
+			Left:  &ColumnRef{Name: spec.LeftTable + "." + spec.LeftColumn},
+			Right: &ColumnRef{Name: spec.RightTable + "." + spec.RightColumn},
+		}
 	}
+	cmd := SelectCommand{TableName: spec.LeftTable, Joins: []JoinClause{join}}
 
-	var results []storage.JoinedRow
+	sc, err := e.fromScope(cmd)
+	if err != nil {
+		return nil, err
+	}
+	joins, err := bindJoins(sc, cmd.Joins)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, lrow := range left.Rows {
-		lval := lrow[spec.LeftColumn]
+	op, _ := scanPlan(sc, joins, nil, nil, false)
+	defer op.Close()
+	if err := op.Open(); err != nil {
+		return nil, err
+	}
 
-		for _, rrow := range right.Rows {
-			rval := rrow[spec.RightColumn]
+	rows, err := drain(op)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]storage.JoinedRow, len(rows))
+	for i, row := range rows {
+		results[i] = storage.JoinedRow(row)
+	}
+	return results, nil
+}
 
-			if lval == rval {
-				merged := make(storage.JoinedRow)
+// boundJoin is a JoinClause with its table looked up and its ON condition
+// bound.
+type boundJoin struct {
+	joinType JoinType
+	src      source
+	on       Expr
+}
 
-				for col, val := range lrow {
-					merged[spec.LeftTable+"."+col] = val
-				}
-				for col, val := range rrow {
-					merged[spec.RightTable+"."+col] = val
-				}
+// bindJoins binds each join's ON condition against the tables joined so
+// far, itself included.
+func bindJoins(sc *scope, joins []JoinClause) ([]boundJoin, error) {
+	bound := make([]boundJoin, len(joins))
+	for i, join := range joins {
+		on, err := bindCondition(sc.prefix(i+2), join.On, "ON")
+		if err != nil {
+			return nil, err
+		}
 
-				results = append(results, merged)
-			}
+		joinType := join.Type
+		if joinType == "" {
+			joinType = InnerJoin
+		}
+		if joinType != CrossJoin && on == nil {
+			return nil, fmt.Errorf("%s JOIN %s needs an ON condition", joinType, join.Table)
 		}
+		bound[i] = boundJoin{joinType: joinType, src: sc.sources[i+1], on: on}
 	}
+	return bound, nil
+}
 
-	return results, nil
+// mergeRows combines a left and a right row; a nil row stands for the NULL
+// padding of an outer join.
+func mergeRows(lrow, rrow storage.Row, leftKeys, rightKeys []string) storage.Row {
+	merged := make(storage.Row, len(leftKeys)+len(rightKeys))
+	for _, key := range leftKeys {
+		merged[key] = lrow[key]
+	}
+	for _, key := range rightKeys {
+		merged[key] = rrow[key]
+	}
+	return merged
 }
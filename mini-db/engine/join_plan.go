@@ -0,0 +1,655 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fastabiz-mini-rdbms/mini-db/core"
+	"fastabiz-mini-rdbms/mini-db/index"
+	"fastabiz-mini-rdbms/mini-db/storage"
+)
+
+type joinAlgorithm string
+
+const (
+	nestedLoop      joinAlgorithm = "nested loop"
+	indexNestedLoop joinAlgorithm = "index nested loop"
+	hashJoin        joinAlgorithm = "hash join"
+	mergeJoin       joinAlgorithm = "merge join"
+)
+
+// tinyJoin is the most row pairs a join compares pair by pair before a
+// hash table or a merge is worth building.
+const tinyJoin = 256
+
+// joinPlan is the planner's choice for one join.
+type joinPlan struct {
+	algorithm joinAlgorithm
+	// Equi-join keys: leftKeys[i] = rightKeys[i] is one conjunct of ON.
+	leftKeys  []string
+	rightKeys []string
+	// buildLeft makes a hash join build its table on the left input.
+	buildLeft bool
+	// An index nested loop looks the values of indexKeys, one left key per
+	// index column, up in index.
+	index     tableIndex
+	indexKeys []string
+}
+
+// equiKeys finds the `left = right` column comparisons among the top-level
+// ANDs of a join condition. The whole condition is still checked on every
+// pair the keys bring together, so any other conjuncts are honoured too.
+// Only pairs whose values hash alike are used: equal types, or INT and
+// DECIMAL, whose values are exact.
+func equiKeys(sc *scope, on Expr, left map[string]bool, right source) (leftKeys, rightKeys []string) {
+	rightSet := make(map[string]bool)
+	for _, key := range sc.keys(right) {
+		rightSet[key] = true
+	}
+
+	var visit func(Expr)
+	visit = func(e Expr) {
+		b, ok := e.(*BinaryExpr)
+		if !ok {
+			return
+		}
+		if b.Op == AND {
+			visit(b.Left)
+			visit(b.Right)
+			return
+		}
+		if b.Op != EQ {
+			return
+		}
+
+		l, lok := b.Left.(*ColumnRef)
+		r, rok := b.Right.(*ColumnRef)
+		if !lok || !rok {
+			return
+		}
+		if left[r.Name] && rightSet[l.Name] {
+			l, r = r, l
+		}
+		if !left[l.Name] || !rightSet[r.Name] || !hashCompatible(sc.columns[l.Name].Type, sc.columns[r.Name].Type) {
+			return
+		}
+		leftKeys = append(leftKeys, l.Name)
+		rightKeys = append(rightKeys, r.Name)
+	}
+	visit(on)
+
+	return leftKeys, rightKeys
+}
+
+func hashCompatible(a, b core.DataType) bool {
+	exact := func(t core.DataType) bool { return t == core.IntType || t == core.DecimalType }
+	return a == b || (exact(a) && exact(b))
+}
+
+// planJoin picks the join algorithm from the input sizes and the indexes
+// on the right table:
+//
+//   - without an equi-join key, or when the inputs are tiny, a nested loop;
+//   - when the right table has an index on its join keys and the left
+//     input is much smaller, probing the index per left row;
+//   - when both inputs already come ordered on the keys, a merge join;
+//   - otherwise a hash join, building on the smaller input.
+//
+// The merge join needs both inputs in hand to check their order, so that
+// choice is made by refinePlan once they have been read. leftRows is the
+// estimated size of the left input.
+func planJoin(sc *scope, join boundJoin, leftRows int, leftKeys []string) joinPlan {
+	inLeft := make(map[string]bool, len(leftKeys))
+	for _, key := range leftKeys {
+		inLeft[key] = true
+	}
+
+	lk, rk := equiKeys(sc, join.on, inLeft, join.src)
+	plan := joinPlan{algorithm: nestedLoop, leftKeys: lk, rightKeys: rk}
+	if len(lk) == 0 {
+		return plan
+	}
+
+	table := join.src.table
+	rightRows := table.Store.Count()
+	if leftRows*rightRows <= tinyJoin {
+		return plan
+	}
+
+	// Probing only finds the right rows some left row matches; RIGHT and
+	// FULL joins also have to report the others.
+	if (join.joinType == InnerJoin || join.joinType == LeftJoin) && leftRows*4 < rightRows {
+		if ix, keys, ok := joinIndex(sc, join.src, lk, rk); ok {
+			plan.algorithm, plan.index, plan.indexKeys = indexNestedLoop, ix, keys
+			return plan
+		}
+	}
+
+	plan.algorithm = hashJoin
+	plan.buildLeft = leftRows < rightRows
+	return plan
+}
+
+// joinIndex finds an index of the right table whose columns are all
+// equi-join keys, preferring a unique one, and returns it with the left
+// keys that feed its columns.
+func joinIndex(sc *scope, src source, leftKeys, rightKeys []string) (tableIndex, []string, bool) {
+	var (
+		found tableIndex
+		keys  []string
+		ok    bool
+	)
+
+next:
+	for _, ix := range tableIndexes(src.table) {
+		ixKeys := make([]string, len(ix.columns))
+		for i, col := range ix.columns {
+			k := slices.Index(rightKeys, sc.key(src, col))
+			if k < 0 {
+				continue next
+			}
+			ixKeys[i] = leftKeys[k]
+		}
+
+		if !ok || (ix.unique && !found.unique) {
+			found, keys, ok = ix, ixKeys, true
+		}
+	}
+	return found, keys, ok
+}
+
+// refinePlan switches a hash join built on the left to a merge join when
+// both inputs turn out to be sorted on the join keys already, which saves
+// building a hash table.
+func refinePlan(plan joinPlan, left, right []storage.Row) joinPlan {
+	if plan.algorithm == hashJoin && plan.buildLeft && sortedOn(left, plan.leftKeys) && sortedOn(right, plan.rightKeys) {
+		plan.algorithm = mergeJoin
+	}
+	return plan
+}
+
+func sortedOn(rows []storage.Row, keys []string) bool {
+	terms := keyOrder(keys)
+	for i := 1; i < len(rows); i++ {
+		c, err := compareRows(terms, rows[i-1], rows[i])
+		if err != nil || c > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func keyOrder(keys []string) []OrderTerm {
+	terms := make([]OrderTerm, len(keys))
+	for i, key := range keys {
+		terms[i] = OrderTerm{Column: key}
+	}
+	return terms
+}
+
+// refine makes the choice Open makes between a hash join built on the
+// left and a merge join, reading both inputs but joining nothing.
+func (o *joinOp) refine() error {
+	if o.plan.algorithm != hashJoin || !o.plan.buildLeft {
+		return nil
+	}
+
+	left, err := readAll(o.left)
+	if err != nil {
+		return err
+	}
+	right, err := readAll(o.right)
+	if err != nil {
+		return err
+	}
+	o.plan = refinePlan(o.plan, left, right)
+	return nil
+}
+
+// readAll opens op, reads all its rows and closes it again.
+func readAll(op operator) ([]storage.Row, error) {
+	defer op.Close()
+	if err := op.Open(); err != nil {
+		return nil, err
+	}
+	return drain(op)
+}
+
+// joinOp joins the rows of its left input to the join's table. The right
+// table is read up front, as the inner side of a nested loop or the build
+// side of a hash join, and the left rows are streamed past it; an index
+// nested loop reads no right rows at all, only looking them up. A hash
+// join built on the left and a merge join need their whole left input
+// first. Outer joins pad the side without a match with NULLs.
+type joinOp struct {
+	left      operator
+	right     operator // scan of the join's table; nil for an index nested loop
+	sc        *scope
+	join      boundJoin
+	plan      joinPlan
+	leftCols  []string // row keys of the left and right inputs
+	rightCols []string
+
+	rightRows    []storage.Row
+	rightMatched []bool
+	table        map[string][]int // hash table on right, for hashJoin
+	leftDone     bool
+	out          []storage.Row // joined rows not yet returned
+}
+
+func newJoinOp(sc *scope, left operator, join boundJoin, leftCols []string) *joinOp {
+	o := &joinOp{
+		left:      left,
+		sc:        sc,
+		join:      join,
+		plan:      planJoin(sc, join, left.estimate(), leftCols),
+		leftCols:  leftCols,
+		rightCols: sc.keys(join.src),
+	}
+	if o.plan.algorithm != indexNestedLoop {
+		o.right = &seqScanOp{sc: sc, src: join.src}
+	}
+	return o
+}
+
+func (o *joinOp) Open() error {
+	o.out, o.leftDone, o.table = nil, false, nil
+	if err := o.left.Open(); err != nil {
+		return err
+	}
+	if o.right == nil {
+		return nil
+	}
+
+	if err := o.right.Open(); err != nil {
+		return err
+	}
+	right, err := drain(o.right)
+	if err != nil {
+		return err
+	}
+	o.rightRows, o.rightMatched = right, make([]bool, len(right))
+
+	if o.plan.algorithm != hashJoin {
+		return nil
+	}
+	if !o.plan.buildLeft {
+		o.table = buildHash(right, o.plan.rightKeys)
+		return nil
+	}
+
+	left, err := drain(o.left)
+	if err != nil {
+		return err
+	}
+	o.leftDone = true
+
+	if o.plan = refinePlan(o.plan, left, right); o.plan.algorithm == mergeJoin {
+		return o.mergeJoin(left, right)
+	}
+	return o.hashJoinLeft(left, right)
+}
+
+func (o *joinOp) Next() (storage.Row, bool, error) {
+	for len(o.out) == 0 {
+		if o.leftDone {
+			return nil, false, nil
+		}
+
+		lrow, ok, err := o.left.Next()
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			o.leftDone = true
+			for i, rrow := range o.rightRows {
+				if !o.rightMatched[i] {
+					o.unmatchedRight(rrow)
+				}
+			}
+			continue
+		}
+
+		if err := o.probe(lrow); err != nil {
+			return nil, false, err
+		}
+	}
+
+	row := o.out[0]
+	o.out = o.out[1:]
+	return row, true, nil
+}
+
+func (o *joinOp) Close() error {
+	o.rightRows, o.rightMatched, o.table, o.out = nil, nil, nil, nil
+	err := o.left.Close()
+	if o.right != nil {
+		if rerr := o.right.Close(); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+// estimate guesses one output row per row of the larger input for an
+// equi-join, and every pair otherwise.
+func (o *joinOp) estimate() int {
+	l, r := o.left.estimate(), o.join.src.table.Store.Count()
+	if len(o.plan.leftKeys) > 0 {
+		return max(l, r)
+	}
+	return l * r
+}
+
+func (o *joinOp) describe() string {
+	var s string
+	switch o.plan.algorithm {
+	case hashJoin:
+		side := "right"
+		if o.plan.buildLeft {
+			side = "left"
+		}
+		s = fmt.Sprintf("Hash Join (%s, build %s)", o.join.joinType, side)
+	case mergeJoin:
+		s = fmt.Sprintf("Merge Join (%s)", o.join.joinType)
+	case indexNestedLoop:
+		s = fmt.Sprintf("Index Nested Loop (%s) using %s of %s",
+			o.join.joinType, o.plan.index, sourceName(o.join.src))
+	default:
+		s = fmt.Sprintf("Nested Loop (%s)", o.join.joinType)
+	}
+	if o.join.on != nil {
+		s += ": " + o.join.on.String()
+	}
+	return s
+}
+
+func (o *joinOp) inputs() []*operator {
+	if o.right == nil {
+		return []*operator{&o.left}
+	}
+	return []*operator{&o.left, &o.right}
+}
+
+func (o *joinOp) padLeft() bool {
+	return o.join.joinType == LeftJoin || o.join.joinType == FullJoin
+}
+
+func (o *joinOp) padRight() bool {
+	return o.join.joinType == RightJoin || o.join.joinType == FullJoin
+}
+
+// try emits lrow+rrow if they satisfy the join condition.
+func (o *joinOp) try(lrow, rrow storage.Row) (bool, error) {
+	merged := mergeRows(lrow, rrow, o.leftCols, o.rightCols)
+	ok, err := matches(o.join.on, merged)
+	if ok {
+		o.out = append(o.out, merged)
+	}
+	return ok, err
+}
+
+func (o *joinOp) unmatchedLeft(lrow storage.Row) {
+	if o.padLeft() {
+		o.out = append(o.out, mergeRows(lrow, nil, o.leftCols, o.rightCols))
+	}
+}
+
+func (o *joinOp) unmatchedRight(rrow storage.Row) {
+	if o.padRight() {
+		o.out = append(o.out, mergeRows(nil, rrow, o.leftCols, o.rightCols))
+	}
+}
+
+// probe joins one left row to the right side: every right row for a
+// nested loop, the rows with the same key for a hash join, or the rows the
+// index finds for an index nested loop.
+func (o *joinOp) probe(lrow storage.Row) error {
+	matched := false
+	tryRight := func(i int) error {
+		ok, err := o.try(lrow, o.rightRows[i])
+		if ok {
+			matched, o.rightMatched[i] = true, true
+		}
+		return err
+	}
+
+	switch o.plan.algorithm {
+	case indexNestedLoop:
+		rrows, err := o.lookup(lrow)
+		if err != nil {
+			return err
+		}
+		for _, rrow := range rrows {
+			ok, err := o.try(lrow, rrow)
+			if err != nil {
+				return err
+			}
+			matched = matched || ok
+		}
+
+	case hashJoin:
+		if key, ok := hashKey(lrow, o.plan.leftKeys); ok {
+			for _, i := range o.table[key] {
+				if err := tryRight(i); err != nil {
+					return err
+				}
+			}
+		}
+
+	default:
+		for i := range o.rightRows {
+			if err := tryRight(i); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !matched {
+		o.unmatchedLeft(lrow)
+	}
+	return nil
+}
+
+// lookup finds the right rows whose index key equals lrow's join keys.
+func (o *joinOp) lookup(lrow storage.Row) ([]storage.Row, error) {
+	table := o.join.src.table
+	ix := o.plan.index
+
+	key := make(index.Key, len(ix.columns))
+	for i, col := range ix.columns {
+		val, ok := keyValue(table.ColumnMap[col], lrow[o.plan.indexKeys[i]])
+		if !ok {
+			return nil, nil // NULL, or a value the column cannot hold
+		}
+		key[i] = val
+	}
+
+	var rows []storage.Row
+	for _, rowID := range ix.index.Lookup(key) {
+		row, ok, err := table.Store.Get(storage.RowID(rowID))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			rows = append(rows, keyRow(o.sc, o.join.src, row))
+		}
+	}
+	return rows, nil
+}
+
+// buildHash indexes rows by their key values. Rows with a NULL key never
+// match, so they are left out.
+func buildHash(rows []storage.Row, keys []string) map[string][]int {
+	table := make(map[string][]int, len(rows))
+	for i, row := range rows {
+		if key, ok := hashKey(row, keys); ok {
+			table[key] = append(table[key], i)
+		}
+	}
+	return table
+}
+
+// hashJoinLeft builds the hash table on the left input, the smaller one,
+// and probes it with the right rows.
+func (o *joinOp) hashJoinLeft(left, right []storage.Row) error {
+	table := buildHash(left, o.plan.leftKeys)
+	leftMatched := make([]bool, len(left))
+
+	for _, rrow := range right {
+		matched := false
+
+		if key, ok := hashKey(rrow, o.plan.rightKeys); ok {
+			for _, i := range table[key] {
+				ok, err := o.try(left[i], rrow)
+				if err != nil {
+					return err
+				}
+				if ok {
+					matched, leftMatched[i] = true, true
+				}
+			}
+		}
+
+		if !matched {
+			o.unmatchedRight(rrow)
+		}
+	}
+
+	for i, lrow := range left {
+		if !leftMatched[i] {
+			o.unmatchedLeft(lrow)
+		}
+	}
+	return nil
+}
+
+// hashKey encodes the key columns of row so that equal values, INT 2 and
+// DECIMAL 2.00 included, get equal keys. ok is false if a key is NULL.
+func hashKey(row storage.Row, keys []string) (string, bool) {
+	var b strings.Builder
+	for _, key := range keys {
+		val := row[key]
+		switch v := val.(type) {
+		case nil:
+			return "", false
+		case int64:
+			val = core.NewDecimal(v, 0)
+		case core.Decimal:
+			val = v.Normalize()
+		}
+		fmt.Fprintf(&b, "%T:%s;", val, strconv.Quote(fmt.Sprint(val)))
+	}
+	return b.String(), true
+}
+
+// mergeJoin walks both inputs, sorted on the join keys, in step. Runs of
+// equal keys on either side are joined pair by pair. The inputs are sorted
+// here if they are not already.
+func (o *joinOp) mergeJoin(left, right []storage.Row) error {
+	plan := o.plan
+
+	var err error
+	if !sortedOn(left, plan.leftKeys) {
+		if left, err = sortRows(left, keyOrder(plan.leftKeys)); err != nil {
+			return err
+		}
+	}
+	if !sortedOn(right, plan.rightKeys) {
+		if right, err = sortRows(right, keyOrder(plan.rightKeys)); err != nil {
+			return err
+		}
+	}
+
+	// NULL keys sort first and match nothing.
+	i, j := 0, 0
+	for i < len(left) && hasNullKey(left[i], plan.leftKeys) {
+		o.unmatchedLeft(left[i])
+		i++
+	}
+	for j < len(right) && hasNullKey(right[j], plan.rightKeys) {
+		o.unmatchedRight(right[j])
+		j++
+	}
+
+	for i < len(left) && j < len(right) {
+		c, err := compareKeys(left[i], plan.leftKeys, right[j], plan.rightKeys)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case c < 0:
+			o.unmatchedLeft(left[i])
+			i++
+		case c > 0:
+			o.unmatchedRight(right[j])
+			j++
+		default:
+			iEnd, jEnd := i+1, j+1
+			for iEnd < len(left) && equalKeys(left[i], left[iEnd], plan.leftKeys) {
+				iEnd++
+			}
+			for jEnd < len(right) && equalKeys(right[j], right[jEnd], plan.rightKeys) {
+				jEnd++
+			}
+
+			rightMatched := make([]bool, jEnd-j)
+			for _, lrow := range left[i:iEnd] {
+				matched := false
+				for k, rrow := range right[j:jEnd] {
+					ok, err := o.try(lrow, rrow)
+					if err != nil {
+						return err
+					}
+					if ok {
+						matched, rightMatched[k] = true, true
+					}
+				}
+				if !matched {
+					o.unmatchedLeft(lrow)
+				}
+			}
+			for k, rrow := range right[j:jEnd] {
+				if !rightMatched[k] {
+					o.unmatchedRight(rrow)
+				}
+			}
+			i, j = iEnd, jEnd
+		}
+	}
+
+	for ; i < len(left); i++ {
+		o.unmatchedLeft(left[i])
+	}
+	for ; j < len(right); j++ {
+		o.unmatchedRight(right[j])
+	}
+	return nil
+}
+
+func hasNullKey(row storage.Row, keys []string) bool {
+	for _, key := range keys {
+		if row[key] == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func compareKeys(a storage.Row, aKeys []string, b storage.Row, bKeys []string) (int, error) {
+	for k := range aKeys {
+		c, err := core.Compare(a[aKeys[k]], b[bKeys[k]])
+		if err != nil || c != 0 {
+			return c, err
+		}
+	}
+	return 0, nil
+}
+
+func equalKeys(a, b storage.Row, keys []string) bool {
+	c, err := compareKeys(a, keys, b, keys)
+	return err == nil && c == 0
+}
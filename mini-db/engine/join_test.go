@@ -0,0 +1,212 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// joinRow is a row of the test tables a and b: an id and a join key k,
+// where a nil k is NULL.
+type joinRow struct {
+	id int
+	k  any
+}
+
+func joinRows(from, to, mod, nullEvery int) []joinRow {
+	var rows []joinRow
+	for id := from; id < to; id++ {
+		var k any = int64(id % mod)
+		if id%nullEvery == 0 {
+			k = nil
+		}
+		rows = append(rows, joinRow{id, k})
+	}
+	return rows
+}
+
+// joinModel computes `SELECT a.id, b.id FROM a <joinType> JOIN b ON
+// a.<col> = b.<col>` pair by pair, in the format query returns.
+func joinModel(a, b []joinRow, col, joinType string) []string {
+	key := func(r joinRow) any {
+		if col == "id" {
+			return int64(r.id)
+		}
+		return r.k
+	}
+
+	var rows []string
+	matchedB := make(map[int]bool)
+	for _, l := range a {
+		matched := false
+		for _, r := range b {
+			if joinType == "CROSS" || key(l) != nil && key(l) == key(r) {
+				rows = append(rows, fmt.Sprint(l.id, " ", r.id))
+				matched = true
+				matchedB[r.id] = true
+			}
+		}
+		if !matched && (joinType == "LEFT" || joinType == "FULL") {
+			rows = append(rows, fmt.Sprint(l.id, " <nil>"))
+		}
+	}
+	if joinType == "RIGHT" || joinType == "FULL" {
+		for _, r := range b {
+			if !matchedB[r.id] {
+				rows = append(rows, fmt.Sprint("<nil> ", r.id))
+			}
+		}
+	}
+	slices.Sort(rows)
+	return rows
+}
+
+// insertJoinRows creates the test tables a and b and fills them.
+func insertJoinRows(t *testing.T, e *Engine, a, b []joinRow) {
+	t.Helper()
+	mustRun(t, e,
+		"CREATE TABLE a (id INT PRIMARY KEY, k INT)",
+		"CREATE TABLE b (id INT PRIMARY KEY, k INT)",
+	)
+	for _, table := range []struct {
+		name string
+		rows []joinRow
+	}{{"a", a}, {"b", b}} {
+		for _, r := range table.rows {
+			k := "NULL"
+			if r.k != nil {
+				k = fmt.Sprint(r.k)
+			}
+			mustRun(t, e, fmt.Sprintf("INSERT INTO %s (id, k) VALUES (%d, %s)", table.name, r.id, k))
+		}
+	}
+}
+
+// joinPairs runs `SELECT a.id, b.id FROM a <joinType> JOIN b ON a.<col> =
+// b.<col>` and returns the pairs in the format joinModel uses.
+func joinPairs(t *testing.T, e *Engine, col, joinType string) []string {
+	t.Helper()
+	sql := fmt.Sprintf("SELECT a.id, b.id FROM a %s JOIN b ON a.%s = b.%s", joinType, col, col)
+	if joinType == "CROSS" {
+		sql = "SELECT a.id, b.id FROM a CROSS JOIN b"
+	}
+	pairs := query(t, e, sql)
+	slices.Sort(pairs)
+	return pairs
+}
+
+// TestJoinTypes checks every join type, on a key with duplicates and
+// NULLs and on the primary key, against a pair-by-pair model.
+func TestJoinTypes(t *testing.T) {
+	a, b := joinRows(0, 10, 3, 4), joinRows(5, 20, 4, 7)
+	e := NewEngine()
+	insertJoinRows(t, e, a, b)
+
+	for _, col := range []string{"k", "id"} {
+		for _, joinType := range []string{"INNER", "LEFT", "LEFT OUTER", "RIGHT", "FULL OUTER", "CROSS"} {
+			got := joinPairs(t, e, col, joinType)
+			if want := joinModel(a, b, col, strings.Fields(joinType)[0]); !slices.Equal(got, want) {
+				t.Errorf("%s JOIN on %s: %d rows, want %d\ngot:  %.300q\nwant: %.300q", joinType, col, len(got), len(want), got, want)
+			}
+		}
+	}
+
+	for _, sql := range []string{
+		"SELECT a.id FROM a LEFT b ON a.k = b.k",
+		"SELECT a.id FROM a CROSS JOIN b ON a.k = b.k",
+		"SELECT a.id FROM a JOIN b ON a.k = c.k",
+		"SELECT a.id FROM a JOIN b ON a.nope = b.k",
+		"SELECT id FROM a JOIN b ON a.k = b.k",
+	} {
+		mustFail(t, e, sql)
+	}
+}
+
+// TestJoinAlgorithms sizes and indexes the tables so the planner picks
+// each join algorithm in turn, and checks every join type against a
+// pair-by-pair model.
+func TestJoinAlgorithms(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		a, b      []joinRow
+		col       string
+		index     bool // index b.k
+	}{
+		{"Nested Loop", joinRows(0, 10, 3, 4), joinRows(0, 20, 4, 7), "k", false},
+		{"Hash Join", joinRows(0, 40, 7, 5), joinRows(0, 60, 9, 13), "k", false},
+		{"Merge Join", joinRows(0, 40, 7, 5), joinRows(20, 100, 9, 13), "id", false},
+		{"Index Nested Loop", joinRows(0, 10, 30, 4), joinRows(0, 200, 40, 7), "id", false},
+		{"Index Nested Loop", joinRows(0, 10, 30, 4), joinRows(0, 200, 40, 7), "k", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm+" on "+tt.col, func(t *testing.T) {
+			e := NewEngine()
+			insertJoinRows(t, e, tt.a, tt.b)
+			if tt.index {
+				mustRun(t, e, "CREATE INDEX b_k ON b (k)")
+			}
+
+			for _, joinType := range []string{"INNER", "LEFT", "RIGHT", "FULL", "CROSS"} {
+				got := joinPairs(t, e, tt.col, joinType)
+				if want := joinModel(tt.a, tt.b, tt.col, joinType); !slices.Equal(got, want) {
+					t.Errorf("%s JOIN on %s: %d rows, want %d\ngot:  %.300q\nwant: %.300q", joinType, tt.col, len(got), len(want), got, want)
+				}
+			}
+
+			sql := fmt.Sprintf("EXPLAIN SELECT a.id, b.id FROM a JOIN b ON a.%s = b.%s", tt.col, tt.col)
+			plan := planOf(explain(t, e, sql))
+			if !slices.ContainsFunc(plan, func(line string) bool { return strings.HasPrefix(line, tt.algorithm+" ") }) {
+				t.Errorf("%s runs as %q, want a %s", sql, plan, tt.algorithm)
+			}
+		})
+	}
+}
+
+// TestJoinChain joins three tables, one of them twice under aliases.
+func TestJoinChain(t *testing.T) {
+	e := NewEngine()
+	mustRun(t, e,
+		"CREATE TABLE users (id INT PRIMARY KEY, name TEXT, manager_id INT)",
+		"CREATE TABLE orders (id INT PRIMARY KEY, user_id INT)",
+		"INSERT INTO users (id, name, manager_id) VALUES (1, 'ann', NULL)",
+		"INSERT INTO users (id, name, manager_id) VALUES (2, 'bob', 1)",
+		"INSERT INTO users (id, name, manager_id) VALUES (3, 'cy', 1)",
+		"INSERT INTO orders (id, user_id) VALUES (10, 2)",
+		"INSERT INTO orders (id, user_id) VALUES (11, 2)",
+		"INSERT INTO orders (id, user_id) VALUES (12, 1)",
+	)
+
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{`SELECT u.name, m.name, o.id FROM users u
+			LEFT JOIN users m ON u.manager_id = m.id
+			JOIN orders o ON o.user_id = u.id
+			ORDER BY o.id`, []string{"bob ann 10", "bob ann 11", "ann <nil> 12"}},
+		{`SELECT u.name, o.id FROM users AS u
+			LEFT JOIN orders AS o ON o.user_id = u.id AND o.id > 10
+			ORDER BY u.id, o.id`, []string{"ann 12", "bob 11", "cy <nil>"}},
+		{`SELECT users.name, COUNT(orders.id) FROM users
+			LEFT JOIN orders ON orders.user_id = users.id
+			GROUP BY users.name ORDER BY users.name`, []string{"ann 1", "bob 2", "cy 0"}},
+		{`SELECT name, user_id FROM users JOIN orders ON user_id = users.id
+			WHERE orders.id = 12`, []string{"ann 1"}},
+	}
+	for _, tt := range tests {
+		if got := query(t, e, tt.sql); !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+
+	for _, sql := range []string{
+		"SELECT id FROM users u JOIN orders o ON o.user_id = u.id",
+		"SELECT users.name FROM users u",
+		"SELECT u.name FROM users u JOIN users u ON u.id = u.manager_id",
+		"SELECT x.name FROM users u",
+	} {
+		mustFail(t, e, sql)
+	}
+}
@@ -0,0 +1,412 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"fastabiz-mini-rdbms/mini-db/storage"
+)
+
+// operator is one node of a physical query plan. SELECT builds a tree of
+// them and pulls rows from the root: Open prepares a node and its inputs,
+// Next returns the next row (ok is false once there are none left) and
+// Close releases whatever the node holds. Most operators work a row at a
+// time, so a LIMIT stops the scans below it as soon as it has its rows;
+// Sort, Aggregate and the build side of a join have to read their whole
+// input first.
+type operator interface {
+	Open() error
+	Next() (row storage.Row, ok bool, err error)
+	Close() error
+
+	// estimate is the planner's guess at how many rows Next will return.
+	estimate() int
+
+	// describe is the node's line in EXPLAIN, and inputs the nodes it
+	// reads from.
+	describe() string
+	inputs() []*operator
+}
+
+// drain reads every remaining row of an open operator.
+func drain(op operator) ([]storage.Row, error) {
+	var rows []storage.Row
+	for {
+		row, ok, err := op.Next()
+		if err != nil || !ok {
+			return rows, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+// seqScanOp reads every row of a table, keyed as the scope keys its
+// columns.
+type seqScanOp struct {
+	sc  *scope
+	src source
+	it  storage.RowIterator
+}
+
+func (o *seqScanOp) Open() error {
+	o.it = o.src.table.Store.Scan()
+	return nil
+}
+
+func (o *seqScanOp) Next() (storage.Row, bool, error) {
+	_, row, ok := o.it.Next()
+	if !ok {
+		return nil, false, o.it.Err()
+	}
+	return keyRow(o.sc, o.src, row), true, nil
+}
+
+func (o *seqScanOp) Close() error {
+	if o.it == nil {
+		return nil
+	}
+	err := o.it.Close()
+	o.it = nil
+	return err
+}
+
+func (o *seqScanOp) estimate() int { return o.src.table.Store.Count() }
+
+func (o *seqScanOp) describe() string { return "Seq Scan on " + sourceName(o.src) }
+
+func (o *seqScanOp) inputs() []*operator { return nil }
+
+// sourceName is how EXPLAIN names a table: "users", or "users u" when it
+// has an alias.
+func sourceName(src source) string {
+	if src.alias != src.table.Name {
+		return src.table.Name + " " + src.alias
+	}
+	return src.table.Name
+}
+
+// keyRow rekeys a stored row by the scope's keys; rows of a single-table
+// query are already keyed by column name.
+func keyRow(sc *scope, src source, row storage.Row) storage.Row {
+	if !sc.qualified {
+		return row
+	}
+	keyed := make(storage.Row, len(src.table.Columns))
+	for _, col := range src.table.Columns {
+		keyed[sc.key(src, col.Name)] = row[col.Name]
+	}
+	return keyed
+}
+
+// indexScanOp fetches rows through one of the table's indexes, by key or
+// by scanning a range of it (see accessPath).
+type indexScanOp struct {
+	sc   *scope
+	src  source
+	path accessPath
+	it   *pathIterator
+}
+
+func (o *indexScanOp) Open() error {
+	o.it = o.path.open()
+	return nil
+}
+
+func (o *indexScanOp) Next() (storage.Row, bool, error) {
+	for {
+		rowID, ok := o.it.next()
+		if !ok {
+			return nil, false, nil
+		}
+		row, ok, err := o.src.table.Store.Get(storage.RowID(rowID))
+		if err != nil {
+			return nil, false, err
+		}
+		if ok {
+			return keyRow(o.sc, o.src, row), true, nil
+		}
+	}
+}
+
+func (o *indexScanOp) Close() error {
+	o.it = nil
+	return nil
+}
+
+// estimate counts one row per key, which is exact for a unique index. For
+// a range it guesses that each condition keeps a third of the rows.
+func (o *indexScanOp) estimate() int {
+	if !o.path.scan {
+		return len(o.path.keys)
+	}
+	n := o.src.table.Store.Count()
+	for range o.path.conds {
+		n /= 3
+	}
+	return max(n, 1)
+}
+
+func (o *indexScanOp) describe() string {
+	if !o.path.scan {
+		return fmt.Sprintf("Index Scan on %s using %s, %d key(s)", sourceName(o.src), o.path.index, len(o.path.keys))
+	}
+
+	kind := "Index Scan"
+	if len(o.path.conds) > 0 {
+		kind = "Index Range Scan"
+	}
+	if o.path.desc {
+		kind += " Backward"
+	}
+	s := fmt.Sprintf("%s on %s using %s", kind, sourceName(o.src), o.path.index)
+	if len(o.path.conds) > 0 {
+		conds := make([]string, len(o.path.conds))
+		for i, cond := range o.path.conds {
+			conds[i] = cond.String()
+		}
+		s += ": " + strings.Join(conds, " AND ")
+	}
+	return s
+}
+
+func (o *indexScanOp) inputs() []*operator { return nil }
+
+// filterOp passes on the rows for which pred is true.
+type filterOp struct {
+	input operator
+	pred  Expr
+}
+
+func (o *filterOp) Open() error { return o.input.Open() }
+
+func (o *filterOp) Next() (storage.Row, bool, error) {
+	for {
+		row, ok, err := o.input.Next()
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		if ok, err := matches(o.pred, row); err != nil || ok {
+			return row, ok, err
+		}
+	}
+}
+
+func (o *filterOp) Close() error { return o.input.Close() }
+
+// estimate guesses, without looking at the predicate, that a third of the
+// input passes it.
+func (o *filterOp) estimate() int { return (o.input.estimate() + 2) / 3 }
+
+func (o *filterOp) describe() string { return "Filter: " + o.pred.String() }
+
+func (o *filterOp) inputs() []*operator { return []*operator{&o.input} }
+
+// projectOp turns rows into result rows, keyed by result column name.
+type projectOp struct {
+	input   operator
+	columns []outputColumn
+}
+
+func (o *projectOp) Open() error { return o.input.Open() }
+
+func (o *projectOp) Next() (storage.Row, bool, error) {
+	row, ok, err := o.input.Next()
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	projected := make(storage.Row, len(o.columns))
+	for _, col := range o.columns {
+		projected[col.name] = row[col.source]
+	}
+	return projected, true, nil
+}
+
+func (o *projectOp) Close() error { return o.input.Close() }
+
+func (o *projectOp) estimate() int { return o.input.estimate() }
+
+func (o *projectOp) describe() string {
+	names := make([]string, len(o.columns))
+	for i, col := range o.columns {
+		names[i] = col.name
+	}
+	return "Project: " + strings.Join(names, ", ")
+}
+
+func (o *projectOp) inputs() []*operator { return []*operator{&o.input} }
+
+// distinctOp passes on the first of every set of rows that are equal in
+// cols. NULLs count as equal to each other here, as in GROUP BY.
+type distinctOp struct {
+	input operator
+	cols  []string
+	seen  map[string]bool
+}
+
+func (o *distinctOp) Open() error {
+	o.seen = make(map[string]bool)
+	return o.input.Open()
+}
+
+func (o *distinctOp) Next() (storage.Row, bool, error) {
+	for {
+		row, ok, err := o.input.Next()
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		if o.first(row) {
+			return row, true, nil
+		}
+	}
+}
+
+// first reports whether row is the first with its values in cols.
+func (o *distinctOp) first(row storage.Row) bool {
+	key := groupKey(row, o.cols)
+	if o.seen[key] {
+		return false
+	}
+	o.seen[key] = true
+	return true
+}
+
+func (o *distinctOp) Close() error {
+	o.seen = nil
+	return o.input.Close()
+}
+
+func (o *distinctOp) estimate() int { return o.input.estimate() }
+
+func (o *distinctOp) describe() string { return "Distinct: " + strings.Join(o.cols, ", ") }
+
+func (o *distinctOp) inputs() []*operator { return []*operator{&o.input} }
+
+// sortOp orders its input by terms. With limit >= 0 only the first limit
+// rows are wanted, and it keeps just those while reading (see topN).
+type sortOp struct {
+	input operator
+	terms []OrderTerm
+	limit int
+	rows  []storage.Row
+}
+
+func (o *sortOp) Open() error {
+	if err := o.input.Open(); err != nil {
+		return err
+	}
+
+	if o.limit >= 0 {
+		top := newTopN(o.terms, o.limit)
+		for seq := 0; ; seq++ {
+			row, ok, err := o.input.Next()
+			if err != nil {
+				return err
+			}
+			if !ok {
+				break
+			}
+			top.offer(row, seq)
+		}
+		var err error
+		o.rows, err = top.sorted()
+		return err
+	}
+
+	rows, err := drain(o.input)
+	if err != nil {
+		return err
+	}
+	o.rows, err = sortRows(rows, o.terms)
+	return err
+}
+
+func (o *sortOp) Next() (storage.Row, bool, error) {
+	if len(o.rows) == 0 {
+		return nil, false, nil
+	}
+	row := o.rows[0]
+	o.rows = o.rows[1:]
+	return row, true, nil
+}
+
+func (o *sortOp) Close() error {
+	o.rows = nil
+	return o.input.Close()
+}
+
+func (o *sortOp) estimate() int {
+	if n := o.input.estimate(); o.limit < 0 || n < o.limit {
+		return n
+	}
+	return o.limit
+}
+
+func (o *sortOp) describe() string {
+	terms := make([]string, len(o.terms))
+	for i, t := range o.terms {
+		terms[i] = t.String()
+	}
+	if o.limit >= 0 {
+		return fmt.Sprintf("Top-N Sort (keep %d): %s", o.limit, strings.Join(terms, ", "))
+	}
+	return "Sort: " + strings.Join(terms, ", ")
+}
+
+func (o *sortOp) inputs() []*operator { return []*operator{&o.input} }
+
+// limitOp skips offset rows and then passes on at most limit rows; a
+// negative limit means no limit. It stops reading its input once it is
+// done.
+type limitOp struct {
+	input    operator
+	offset   int
+	limit    int
+	skipped  int
+	returned int
+}
+
+func (o *limitOp) Open() error {
+	o.skipped, o.returned = 0, 0
+	return o.input.Open()
+}
+
+func (o *limitOp) Next() (storage.Row, bool, error) {
+	if o.limit >= 0 && o.returned >= o.limit {
+		return nil, false, nil
+	}
+
+	for ; o.skipped < o.offset; o.skipped++ {
+		if _, ok, err := o.input.Next(); err != nil || !ok {
+			return nil, false, err
+		}
+	}
+
+	row, ok, err := o.input.Next()
+	if ok {
+		o.returned++
+	}
+	return row, ok, err
+}
+
+func (o *limitOp) Close() error { return o.input.Close() }
+
+func (o *limitOp) estimate() int {
+	n := max(o.input.estimate()-o.offset, 0)
+	if o.limit >= 0 && o.limit < n {
+		return o.limit
+	}
+	return n
+}
+
+func (o *limitOp) describe() string {
+	switch {
+	case o.limit < 0:
+		return fmt.Sprintf("Offset: %d", o.offset)
+	case o.offset > 0:
+		return fmt.Sprintf("Limit: %d offset %d", o.limit, o.offset)
+	}
+	return fmt.Sprintf("Limit: %d", o.limit)
+}
+
+func (o *limitOp) inputs() []*operator { return []*operator{&o.input} }
@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"fastabiz-mini-rdbms/mini-db/storage"
+)
+
+// countingStore counts the rows its scans and lookups hand out.
+type countingStore struct {
+	storage.TableStore
+	read int
+}
+
+func (s *countingStore) Get(id storage.RowID) (storage.Row, bool, error) {
+	row, ok, err := s.TableStore.Get(id)
+	if ok {
+		s.read++
+	}
+	return row, ok, err
+}
+
+func (s *countingStore) Scan() storage.RowIterator {
+	return &countingIterator{RowIterator: s.TableStore.Scan(), store: s}
+}
+
+type countingIterator struct {
+	storage.RowIterator
+	store *countingStore
+}
+
+func (it *countingIterator) Next() (storage.RowID, storage.Row, bool) {
+	id, row, ok := it.RowIterator.Next()
+	if ok {
+		it.store.read++
+	}
+	return id, row, ok
+}
+
+// TestLimitStopsScan checks a LIMIT stops the table scan once it has its
+// rows, while ORDER BY and aggregates still read the whole table.
+func TestLimitStopsScan(t *testing.T) {
+	e := NewEngine()
+	mustRun(t, e,
+		"CREATE TABLE t (id INT PRIMARY KEY, n INT)",
+		"CREATE TABLE u (id INT PRIMARY KEY, t_id INT)",
+		"INSERT INTO u (id, t_id) VALUES (1, 3)",
+	)
+	for i := 1; i <= 100; i++ {
+		mustRun(t, e, fmt.Sprintf("INSERT INTO t (id, n) VALUES (%d, %d)", i, i%10))
+	}
+	store := &countingStore{TableStore: e.Tables["t"].Store}
+	e.Tables["t"].Store = store
+
+	tests := []struct {
+		sql  string
+		want []string
+		read int
+	}{
+		{"SELECT id FROM t LIMIT 3", []string{"1", "2", "3"}, 3},
+		{"SELECT id FROM t LIMIT 2 OFFSET 5", []string{"6", "7"}, 7},
+		{"SELECT id FROM t WHERE n = 0 LIMIT 2", []string{"10", "20"}, 20},
+		{"SELECT id FROM t LIMIT 0", []string{}, 0},
+		{"SELECT id FROM t WHERE id > 50 AND n = 1 LIMIT 1", []string{"51"}, 1},
+		{"SELECT DISTINCT n FROM t LIMIT 2", []string{"1", "2"}, 2},
+		{"SELECT t.id FROM t JOIN u ON t.id = u.t_id LIMIT 1", []string{"3"}, 3},
+		{"SELECT id FROM t ORDER BY n DESC LIMIT 1", []string{"9"}, 100},
+		{"SELECT COUNT(*) FROM t LIMIT 1", []string{"100"}, 100},
+	}
+	for _, tt := range tests {
+		store.read = 0
+		if got := query(t, e, tt.sql); !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.sql, got, tt.want)
+		}
+		if store.read != tt.read {
+			t.Errorf("%s read %d rows, want %d", tt.sql, store.read, tt.read)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package engine
+
+import (
+	"container/heap"
+	"sort"
+
+	"fastabiz-mini-rdbms/mini-db/core"
+	"fastabiz-mini-rdbms/mini-db/storage"
+)
+
+// NullsOrder places NULLs in an ORDER BY. By default NULL sorts below every
+// other value: first in ascending order, last in descending order.
+type NullsOrder int
+
+const (
+	NullsDefault NullsOrder = iota
+	NullsFirst
+	NullsLast
+)
+
+// OrderTerm is one ORDER BY key. For ORDER BY COUNT(*) and the like, Agg
+// is the aggregate and Column its result name.
+type OrderTerm struct {
+	Column string
+	Agg    *Aggregate
+	Desc   bool
+	Nulls  NullsOrder
+}
+
+// String renders the term as in ORDER BY, e.g. "age DESC NULLS FIRST".
+func (t OrderTerm) String() string {
+	s := t.Column
+	if t.Desc {
+		s += " DESC"
+	}
+	switch t.Nulls {
+	case NullsFirst:
+		s += " NULLS FIRST"
+	case NullsLast:
+		s += " NULLS LAST"
+	}
+	return s
+}
+
+func (t OrderTerm) nullsFirst() bool {
+	if t.Nulls == NullsDefault {
+		return !t.Desc
+	}
+	return t.Nulls == NullsFirst
+}
+
+// compareRows orders a and b by terms, returning -1, 0 or +1.
+func compareRows(terms []OrderTerm, a, b storage.Row) (int, error) {
+	for _, t := range terms {
+		va, vb := a[t.Column], b[t.Column]
+
+		switch {
+		case va == nil && vb == nil:
+			continue
+		case va == nil || vb == nil:
+			c := 1
+			if (va == nil) == t.nullsFirst() {
+				c = -1
+			}
+			return c, nil
+		}
+
+		c, err := core.Compare(va, vb)
+		if err != nil {
+			return 0, err
+		}
+		if c != 0 {
+			if t.Desc {
+				c = -c
+			}
+			return c, nil
+		}
+	}
+	return 0, nil
+}
+
+// rowSorter sorts rows by terms; rows that compare equal keep their input
+// order, so results are repeatable. The first comparison error is kept in
+// err, since sort.Interface has no way to return one.
+type rowSorter struct {
+	rows  []storage.Row
+	seq   []int
+	terms []OrderTerm
+	err   error
+}
+
+func (s *rowSorter) Len() int { return len(s.rows) }
+
+func (s *rowSorter) Swap(i, j int) {
+	s.rows[i], s.rows[j] = s.rows[j], s.rows[i]
+	s.seq[i], s.seq[j] = s.seq[j], s.seq[i]
+}
+
+func (s *rowSorter) Less(i, j int) bool {
+	c, err := compareRows(s.terms, s.rows[i], s.rows[j])
+	if err != nil && s.err == nil {
+		s.err = err
+	}
+	if c != 0 {
+		return c < 0
+	}
+	return s.seq[i] < s.seq[j]
+}
+
+// topN keeps the first n rows in sort order seen so far as a max-heap, so
+// its root is the row the next candidate has to beat.
+type topN struct {
+	rowSorter
+	n int
+}
+
+func (h *topN) Less(i, j int) bool { return h.rowSorter.Less(j, i) }
+
+func (h *topN) Push(x any) {
+	r := x.(seqRow)
+	h.rows = append(h.rows, r.row)
+	h.seq = append(h.seq, r.seq)
+}
+
+func (h *topN) Pop() any {
+	last := len(h.rows) - 1
+	r := seqRow{h.rows[last], h.seq[last]}
+	h.rows, h.seq = h.rows[:last], h.seq[:last]
+	return r
+}
+
+type seqRow struct {
+	row storage.Row
+	seq int
+}
+
+// offer adds the row if it belongs among the first n.
+func (h *topN) offer(row storage.Row, seq int) {
+	if h.n == 0 {
+		return
+	}
+	if len(h.rows) < h.n {
+		heap.Push(h, seqRow{row, seq})
+		return
+	}
+
+	c, err := compareRows(h.terms, row, h.rows[0])
+	if err != nil && h.err == nil {
+		h.err = err
+	}
+	// A later row that ties with the root does not displace it.
+	if c < 0 {
+		h.rows[0], h.seq[0] = row, seq
+		heap.Fix(h, 0)
+	}
+}
+
+// sortRows orders rows by terms.
+func sortRows(rows []storage.Row, terms []OrderTerm) ([]storage.Row, error) {
+	s := &rowSorter{rows: rows, seq: make([]int, len(rows)), terms: terms}
+	for i := range s.seq {
+		s.seq[i] = i
+	}
+	sort.Sort(s)
+	return s.rows, s.err
+}
+
+// newTopN collects the first n rows in terms order. Rows are offered as
+// they are scanned, so ORDER BY ... LIMIT n holds n rows at a time and
+// costs O(rows log n) instead of a full sort.
+func newTopN(terms []OrderTerm, n int) *topN {
+	return &topN{rowSorter: rowSorter{terms: terms}, n: n}
+}
+
+// sorted returns the collected rows in order.
+func (h *topN) sorted() ([]storage.Row, error) {
+	if h.err != nil {
+		return nil, h.err
+	}
+	sort.Sort(&h.rowSorter)
+	return h.rows, h.err
+}
+
+// window applies OFFSET and LIMIT to already ordered rows; a negative
+// limit means no limit.
+func window(rows []storage.Row, offset, limit int) []storage.Row {
+	if offset >= len(rows) {
+		return nil
+	}
+	rows = rows[offset:]
+	if limit >= 0 && limit < len(rows) {
+		rows = rows[:limit]
+	}
+	return rows
+}
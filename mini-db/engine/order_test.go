@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestOrderLimit(t *testing.T) {
+	for _, kind := range []string{"memory", "disk"} {
+		t.Run(kind, func(t *testing.T) {
+			e := openEngine(t, t.TempDir())
+			defer e.Close()
+			mustRun(t, e,
+				"CREATE TABLE t (id INT PRIMARY KEY, s TEXT, n INT) ENGINE = "+kind,
+				"INSERT INTO t (id, s, n) VALUES (1, 'b', 30)",
+				"INSERT INTO t (id, s, n) VALUES (2, 'a', NULL)",
+				"INSERT INTO t (id, s, n) VALUES (3, 'c', 10)",
+				"INSERT INTO t (id, s, n) VALUES (4, 'a', 20)",
+				"INSERT INTO t (id, s, n) VALUES (5, NULL, 20)",
+			)
+
+			tests := []struct {
+				clauses string
+				want    []string
+			}{
+				{"ORDER BY n", []string{"2", "3", "4", "5", "1"}},
+				{"ORDER BY n ASC", []string{"2", "3", "4", "5", "1"}},
+				{"ORDER BY n DESC", []string{"1", "4", "5", "3", "2"}},
+				{"ORDER BY n NULLS LAST", []string{"3", "4", "5", "1", "2"}},
+				{"ORDER BY n DESC NULLS FIRST", []string{"2", "1", "4", "5", "3"}},
+				{"ORDER BY s, n DESC", []string{"5", "4", "2", "1", "3"}},
+				{"ORDER BY n LIMIT 2", []string{"2", "3"}},
+				{"ORDER BY n DESC LIMIT 3", []string{"1", "4", "5"}},
+				{"ORDER BY n LIMIT 2 OFFSET 2", []string{"4", "5"}},
+				{"ORDER BY id OFFSET 3", []string{"4", "5"}},
+				{"ORDER BY id LIMIT 0", []string{}},
+				{"ORDER BY id OFFSET 9", []string{}},
+				{"ORDER BY id LIMIT 9 OFFSET 4", []string{"5"}},
+				{"WHERE n >= 20 ORDER BY id DESC LIMIT 2", []string{"5", "4"}},
+			}
+			for _, tt := range tests {
+				sql := "SELECT id FROM t " + tt.clauses
+				if got := query(t, e, sql); !slices.Equal(got, tt.want) {
+					t.Errorf("%s = %q, want %q", sql, got, tt.want)
+				}
+			}
+
+			if got := query(t, e, "SELECT id FROM t WHERE n > 0 LIMIT 2"); len(got) != 2 {
+				t.Errorf("LIMIT 2 without ORDER BY returned %d rows", len(got))
+			}
+
+			for _, sql := range []string{
+				"SELECT id FROM t ORDER BY x",
+				"SELECT id FROM t ORDER BY n NULLS MIDDLE",
+				"SELECT id FROM t LIMIT -1",
+				"SELECT id FROM t LIMIT 'a'",
+				"SELECT id FROM t OFFSET x",
+			} {
+				mustFail(t, e, sql)
+			}
+		})
+	}
+}
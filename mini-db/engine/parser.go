@@ -4,6 +4,7 @@ import (
 	"fastabiz-mini-rdbms/mini-db/core"
 	"fastabiz-mini-rdbms/mini-db/storage"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -17,17 +18,48 @@ func NewParser(tokens []Token) *Parser {
 }
 
 func (p *Parser) Parse() (any, error) {
+	cmd, err := p.parseStatement()
+	if err != nil {
+		return nil, err
+	}
+
+	// An optional trailing semicolon, then nothing else.
+	if p.current().Type == SEMI {
+		p.advance()
+	}
+	if tok := p.current(); tok.Type != EOF {
+		return nil, fmt.Errorf("unexpected token after statement: %s", tok.Literal)
+	}
+	return cmd, nil
+}
+
+func (p *Parser) parseStatement() (any, error) {
 	switch p.current().Type {
 	case CREATE:
-		return p.parseCreateTable()
+		if p.peek().Type == TABLE {
+			return p.parseCreateTable()
+		}
+		return p.parseCreateIndex()
 	case INSERT:
 		return p.parseInsert()
 	case SELECT:
-		return p.parseSelect()
+		return p.parseQuery()
 	case DELETE:
 		return p.parseDelete()
 	case UPDATE:
 		return p.parseUpdate()
+	case EXPLAIN:
+		return p.parseExplain()
+	case DROP:
+		return p.parseDropIndex()
+	case ALTER:
+		return p.parseAlterTable()
+	case IDENT:
+		if p.atWord("CHECKPOINT") {
+			p.advance() // CHECKPOINT
+			return &CheckpointCommand{}, nil
+		}
+		return nil, fmt.Errorf("unexpected token: %s", p.current().Literal)
 	default:
 		return nil, fmt.Errorf("unexpected token: %s", p.current().Literal)
 	}
@@ -51,50 +83,514 @@ func (p *Parser) parseCreateTable() (*CreateTableCommand, error) {
 		return nil, err
 	}
 
+	cmd := &CreateTableCommand{TableName: tableNameTok.Literal}
 	var columns []storage.Column
 
 	for {
-		// 1. Column name
-		colNameTok, err := p.expect(IDENT)
+		// Table constraints: PRIMARY KEY (...),
+		// [CONSTRAINT name] FOREIGN KEY (...) REFERENCES ...
+		// or [CONSTRAINT name] CHECK (...)
+		if p.atWord("CONSTRAINT") || p.atWord("PRIMARY") || p.atWord("FOREIGN") || p.atWord("CHECK") {
+			if err := p.parseTableConstraint(cmd); err != nil {
+				return nil, err
+			}
+			if p.current().Type == COMMA {
+				p.advance()
+				continue
+			}
+			break
+		}
+
+		col, err := p.parseColumnDef(cmd)
 		if err != nil {
 			return nil, err
 		}
+		columns = append(columns, col)
 
-		// 2. Column type
-		colTypeTok, err := p.expect(IDENT)
-		if err != nil {
+		// Check for comma to continue
+		if p.current().Type == COMMA {
+			p.advance()
+			continue
+		}
+		break
+	}
+
+	_, err = p.expect(RPAREN)
+	if err != nil {
+		return nil, err
+	}
+
+	// Optional ENGINE = memory|disk
+	var engine storage.EngineKind
+	if p.current().Type == IDENT && strings.ToUpper(p.current().Literal) == "ENGINE" {
+		p.advance() // consume ENGINE
+
+		if _, err := p.expect(EQ); err != nil {
 			return nil, err
 		}
 
-		colType, err := core.ParseDataType(colTypeTok.Literal)
+		engineTok, err := p.expect(IDENT)
 		if err != nil {
 			return nil, err
 		}
 
-		col := storage.Column{
-			Name: colNameTok.Literal,
-			Type: colType,
+		kind, ok := storage.ParseEngineKind(strings.ToLower(engineTok.Literal))
+		if !ok {
+			return nil, fmt.Errorf("unknown storage engine: %s", engineTok.Literal)
 		}
+		engine = kind
+	}
+
+	cmd.Columns = columns
+	cmd.Engine = engine
+	return cmd, nil
+}
+
+// parseColumnDef reads a column definition: its name, its type and its
+// constraints.
+func (p *Parser) parseColumnDef(cmd *CreateTableCommand) (storage.Column, error) {
+	// 1. Column name
+	colNameTok, err := p.expect(IDENT)
+	if err != nil {
+		return storage.Column{}, err
+	}
+	col := storage.Column{Name: colNameTok.Literal}
+
+	// 2. Column type
+	if err := p.parseColumnType(&col); err != nil {
+		return storage.Column{}, err
+	}
+
+	// 3. Constraints: PRIMARY KEY, UNIQUE, NOT NULL, NULL, REFERENCES,
+	//    CHECK, DEFAULT, AUTOINCREMENT
+	if err := p.parseColumnConstraints(cmd, &col); err != nil {
+		return storage.Column{}, err
+	}
+	return col, nil
+}
+
+// parseColumnType reads a column type into col, with the precision and
+// scale of a DECIMAL.
+func (p *Parser) parseColumnType(col *storage.Column) error {
+	colTypeTok, err := p.expect(IDENT)
+	if err != nil {
+		return err
+	}
+
+	// SERIAL is an INT that numbers itself.
+	if strings.EqualFold(colTypeTok.Literal, "SERIAL") {
+		col.Type, col.AutoIncrement = core.IntType, true
+	} else if col.Type, err = core.ParseDataType(colTypeTok.Literal); err != nil {
+		return err
+	}
+
+	// DECIMAL(p, s)
+	if col.Type == core.DecimalType {
+		return p.parseDecimalSpec(col)
+	}
+	return nil
+}
+
+// parseColumnConstraints reads the constraints after a column's type, in
+// any order. A foreign key is added to cmd.
+func (p *Parser) parseColumnConstraints(cmd *CreateTableCommand, col *storage.Column) error {
+	for {
+		var name string
+		if p.atWord("CONSTRAINT") {
+			p.advance()
+			tok, err := p.expect(IDENT)
+			if err != nil {
+				return err
+			}
+			name = tok.Literal
+			if !p.atWord("REFERENCES") && !p.atWord("CHECK") {
+				return fmt.Errorf("expected REFERENCES or CHECK after CONSTRAINT %s, got %s", name, p.current().Literal)
+			}
+		}
+
+		switch {
+		case p.atWord("CHECK"):
+			expr, err := p.parseCheck()
+			if err != nil {
+				return err
+			}
+			cmd.Checks = append(cmd.Checks, storage.CheckConstraint{Name: name, Column: col.Name, Expr: expr})
+
+		case p.atWord("REFERENCES"):
+			fk := storage.ForeignKey{Name: name, Columns: []string{col.Name}}
+			if err := p.parseReferences(&fk); err != nil {
+				return err
+			}
+			cmd.ForeignKeys = append(cmd.ForeignKeys, fk)
 
-		// 3. PRIMARY KEY
-		if p.current().Type == IDENT && strings.ToUpper(p.current().Literal) == "PRIMARY" {
+		case p.atWord("PRIMARY"):
 			p.advance() // consume PRIMARY
 
 			keyTok, err := p.expect(IDENT)
 			if err != nil {
-				return nil, fmt.Errorf("expected KEY after PRIMARY, got %s", keyTok.Literal)
+				return fmt.Errorf("expected KEY after PRIMARY, got %s", keyTok.Literal)
 			}
 
 			if strings.ToUpper(keyTok.Literal) != "KEY" {
-				return nil, fmt.Errorf("expected KEY after PRIMARY, got %s", keyTok.Literal)
+				return fmt.Errorf("expected KEY after PRIMARY, got %s", keyTok.Literal)
 			}
 
 			col.Primary = true
+
+		case p.atWord("UNIQUE"):
+			p.advance()
+			col.Unique = true
+
+		case p.current().Type == NOT:
+			p.advance()
+			if tok, err := p.expect(NULL); err != nil {
+				return fmt.Errorf("expected NULL after NOT, got %s", tok.Literal)
+			}
+			col.NotNull = true
+
+		case p.current().Type == NULL:
+			p.advance() // nullable, the default
+			col.NotNull = false
+
+		case p.atWord("DEFAULT"):
+			p.advance()
+			def, err := p.parseDefault()
+			if err != nil {
+				return err
+			}
+			col.Default = def
+
+		case p.atWord("AUTOINCREMENT"), p.atWord("AUTO_INCREMENT"):
+			p.advance()
+			col.AutoIncrement = true
+
+		default:
+			return nil
 		}
+	}
+}
 
-		columns = append(columns, col)
+// parseDefault reads the value after DEFAULT: a constant, or
+// CURRENT_TIMESTAMP or CURRENT_DATE for the time of the insert.
+func (p *Parser) parseDefault() (*storage.Default, error) {
+	for _, fn := range []string{"CURRENT_TIMESTAMP", "CURRENT_DATE"} {
+		if p.atWord(fn) {
+			p.advance()
+			return &storage.Default{Func: fn}, nil
+		}
+	}
+
+	val, err := p.parseLiteral()
+	if err != nil {
+		return nil, err
+	}
+	return &storage.Default{Value: val}, nil
+}
+
+// parseTableConstraint reads PRIMARY KEY (col, ...), or [CONSTRAINT name]
+// followed by CHECK (expr) or by FOREIGN KEY (col, ...) and its REFERENCES
+// clause.
+func (p *Parser) parseTableConstraint(cmd *CreateTableCommand) error {
+	if p.atWord("PRIMARY") {
+		p.advance()
+		if !p.atWord("KEY") {
+			return fmt.Errorf("expected KEY after PRIMARY, got %s", p.current().Literal)
+		}
+		p.advance()
+		if cmd.PrimaryKey != nil {
+			return fmt.Errorf("multiple primary keys not allowed")
+		}
+		var err error
+		cmd.PrimaryKey, err = p.parseIdentList()
+		return err
+	}
+
+	var fk storage.ForeignKey
+	if p.atWord("CONSTRAINT") {
+		p.advance()
+		tok, err := p.expect(IDENT)
+		if err != nil {
+			return err
+		}
+		fk.Name = tok.Literal
+	}
+
+	if p.atWord("CHECK") {
+		expr, err := p.parseCheck()
+		if err != nil {
+			return err
+		}
+		cmd.Checks = append(cmd.Checks, storage.CheckConstraint{Name: fk.Name, Expr: expr})
+		return nil
+	}
+
+	if !p.atWord("FOREIGN") {
+		return fmt.Errorf("expected FOREIGN KEY or CHECK, got %s", p.current().Literal)
+	}
+	p.advance()
+	if !p.atWord("KEY") {
+		return fmt.Errorf("expected KEY after FOREIGN, got %s", p.current().Literal)
+	}
+	p.advance()
+
+	var err error
+	if fk.Columns, err = p.parseIdentList(); err != nil {
+		return err
+	}
+	if !p.atWord("REFERENCES") {
+		return fmt.Errorf("expected REFERENCES, got %s", p.current().Literal)
+	}
+	if err := p.parseReferences(&fk); err != nil {
+		return err
+	}
+	cmd.ForeignKeys = append(cmd.ForeignKeys, fk)
+	return nil
+}
+
+// parseCheck reads CHECK (expr), returning expr as SQL text.
+func (p *Parser) parseCheck() (string, error) {
+	p.advance() // CHECK
+
+	if _, err := p.expect(LPAREN); err != nil {
+		return "", err
+	}
+	expr, err := p.parseExpr()
+	if err != nil {
+		return "", err
+	}
+	if _, err := p.expect(RPAREN); err != nil {
+		return "", err
+	}
+	return expr.String(), nil
+}
+
+// parseReferences reads REFERENCES table [(col, ...)] followed by any of
+// ON DELETE action and ON UPDATE action. Without a column list the key
+// refers to the table's primary key.
+func (p *Parser) parseReferences(fk *storage.ForeignKey) error {
+	p.advance() // REFERENCES
+
+	tableTok, err := p.expect(IDENT)
+	if err != nil {
+		return err
+	}
+	fk.RefTable = tableTok.Literal
+
+	if p.current().Type == LPAREN {
+		if fk.RefColumns, err = p.parseIdentList(); err != nil {
+			return err
+		}
+	}
+
+	for p.current().Type == ON {
+		p.advance() // ON
+
+		var action *storage.RefAction
+		switch p.current().Type {
+		case DELETE:
+			action = &fk.OnDelete
+		case UPDATE:
+			action = &fk.OnUpdate
+		default:
+			return fmt.Errorf("expected DELETE or UPDATE after ON, got %s", p.current().Literal)
+		}
+		p.advance()
+
+		if *action, err = p.parseRefAction(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseRefAction reads CASCADE, SET NULL, RESTRICT or NO ACTION, which is
+// the same as RESTRICT here as constraints are checked right away.
+func (p *Parser) parseRefAction() (storage.RefAction, error) {
+	switch {
+	case p.atWord("CASCADE"):
+		p.advance()
+		return storage.CascadeAction, nil
+
+	case p.atWord("RESTRICT"):
+		p.advance()
+		return storage.RestrictAction, nil
+
+	case p.current().Type == SET:
+		p.advance()
+		if tok, err := p.expect(NULL); err != nil {
+			return "", fmt.Errorf("expected NULL after SET, got %s", tok.Literal)
+		}
+		return storage.SetNullAction, nil
+
+	case p.atWord("NO"):
+		p.advance()
+		if !p.atWord("ACTION") {
+			return "", fmt.Errorf("expected ACTION after NO, got %s", p.current().Literal)
+		}
+		p.advance()
+		return storage.RestrictAction, nil
+	}
+	return "", fmt.Errorf("expected CASCADE, SET NULL, RESTRICT or NO ACTION, got %s", p.current().Literal)
+}
+
+// parseCreateIndex reads CREATE [UNIQUE] INDEX name ON table (col, ...).
+func (p *Parser) parseCreateIndex() (*CreateIndexCommand, error) {
+	p.advance() // CREATE
+
+	cmd := &CreateIndexCommand{}
+	if p.atWord("UNIQUE") {
+		p.advance() // UNIQUE
+		cmd.Unique = true
+	}
+	if !p.atWord("INDEX") {
+		return nil, fmt.Errorf("expected TABLE or INDEX after CREATE, got %s", p.current().Literal)
+	}
+	p.advance() // INDEX
+
+	name, err := p.expect(IDENT)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(ON); err != nil {
+		return nil, err
+	}
+	table, err := p.expect(IDENT)
+	if err != nil {
+		return nil, err
+	}
+	cmd.IndexName, cmd.TableName = name.Literal, table.Literal
+
+	if cmd.Columns, err = p.parseIdentList(); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// parseDropIndex reads DROP INDEX name.
+func (p *Parser) parseDropIndex() (*DropIndexCommand, error) {
+	p.advance() // DROP
+
+	if !p.atWord("INDEX") {
+		return nil, fmt.Errorf("expected INDEX after DROP, got %s", p.current().Literal)
+	}
+	p.advance() // INDEX
+
+	name, err := p.expect(IDENT)
+	if err != nil {
+		return nil, err
+	}
+	return &DropIndexCommand{IndexName: name.Literal}, nil
+}
+
+// parseAlterTable reads ALTER TABLE name and one action:
+//
+//	ADD [COLUMN] col type [constraints]
+//	DROP [COLUMN] col
+//	RENAME [COLUMN] col TO name
+//	RENAME TO name
+//	ALTER [COLUMN] col [SET DATA] TYPE type
+func (p *Parser) parseAlterTable() (*AlterTableCommand, error) {
+	p.advance() // ALTER
+
+	if _, err := p.expect(TABLE); err != nil {
+		return nil, err
+	}
+	tableTok, err := p.expect(IDENT)
+	if err != nil {
+		return nil, err
+	}
+	cmd := &AlterTableCommand{TableName: tableTok.Literal}
+
+	switch {
+	case p.atWord("ADD"):
+		p.advance()
+		p.skipWord("COLUMN")
+
+		def := &CreateTableCommand{TableName: cmd.TableName}
+		col, err := p.parseColumnDef(def)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Action, cmd.Column, cmd.Def = AddColumn, col.Name, col
+		cmd.ForeignKeys, cmd.Checks = def.ForeignKeys, def.Checks
+
+	case p.current().Type == DROP:
+		p.advance()
+		p.skipWord("COLUMN")
+
+		colTok, err := p.expect(IDENT)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Action, cmd.Column = DropColumn, colTok.Literal
+
+	case p.atWord("RENAME"):
+		p.advance()
+		cmd.Action = RenameTable
+		if !p.atWord("TO") {
+			p.skipWord("COLUMN")
+			colTok, err := p.expect(IDENT)
+			if err != nil {
+				return nil, err
+			}
+			if !p.atWord("TO") {
+				return nil, fmt.Errorf("expected TO after RENAME COLUMN %s, got %s", colTok.Literal, p.current().Literal)
+			}
+			cmd.Action, cmd.Column = RenameColumn, colTok.Literal
+		}
+		p.advance() // TO
+
+		nameTok, err := p.expect(IDENT)
+		if err != nil {
+			return nil, err
+		}
+		cmd.NewName = nameTok.Literal
+
+	case p.current().Type == ALTER:
+		p.advance()
+		p.skipWord("COLUMN")
+
+		colTok, err := p.expect(IDENT)
+		if err != nil {
+			return nil, err
+		}
+		if p.current().Type == SET {
+			p.advance()
+			if !p.atWord("DATA") {
+				return nil, fmt.Errorf("expected DATA after SET, got %s", p.current().Literal)
+			}
+			p.advance()
+		}
+		if !p.atWord("TYPE") {
+			return nil, fmt.Errorf("expected TYPE after ALTER COLUMN %s, got %s", colTok.Literal, p.current().Literal)
+		}
+		p.advance()
+
+		cmd.Action, cmd.Column = AlterColumnType, colTok.Literal
+		if err := p.parseColumnType(&cmd.Def); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("expected ADD, DROP, RENAME or ALTER after ALTER TABLE %s, got %s", cmd.TableName, p.current().Literal)
+	}
+	return cmd, nil
+}
+
+// parseIdentList reads a parenthesised, comma-separated list of names.
+func (p *Parser) parseIdentList() ([]string, error) {
+	if _, err := p.expect(LPAREN); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for {
+		tok, err := p.expect(IDENT)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, tok.Literal)
 
-		// 4. Check for comma to continue
 		if p.current().Type == COMMA {
 			p.advance()
 			continue
@@ -102,18 +598,12 @@ func (p *Parser) parseCreateTable() (*CreateTableCommand, error) {
 		break
 	}
 
-	_, err = p.expect(RPAREN)
-	if err != nil {
+	if _, err := p.expect(RPAREN); err != nil {
 		return nil, err
 	}
-
-	return &CreateTableCommand{
-		TableName: tableNameTok.Literal,
-		Columns:   columns,
-	}, nil
+	return names, nil
 }
 
-
 func (p *Parser) parseInsert() (*InsertCommand, error) {
 	p.advance() // INSERT
 	_, err := p.expect(INTO)
@@ -163,8 +653,11 @@ func (p *Parser) parseInsert() (*InsertCommand, error) {
 
 	values := make(map[string]any)
 	for i := 0; i < len(cols); i++ {
-		tok := p.advance()
-		values[cols[i]] = tok.Literal
+		val, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		values[cols[i]] = val
 
 		if p.current().Type == COMMA {
 			p.advance()
@@ -185,13 +678,19 @@ func (p *Parser) parseInsert() (*InsertCommand, error) {
 func (p *Parser) parseSelect() (*SelectCommand, error) {
 	p.advance() // SELECT
 
-	var cols []string
+	distinct := false
+	if p.current().Type == DISTINCT {
+		p.advance() // DISTINCT
+		distinct = true
+	}
+
+	var cols []SelectItem
 	for {
-		tok := p.advance()
-		if tok.Type != IDENT && tok.Type != STAR {
-			return nil, fmt.Errorf("invalid column")
+		item, err := p.parseSelectItem()
+		if err != nil {
+			return nil, err
 		}
-		cols = append(cols, tok.Literal)
+		cols = append(cols, item)
 
 		if p.current().Type == COMMA {
 			p.advance()
@@ -210,41 +709,242 @@ func (p *Parser) parseSelect() (*SelectCommand, error) {
 	if err != nil {
 		return nil, err
 	}
+	alias, err := p.parseAlias()
+	if err != nil {
+		return nil, err
+	}
 
-	// optional JOIN
-	var join *JoinSpec
-	if p.current().Type == JOIN {
-		join, err = p.parseJoin(tableTok.Literal)
+	// optional JOINs
+	var joins []JoinClause
+	for p.atJoin() {
+		join, err := p.parseJoin()
 		if err != nil {
 			return nil, err
 		}
+		joins = append(joins, *join)
 	}
 
 	// optional WHERE
-	var where *WhereClause
-	if p.current().Type == WHERE {
-		p.advance() // WHERE
-		col, err := p.expect(IDENT)
-		if err != nil {
+	where, err := p.parseOptionalWhere()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := &SelectCommand{
+		TableName: tableTok.Literal,
+		Alias:     alias,
+		Distinct:  distinct,
+		Columns:   cols,
+		Joins:     joins,
+		Where:     where,
+	}
+
+	// optional GROUP BY, HAVING
+	if p.current().Type == GROUP {
+		p.advance() // GROUP
+		if _, err := p.expect(BY); err != nil {
 			return nil, err
 		}
-		if _, err := p.expect(EQ); err != nil {
+		for {
+			col, err := p.parseColumnName()
+			if err != nil {
+				return nil, err
+			}
+			cmd.GroupBy = append(cmd.GroupBy, col)
+
+			if p.current().Type != COMMA {
+				break
+			}
+			p.advance()
+		}
+	}
+	if p.current().Type == HAVING {
+		p.advance() // HAVING
+		if cmd.Having, err = p.parseExpr(); err != nil {
 			return nil, err
 		}
-		val := p.advance()
+	}
+
+	return cmd, nil
+}
+
+// parseQuery reads a SELECT, or several joined by UNION [ALL], INTERSECT
+// or EXCEPT, followed by the optional ORDER BY, LIMIT and OFFSET that
+// apply to the whole result.
+func (p *Parser) parseQuery() (any, error) {
+	first, err := p.parseSelect()
+	if err != nil {
+		return nil, err
+	}
+
+	compound := &CompoundSelectCommand{Selects: []SelectCommand{*first}}
+	for {
+		var op SetOp
+		switch p.current().Type {
+		case UNION:
+			p.advance() // UNION
+			op = SetUnion
+			if p.atWord("ALL") {
+				p.advance() // ALL
+				op = SetUnionAll
+			}
+		case INTERSECT:
+			p.advance() // INTERSECT
+			op = SetIntersect
+		case EXCEPT:
+			p.advance() // EXCEPT
+			op = SetExcept
+		}
+		if op == "" {
+			break
+		}
 
-		where = &WhereClause{
-			Column: col.Literal,
-			Value:  val.Literal,
+		if p.current().Type != SELECT {
+			return nil, fmt.Errorf("expected SELECT after %s, got %s", op, p.current().Type)
+		}
+		next, err := p.parseSelect()
+		if err != nil {
+			return nil, err
 		}
+		compound.Selects = append(compound.Selects, *next)
+		compound.Ops = append(compound.Ops, op)
 	}
 
-	return &SelectCommand{
-		TableName: tableTok.Literal,
-		Columns:   cols,
-		Join:      join,
-		Where:     where,
-	}, nil
+	// optional ORDER BY, LIMIT, OFFSET
+	orderBy, err := p.parseOrderBy()
+	if err != nil {
+		return nil, err
+	}
+	limit, offset, err := p.parseLimit()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(compound.Ops) == 0 {
+		first.OrderBy, first.Limit, first.Offset = orderBy, limit, offset
+		return first, nil
+	}
+	compound.OrderBy, compound.Limit, compound.Offset = orderBy, limit, offset
+	return compound, nil
+}
+
+// parseExplain reads EXPLAIN [ANALYZE] followed by a SELECT, or EXPLAIN
+// followed by an UPDATE or DELETE.
+func (p *Parser) parseExplain() (*ExplainCommand, error) {
+	p.advance() // EXPLAIN
+
+	cmd := &ExplainCommand{}
+	if p.atWord("ANALYZE") {
+		p.advance() // ANALYZE
+		cmd.Analyze = true
+	}
+
+	var err error
+	switch p.current().Type {
+	case SELECT:
+	case UPDATE, DELETE:
+		// Analyzing would run the statement and change the table.
+		if cmd.Analyze {
+			return nil, fmt.Errorf("EXPLAIN ANALYZE of %s is not supported", p.current().Type)
+		}
+		if p.current().Type == UPDATE {
+			cmd.Update, err = p.parseUpdate()
+		} else {
+			cmd.Delete, err = p.parseDelete()
+		}
+		return cmd, err
+	default:
+		return nil, fmt.Errorf("EXPLAIN expects a SELECT, UPDATE or DELETE, got %s", p.current().Literal)
+	}
+	stmt, err := p.parseQuery()
+	if err != nil {
+		return nil, err
+	}
+	sel, ok := stmt.(*SelectCommand)
+	if !ok {
+		return nil, fmt.Errorf("EXPLAIN of UNION, INTERSECT or EXCEPT is not supported")
+	}
+	cmd.Select = *sel
+	return cmd, nil
+}
+
+// parseSelectItem reads `*`, `alias.*`, a column or an aggregate call;
+// columns and aggregates take an optional `AS alias`.
+func (p *Parser) parseSelectItem() (SelectItem, error) {
+	var item SelectItem
+
+	switch tok := p.current(); {
+	case tok.Type == STAR:
+		p.advance()
+		return SelectItem{Column: "*"}, nil
+
+	case tok.Type == IDENT && p.peek().Type == LPAREN:
+		agg, err := p.parseAggregate()
+		if err != nil {
+			return item, err
+		}
+		item.Agg = &agg
+
+	case tok.Type == IDENT && p.peek().Type == DOT && p.peekAt(2).Type == STAR:
+		item.Column = p.advance().Literal + ".*"
+		p.advance() // .
+		p.advance() // *
+		return item, nil
+
+	case tok.Type == IDENT:
+		col, err := p.parseColumnName()
+		if err != nil {
+			return item, err
+		}
+		item.Column = col
+
+	default:
+		return item, fmt.Errorf("invalid column")
+	}
+
+	if p.current().Type == AS {
+		p.advance() // AS
+		alias, err := p.expect(IDENT)
+		if err != nil {
+			return item, err
+		}
+		item.Alias = alias.Literal
+	}
+	return item, nil
+}
+
+// parseAggregate reads COUNT(*), COUNT([DISTINCT] col), SUM(col), AVG(col),
+// MIN(col) or MAX(col).
+func (p *Parser) parseAggregate() (Aggregate, error) {
+	name := p.advance()
+	fn, ok := parseAggFunc(name.Literal)
+	if !ok {
+		return Aggregate{}, fmt.Errorf("unknown function %s", name.Literal)
+	}
+	agg := Aggregate{Func: fn}
+
+	if _, err := p.expect(LPAREN); err != nil {
+		return agg, err
+	}
+
+	if p.current().Type == STAR {
+		p.advance() // *
+	} else {
+		if p.current().Type == DISTINCT {
+			p.advance() // DISTINCT
+			agg.Distinct = true
+		}
+		col, err := p.parseColumnName()
+		if err != nil {
+			return agg, err
+		}
+		agg.Column = col
+	}
+
+	if _, err := p.expect(RPAREN); err != nil {
+		return agg, err
+	}
+	return agg, nil
 }
 
 func (p *Parser) parseDelete() (*DeleteCommand, error) {
@@ -259,106 +959,361 @@ func (p *Parser) parseDelete() (*DeleteCommand, error) {
 		return nil, err
 	}
 
-	p.expect(WHERE)
-	col, _ := p.expect(IDENT)
-	p.expect(EQ)
-	val := p.advance()
+	where, err := p.parseOptionalWhere()
+	if err != nil {
+		return nil, err
+	}
 
 	return &DeleteCommand{
 		TableName: table.Literal,
-		Where: &WhereClause{
-			Column: col.Literal,
-			Value:  val.Literal,
-		},
+		Where:     where,
 	}, nil
 }
 
 func (p *Parser) parseUpdate() (*UpdateCommand, error) {
 	p.advance() // UPDATE
-	table, _ := p.expect(IDENT)
-	p.expect(SET)
+	table, err := p.expect(IDENT)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(SET); err != nil {
+		return nil, err
+	}
 
-	col, _ := p.expect(IDENT)
-	p.expect(EQ)
-	val := p.advance()
+	col, err := p.expect(IDENT)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(EQ); err != nil {
+		return nil, err
+	}
+	val, err := p.parseLiteral()
+	if err != nil {
+		return nil, err
+	}
 
-	p.expect(WHERE)
-	wcol, _ := p.expect(IDENT)
-	p.expect(EQ)
-	wval := p.advance()
+	where, err := p.parseOptionalWhere()
+	if err != nil {
+		return nil, err
+	}
 
 	return &UpdateCommand{
 		TableName: table.Literal,
 		Set: map[string]any{
-			col.Literal: val.Literal,
-		},
-		Where: &WhereClause{
-			Column: wcol.Literal,
-			Value:  wval.Literal,
+			col.Literal: val,
 		},
+		Where: where,
 	}, nil
 }
 
-func (p *Parser) parseJoin(leftTable string) (*JoinSpec, error) {
-	p.advance() // JOIN
-
-	// JOIN orders
-	rightTableTok, err := p.expect(IDENT)
+// parseJoin reads one `<join type> table [alias] ON condition`; a CROSS
+// JOIN has no ON.
+func (p *Parser) parseJoin() (*JoinClause, error) {
+	joinType, err := p.parseJoinType()
 	if err != nil {
 		return nil, err
 	}
 
-	if _, err := p.expect(ON); err != nil {
+	// JOIN orders [o]
+	tableTok, err := p.expect(IDENT)
+	if err != nil {
 		return nil, err
 	}
-
-	// users.id
-	leftTableTok, err := p.expect(IDENT)
+	alias, err := p.parseAlias()
 	if err != nil {
 		return nil, err
 	}
-	if _, err := p.expect(DOT); err != nil {
+
+	join := &JoinClause{Type: joinType, Table: tableTok.Literal, Alias: alias}
+	if joinType == CrossJoin {
+		return join, nil
+	}
+
+	if _, err := p.expect(ON); err != nil {
 		return nil, err
 	}
-	leftColTok, err := p.expect(IDENT)
-	if err != nil {
+	if join.On, err = p.parseExpr(); err != nil {
 		return nil, err
 	}
+	return join, nil
+}
 
-	if _, err := p.expect(EQ); err != nil {
-		return nil, err
+// parseAlias reads the optional `[AS] alias` after a table name.
+func (p *Parser) parseAlias() (string, error) {
+	if p.current().Type == AS {
+		p.advance() // AS
+		alias, err := p.expect(IDENT)
+		return alias.Literal, err
 	}
+	// A bare word is an alias unless it starts the next clause.
+	if p.current().Type == IDENT && !p.atJoin() && !p.atOffset() {
+		return p.advance().Literal, nil
+	}
+	return "", nil
+}
 
-	// orders.user_id
-	rightTableTok2, err := p.expect(IDENT)
+// parseColumnName reads a column reference, `col` or `table.col`.
+func (p *Parser) parseColumnName() (string, error) {
+	tok, err := p.expect(IDENT)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	if _, err := p.expect(DOT); err != nil {
-		return nil, err
+	if p.current().Type != DOT {
+		return tok.Literal, nil
 	}
-	rightColTok, err := p.expect(IDENT)
+	p.advance() // .
+
+	col, err := p.expect(IDENT)
 	if err != nil {
+		return "", err
+	}
+	return tok.Literal + "." + col.Literal, nil
+}
+
+// atJoin reports whether a join clause starts here. LEFT, RIGHT and FULL
+// are not reserved words, so they only start one before OUTER or JOIN.
+func (p *Parser) atJoin() bool {
+	switch p.current().Type {
+	case JOIN, INNER, CROSS:
+		return true
+	}
+	if p.atWord("LEFT") || p.atWord("RIGHT") || p.atWord("FULL") {
+		next := p.peek().Type
+		return next == OUTER || next == JOIN
+	}
+	return false
+}
+
+// parseJoinType reads [INNER] JOIN, LEFT|RIGHT|FULL [OUTER] JOIN or
+// CROSS JOIN.
+func (p *Parser) parseJoinType() (JoinType, error) {
+	joinType := InnerJoin
+
+	switch p.current().Type {
+	case INNER:
+		p.advance()
+	case CROSS:
+		p.advance()
+		joinType = CrossJoin
+	case IDENT: // LEFT, RIGHT or FULL, see atJoin
+		joinType = JoinType(strings.ToUpper(p.advance().Literal))
+		if p.current().Type == OUTER {
+			p.advance()
+		}
+	}
+
+	if _, err := p.expect(JOIN); err != nil {
+		return "", err
+	}
+	return joinType, nil
+}
+
+// parseOptionalWhere reads `WHERE <expr>` if present; a missing WHERE
+// clause comes back as nil.
+func (p *Parser) parseOptionalWhere() (Expr, error) {
+	if p.current().Type != WHERE {
+		return nil, nil
+	}
+	p.advance() // WHERE
+	return p.parseExpr()
+}
+
+// parseOrderBy reads `ORDER BY col [ASC|DESC] [NULLS FIRST|LAST], ...` if
+// present.
+func (p *Parser) parseOrderBy() ([]OrderTerm, error) {
+	if p.current().Type != ORDER {
+		return nil, nil
+	}
+	p.advance() // ORDER
+	if _, err := p.expect(BY); err != nil {
 		return nil, err
 	}
 
-	// safety check
-	if rightTableTok2.Literal != rightTableTok.Literal {
-		return nil, fmt.Errorf(
-			"JOIN table mismatch: expected %s, got %s",
-			rightTableTok.Literal,
-			rightTableTok2.Literal,
-		)
+	var terms []OrderTerm
+	for {
+		var term OrderTerm
+		if p.peek().Type == LPAREN {
+			// ORDER BY COUNT(*) sorts on the aggregate's result column.
+			agg, err := p.parseAggregate()
+			if err != nil {
+				return nil, err
+			}
+			term.Column, term.Agg = agg.String(), &agg
+		} else {
+			col, err := p.parseColumnName()
+			if err != nil {
+				return nil, err
+			}
+			term.Column = col
+		}
+
+		switch p.current().Type {
+		case ASC:
+			p.advance()
+		case DESC:
+			p.advance()
+			term.Desc = true
+		}
+
+		// NULLS FIRST / NULLS LAST are not reserved words.
+		if tok := p.current(); tok.Type == IDENT && strings.ToUpper(tok.Literal) == "NULLS" {
+			p.advance() // NULLS
+			pos, err := p.expect(IDENT)
+			if err != nil {
+				return nil, err
+			}
+			switch strings.ToUpper(pos.Literal) {
+			case "FIRST":
+				term.Nulls = NullsFirst
+			case "LAST":
+				term.Nulls = NullsLast
+			default:
+				return nil, fmt.Errorf("expected FIRST or LAST after NULLS, got %s", pos.Literal)
+			}
+		}
+
+		terms = append(terms, term)
+
+		if p.current().Type == COMMA {
+			p.advance()
+			continue
+		}
+		return terms, nil
+	}
+}
+
+// parseLimit reads `LIMIT n [OFFSET m]` or a lone `OFFSET m`. A missing
+// LIMIT comes back as nil.
+func (p *Parser) parseLimit() (limit *int, offset int, err error) {
+	if p.current().Type == LIMIT {
+		p.advance() // LIMIT
+		n, err := p.parseCount("LIMIT")
+		if err != nil {
+			return nil, 0, err
+		}
+		limit = &n
 	}
 
-	return &JoinSpec{
-		LeftTable:   leftTableTok.Literal,
-		RightTable: rightTableTok.Literal,
-		LeftColumn: leftColTok.Literal,
-		RightColumn: rightColTok.Literal,
-	}, nil
+	if p.atOffset() {
+		p.advance() // OFFSET
+		if offset, err = p.parseCount("OFFSET"); err != nil {
+			return nil, 0, err
+		}
+	}
+	return limit, offset, nil
+}
+
+// atOffset reports whether an OFFSET clause starts here. OFFSET is not a
+// reserved word, so it has to be followed by the count.
+func (p *Parser) atOffset() bool {
+	return p.atWord("OFFSET") && p.peek().Type == NUMBER
+}
+
+// parseCount reads the non-negative row count of a LIMIT or OFFSET.
+func (p *Parser) parseCount(clause string) (int, error) {
+	tok, err := p.expect(NUMBER)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(tok.Literal)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer, got %s", clause, tok.Literal)
+	}
+	return n, nil
+}
+
+// parseLiteral reads a constant: a number (optionally negative), a quoted
+// string, TRUE/FALSE, a DATE or TIMESTAMP literal, or NULL. Integers come
+// back as int64, numbers with a fraction as core.Decimal and NULL as nil;
+// they are checked against the column type when the statement is bound.
+func (p *Parser) parseLiteral() (any, error) {
+	tok := p.advance()
+
+	switch tok.Type {
+	case NULL:
+		return nil, nil
+	case NUMBER:
+		return parseNumber(tok.Literal)
+	case MINUS:
+		num, err := p.expect(NUMBER)
+		if err != nil {
+			return nil, err
+		}
+		return parseNumber("-" + num.Literal)
+	case STRING:
+		return tok.Literal, nil
+	case TRUE:
+		return true, nil
+	case FALSE:
+		return false, nil
+	case IDENT:
+		// Typed literals: DATE '2024-01-31', TIMESTAMP '2024-01-31 10:00:00'
+		if p.current().Type == STRING {
+			switch strings.ToUpper(tok.Literal) {
+			case "DATE":
+				return core.ParseDate(p.advance().Literal)
+			case "TIMESTAMP":
+				return core.ParseTimestamp(p.advance().Literal)
+			}
+		}
+		return nil, fmt.Errorf("expected a value, got %s", tok.Literal)
+	default:
+		return nil, fmt.Errorf("expected a value, got %s", tok.Type)
+	}
+}
+
+func parseNumber(lit string) (any, error) {
+	if strings.Contains(lit, ".") {
+		return core.ParseDecimal(lit)
+	}
+
+	n, err := strconv.ParseInt(lit, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("integer out of range: %s", lit)
+	}
+	return n, nil
 }
 
+// parseDecimalSpec reads the optional (precision[, scale]) after DECIMAL.
+// A bare DECIMAL holds whole numbers of up to the maximum precision.
+func (p *Parser) parseDecimalSpec(col *storage.Column) error {
+	col.Precision = core.MaxDecimalPrecision
+	col.Scale = 0
+
+	if p.current().Type != LPAREN {
+		return nil
+	}
+	p.advance() // (
+
+	precTok, err := p.expect(NUMBER)
+	if err != nil {
+		return err
+	}
+	precision, err := strconv.Atoi(precTok.Literal)
+	if err != nil || precision < 1 || precision > core.MaxDecimalPrecision {
+		return fmt.Errorf("DECIMAL precision must be between 1 and %d", core.MaxDecimalPrecision)
+	}
+
+	scale := 0
+	if p.current().Type == COMMA {
+		p.advance() // ,
+		scaleTok, err := p.expect(NUMBER)
+		if err != nil {
+			return err
+		}
+		scale, err = strconv.Atoi(scaleTok.Literal)
+		if err != nil || scale > precision {
+			return fmt.Errorf("DECIMAL scale must be between 0 and the precision %d", precision)
+		}
+	}
+
+	if _, err := p.expect(RPAREN); err != nil {
+		return err
+	}
+
+	col.Precision, col.Scale = precision, scale
+	return nil
+}
 
 func (p *Parser) current() Token {
 	if p.pos >= len(p.tokens) {
@@ -367,6 +1322,31 @@ func (p *Parser) current() Token {
 	return p.tokens[p.pos]
 }
 
+// atWord reports whether the current token is the given word, for words
+// such as PRIMARY or INDEX that are not reserved keywords.
+func (p *Parser) atWord(word string) bool {
+	tok := p.current()
+	return tok.Type == IDENT && strings.EqualFold(tok.Literal, word)
+}
+
+// skipWord consumes word if it comes next.
+func (p *Parser) skipWord(word string) {
+	if p.atWord(word) {
+		p.advance()
+	}
+}
+
+func (p *Parser) peek() Token {
+	return p.peekAt(1)
+}
+
+func (p *Parser) peekAt(n int) Token {
+	if p.pos+n >= len(p.tokens) {
+		return Token{Type: EOF}
+	}
+	return p.tokens[p.pos+n]
+}
+
 func (p *Parser) advance() Token {
 	tok := p.current()
 	p.pos++
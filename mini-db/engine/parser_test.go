@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"slices"
+	"testing"
+)
+
+// Words that only mean something in one clause are not reserved, so they
+// still work as table and column names.
+func TestUnreservedWords(t *testing.T) {
+	e := NewEngine()
+	mustRun(t, e,
+		"CREATE TABLE checkpoint (id INT PRIMARY KEY, s TEXT, offset INT, all TEXT)",
+		"INSERT INTO checkpoint (id, s, offset) VALUES (1, 'a', 10)",
+		"INSERT INTO checkpoint (id, s, offset) VALUES (2, 'b', 20)",
+		"CREATE TABLE left (id INT PRIMARY KEY, full INT, right TEXT)",
+		"INSERT INTO left (id, full, right) VALUES (1, 7, 'r')",
+	)
+
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"SELECT id, s FROM checkpoint WHERE id = 1", []string{"1 a"}},
+		{"SELECT s, offset FROM checkpoint ORDER BY offset OFFSET 1", []string{"b 20"}},
+		{"SELECT full, right FROM left WHERE full = 7", []string{"7 r"}},
+		{"SELECT l.id, c.s FROM left l LEFT JOIN checkpoint c ON l.id = c.id", []string{"1 a"}},
+		{"SELECT c.id, full FROM checkpoint c FULL OUTER JOIN left ON left.id = c.id ORDER BY c.id", []string{"1 7", "2 <nil>"}},
+		{"SELECT id FROM checkpoint offset ORDER BY id LIMIT 1", []string{"1"}},
+		{"SELECT id FROM checkpoint ORDER BY id DESC OFFSET 1", []string{"1"}},
+		{"SELECT id FROM checkpoint UNION ALL SELECT id FROM checkpoint WHERE all IS NULL ORDER BY id", []string{"1", "1", "2", "2"}},
+	}
+	for _, tt := range tests {
+		if got := query(t, e, tt.sql); !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+
+	for _, sql := range []string{
+		"CHECKPOINT",
+		"SELECT * FROM left LEFT JOIN checkpoint ON left.id = checkpoint.id",
+		"SELECT * FROM left FULL OUTER JOIN right ON left.id = right.id",
+		"EXPLAIN ANALYZE SELECT * FROM left",
+	} {
+		if _, err := parse(sql); err != nil {
+			t.Errorf("%s: %v", sql, err)
+		}
+	}
+}
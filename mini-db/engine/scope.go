@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"fastabiz-mini-rdbms/mini-db/storage"
+)
+
+// source is one table of a FROM clause, under the name the query uses for
+// it: its alias, or the table name when it has none.
+type source struct {
+	alias string
+	table *storage.Table
+}
+
+// scope resolves the column references of a statement against its tables.
+// A single-table statement works on the table's own rows, keyed by column
+// name. A join works on combined rows keyed by "alias.column", so the
+// same column of two tables, or of one table joined to itself, stays
+// apart.
+type scope struct {
+	sources   []source
+	qualified bool
+	columns   map[string]storage.Column // by row key
+}
+
+func tableScope(table *storage.Table, alias string) *scope {
+	return newScope([]source{{alias: aliasOr(alias, table.Name), table: table}}, false)
+}
+
+func newScope(sources []source, qualified bool) *scope {
+	sc := &scope{sources: sources, qualified: qualified, columns: make(map[string]storage.Column)}
+	for _, src := range sources {
+		for _, col := range src.table.Columns {
+			sc.columns[sc.key(src, col.Name)] = col
+		}
+	}
+	return sc
+}
+
+// prefix is the scope of the first n sources, as seen by the ON condition
+// of the n-1th join.
+func (sc *scope) prefix(n int) *scope {
+	return newScope(sc.sources[:n], sc.qualified)
+}
+
+func (sc *scope) key(src source, col string) string {
+	if sc.qualified {
+		return src.alias + "." + col
+	}
+	return col
+}
+
+// keys lists the row keys of src's columns in schema order.
+func (sc *scope) keys(src source) []string {
+	keys := make([]string, len(src.table.Columns))
+	for i, col := range src.table.Columns {
+		keys[i] = sc.key(src, col.Name)
+	}
+	return keys
+}
+
+// resolve finds the column a reference such as "name" or "u.name" names,
+// returning the key it is stored under in rows.
+func (sc *scope) resolve(ref string) (string, storage.Column, error) {
+	qual, name := splitColumnRef(ref)
+
+	var (
+		found     []source
+		knownQual bool
+	)
+	for _, src := range sc.sources {
+		if qual != "" && qual != src.alias {
+			continue
+		}
+		knownQual = true
+		if _, ok := src.table.ColumnMap[name]; ok {
+			found = append(found, src)
+		}
+	}
+
+	switch {
+	case len(found) == 1:
+		return sc.key(found[0], name), found[0].table.ColumnMap[name], nil
+	case len(found) > 1:
+		return "", storage.Column{}, fmt.Errorf("column reference %s is ambiguous", ref)
+	case qual != "" && !knownQual:
+		return "", storage.Column{}, fmt.Errorf("missing FROM entry for table %s", qual)
+	case len(sc.sources) == 1:
+		return "", storage.Column{}, fmt.Errorf("column %s does not exist in table %s", name, sc.sources[0].table.Name)
+	}
+	return "", storage.Column{}, fmt.Errorf("column %s does not exist", ref)
+}
+
+// source finds the source a qualifier names, for `alias.*`.
+func (sc *scope) source(alias string) (source, bool) {
+	for _, src := range sc.sources {
+		if src.alias == alias {
+			return src, true
+		}
+	}
+	return source{}, false
+}
+
+// splitColumnRef splits "u.name" into "u" and "name"; an unqualified
+// reference has an empty qualifier.
+func splitColumnRef(ref string) (qual, name string) {
+	if i := strings.LastIndexByte(ref, '.'); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+	return "", ref
+}
+
+func aliasOr(alias, name string) string {
+	if alias != "" {
+		return alias
+	}
+	return name
+}
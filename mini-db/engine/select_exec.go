@@ -2,48 +2,465 @@ package engine
 
 import (
 	"errors"
+	"fastabiz-mini-rdbms/mini-db/core"
 	"fastabiz-mini-rdbms/mini-db/storage"
 	"fmt"
 )
 
-func (e *Engine) Select(cmd SelectCommand) ([]storage.Row, error) {
+// outputColumn is a result column: its name, its type and the key it is
+// read from in the rows being projected. agg is set for aggregates.
+type outputColumn struct {
+	name   string
+	source string
+	typ    core.DataType
+	agg    *Aggregate
+}
+
+// rowSet is the result of a SELECT before it is handed out: the rows are
+// keyed by result column name.
+type rowSet struct {
+	columns []outputColumn
+	rows    []storage.Row
+}
+
+func (rs *rowSet) result() *core.Result {
+	result := &core.Result{Rows: make([]core.Row, 0, len(rs.rows))}
+	for _, col := range rs.columns {
+		result.Columns = append(result.Columns, col.name)
+	}
+	for _, row := range rs.rows {
+		result.Rows = append(result.Rows, core.Row(row))
+	}
+	return result
+}
+
+func (e *Engine) Select(cmd SelectCommand) (*core.Result, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	rs, err := e.query(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return rs.result(), nil
+}
+
+// query runs one SELECT without taking the engine lock.
+func (e *Engine) query(cmd SelectCommand) (*rowSet, error) {
+	op, columns, err := e.planSelect(cmd)
+	if err != nil {
+		return nil, err
+	}
+	defer op.Close()
+
+	if err := op.Open(); err != nil {
+		return nil, err
+	}
+	rows, err := drain(op)
+	if err != nil {
+		return nil, err
+	}
+	return &rowSet{columns: columns, rows: rows}, nil
+}
+
+// planSelect builds the operator tree for a SELECT, bottom up: the scans
+// and joins of the FROM clause, WHERE, grouping and HAVING, ORDER BY,
+// OFFSET/LIMIT and finally the projection onto the result columns.
+func (e *Engine) planSelect(cmd SelectCommand) (operator, []outputColumn, error) {
+	limit := -1
+	if cmd.Limit != nil {
+		limit = *cmd.Limit
+	}
+
+	// DISTINCT has to see every row before ORDER BY and LIMIT, which then
+	// apply to the distinct result columns rather than the source rows.
+	if cmd.Distinct {
+		inner := cmd
+		inner.Distinct, inner.OrderBy, inner.Limit, inner.Offset = false, nil, nil, 0
+
+		op, columns, err := e.planSelect(inner)
+		if err != nil {
+			return nil, nil, err
+		}
+		rs := &rowSet{columns: columns}
+		op = &distinctOp{input: op, cols: rs.names()}
+
+		if len(cmd.OrderBy) > 0 {
+			terms, err := rs.resolveOrder(cmd.OrderBy)
+			if err != nil {
+				return nil, nil, err
+			}
+			op = newSortOp(op, terms, cmd.Offset, limit)
+		}
+		return withLimit(op, cmd.Offset, limit), columns, nil
+	}
+
+	sc, err := e.fromScope(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	columns, err := selectColumns(sc, cmd.Columns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	where, err := bindWhere(sc, cmd.Where)
+	if err != nil {
+		return nil, nil, err
+	}
+	joins, err := bindJoins(sc, cmd.Joins)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if isAggregate(cmd) {
+		op, _ := scanPlan(sc, joins, where, nil, false)
+		if op, err = planGroups(sc, cmd, op, columns, limit); err != nil {
+			return nil, nil, err
+		}
+		return &projectOp{input: op, columns: columns}, columns, nil
+	}
+
+	order, err := resolveOrder(sc, cmd.OrderBy, columns, func(string) error { return nil })
+	if err != nil {
+		return nil, nil, err
+	}
+	op, sorted := scanPlan(sc, joins, where, order, limit >= 0)
+	if len(order) > 0 && !sorted {
+		op = newSortOp(op, order, cmd.Offset, limit)
+	}
+	op = withLimit(op, cmd.Offset, limit)
+
+	return &projectOp{input: op, columns: columns}, columns, nil
+}
+
+// scanPlan reads the rows of the FROM clause that pass where. A single
+// table is read through an index when where pins or bounds its columns,
+// or when the index order is the order rows are wanted in (see
+// chooseAccess); sorted then reports that they come out in that order.
+// Joins are applied left to right to a scan of the first table.
+func scanPlan(sc *scope, joins []boundJoin, where Expr, order []OrderTerm, limited bool) (op operator, sorted bool) {
+	first := sc.sources[0]
+
+	op = &seqScanOp{sc: sc, src: first}
+	if len(joins) == 0 {
+		if path, inOrder, ok := chooseAccess(first.table, where, order, limited); ok {
+			op, sorted = &indexScanOp{sc: sc, src: first, path: path}, inOrder
+		}
+	}
+
+	leftCols := sc.keys(first)
+	for _, join := range joins {
+		j := newJoinOp(sc, op, join, leftCols)
+		op, leftCols = j, append(leftCols, j.rightCols...)
+	}
+
+	if where == nil {
+		return op, sorted
+	}
+	return &filterOp{input: op, pred: where}, sorted
+}
+
+// newSortOp sorts by terms; with a LIMIT only the first offset+limit rows
+// are kept while sorting.
+func newSortOp(input operator, terms []OrderTerm, offset, limit int) operator {
+	n := -1
+	if limit >= 0 {
+		n = offset + limit
+	}
+	return &sortOp{input: input, terms: terms, limit: n}
+}
+
+func withLimit(input operator, offset, limit int) operator {
+	if offset == 0 && limit < 0 {
+		return input
+	}
+	return &limitOp{input: input, offset: offset, limit: limit}
+}
+
+// finish applies ORDER BY, OFFSET and LIMIT; the order terms must name
+// result columns.
+func (rs *rowSet) finish(order []OrderTerm, offset, limit int) error {
+	if len(order) > 0 {
+		terms, err := rs.resolveOrder(order)
+		if err != nil {
+			return err
+		}
+		if rs.rows, err = sortRows(rs.rows, terms); err != nil {
+			return err
+		}
+	}
+
+	rs.rows = window(rs.rows, offset, limit)
+	return nil
+}
+
+func (rs *rowSet) names() []string {
+	names := make([]string, len(rs.columns))
+	for i, col := range rs.columns {
+		names[i] = col.name
+	}
+	return names
+}
+
+// resolveOrder points ORDER BY terms at result columns, matching either the
+// result name or, for an unaliased reference, the column it came from.
+func (rs *rowSet) resolveOrder(terms []OrderTerm) ([]OrderTerm, error) {
+	resolved := make([]OrderTerm, 0, len(terms))
+	for _, term := range terms {
+		found := false
+		for _, col := range rs.columns {
+			if col.name == term.Column {
+				found = true
+				break
+			}
+		}
+		if !found {
+			for _, col := range rs.columns {
+				if col.source == term.Column {
+					term.Column, found = col.name, true
+					break
+				}
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("ORDER BY %s must appear in the select list", term.Column)
+		}
+		term.Agg = nil
+		resolved = append(resolved, term)
+	}
+	return resolved, nil
+}
+
+func isAggregate(cmd SelectCommand) bool {
+	if len(cmd.GroupBy) > 0 || cmd.Having != nil {
+		return true
+	}
+	for _, item := range cmd.Columns {
+		if item.Agg != nil {
+			return true
+		}
+	}
+	for _, term := range cmd.OrderBy {
+		if term.Agg != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// planGroups plans an aggregate query: it hash-aggregates the matching
+// rows, then applies HAVING, ORDER BY and the LIMIT window to the groups.
+// Every plain column in the select list, HAVING or ORDER BY has to be one
+// of the GROUP BY columns.
+func planGroups(sc *scope, cmd SelectCommand, input operator, columns []outputColumn, limit int) (operator, error) {
+	grouped := make(map[string]bool, len(cmd.GroupBy))
+	groupBy := make([]string, len(cmd.GroupBy))
+	for i, col := range cmd.GroupBy {
+		key, _, err := sc.resolve(col)
+		if err != nil {
+			return nil, err
+		}
+		grouped[key], groupBy[i] = true, key
+	}
+	notGrouped := func(col string) error {
+		return fmt.Errorf("column %s must appear in GROUP BY or be used in an aggregate", col)
+	}
+
+	// Collect every aggregate the query needs, once each.
+	var aggs []Aggregate
+	seen := make(map[string]bool)
+	need := func(agg Aggregate) {
+		if !seen[agg.String()] {
+			seen[agg.String()] = true
+			aggs = append(aggs, agg)
+		}
+	}
+
+	for _, col := range columns {
+		switch {
+		case col.agg != nil:
+			need(*col.agg)
+		case !grouped[col.source]:
+			return nil, notGrouped(col.name)
+		}
+	}
+
+	having, err := bindExpr(sc, cmd.Having)
+	if err != nil {
+		return nil, err
+	}
+	err = walkExpr(having, func(e Expr) error {
+		switch x := e.(type) {
+		case *AggregateExpr:
+			need(x.Aggregate)
+		case *ColumnRef:
+			if !grouped[x.Name] {
+				return notGrouped(x.Name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := resolveOrder(sc, cmd.OrderBy, columns, func(key string) error {
+		if !grouped[key] {
+			return notGrouped(key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, term := range order {
+		if term.Agg != nil {
+			need(*term.Agg)
+		}
+	}
+
+	var op operator = &aggregateOp{input: input, groupBy: groupBy, aggs: aggs}
+	if having != nil {
+		op = &filterOp{input: op, pred: having}
+	}
+	if len(order) > 0 {
+		op = newSortOp(op, order, cmd.Offset, limit)
+	}
+	return withLimit(op, cmd.Offset, limit), nil
+}
+
+// fromScope looks up the tables of the FROM clause. Each needs a distinct
+// name, so a table joined to itself must be given an alias.
+func (e *Engine) fromScope(cmd SelectCommand) (*scope, error) {
 	table, exists := e.Tables[cmd.TableName]
 	if !exists {
 		return nil, errors.New("table does not exist")
 	}
+	if len(cmd.Joins) == 0 {
+		return tableScope(table, cmd.Alias), nil
+	}
 
-	var result []storage.Row
-	for _, row := range table.Rows {
-		// Where filter
-		if cmd.Where != nil && cmd.Where.Column == table.PrimaryKey && table.PKIndex != nil {
-			rowID, ok := table.PKIndex.Get(cmd.Where.Value)
-			if !ok {
-				return []storage.Row{}, nil // No matching rows
+	sources := []source{{alias: aliasOr(cmd.Alias, table.Name), table: table}}
+	for _, join := range cmd.Joins {
+		table, exists := e.Tables[join.Table]
+		if !exists {
+			return nil, fmt.Errorf("table %s does not exist", join.Table)
+		}
+
+		alias := aliasOr(join.Alias, table.Name)
+		for _, src := range sources {
+			if src.alias == alias {
+				return nil, fmt.Errorf("table name %s specified more than once; use an alias", alias)
 			}
+		}
+		sources = append(sources, source{alias: alias, table: table})
+	}
+	return newScope(sources, true), nil
+}
+
+// selectColumns resolves the select list to result columns in output
+// order. * (or an empty list) expands to every column of every table, and
+// alias.* to those of one table; in a join they are named alias.column.
+// Every result column needs a name of its own.
+func selectColumns(sc *scope, items []SelectItem) ([]outputColumn, error) {
+	if len(items) == 0 {
+		items = []SelectItem{{Column: "*"}}
+	}
 
-			row := table.Rows[storage.RowID(rowID)]
-			return []storage.Row{row}, nil
+	expand := func(columns []outputColumn, src source) []outputColumn {
+		for _, c := range src.table.Columns {
+			key := sc.key(src, c.Name)
+			columns = append(columns, outputColumn{name: key, source: key, typ: c.Type})
 		}
+		return columns
+	}
 
-		// Projection
-		projected := storage.Row{}
+	var columns []outputColumn
+	for _, item := range items {
+		qual, name := splitColumnRef(item.Column)
 
-		if len(cmd.Columns) == 0 {
-			// SELECT *
-			for k, v := range row {
-				projected[k] = v
+		switch {
+		case item.Agg != nil:
+			agg, err := bindAggregate(sc, *item.Agg)
+			if err != nil {
+				return nil, err
 			}
-		} else {
-			for _, col := range cmd.Columns {
-				if _, ok := table.ColumnMap[col]; !ok {
-					return nil, fmt.Errorf("column %s does not exist in table %s", col, cmd.TableName)
-				}
-				projected[col] = row[col]
+			typ := core.IntType
+			if col, ok := sc.columns[agg.Column]; ok {
+				typ = agg.resultType(col.Type)
+			}
+			columns = append(columns, outputColumn{name: item.Name(), source: agg.String(), typ: typ, agg: &agg})
+
+		case item.Column == "*":
+			for _, src := range sc.sources {
+				columns = expand(columns, src)
+			}
+
+		case name == "*":
+			src, ok := sc.source(qual)
+			if !ok {
+				return nil, fmt.Errorf("missing FROM entry for table %s", qual)
 			}
+			columns = expand(columns, src)
+
+		default:
+			key, col, err := sc.resolve(item.Column)
+			if err != nil {
+				return nil, err
+			}
+			columns = append(columns, outputColumn{name: item.Name(), source: key, typ: col.Type})
 		}
+	}
 
-		result = append(result, projected)
+	// Result rows are keyed by column name, so two columns of the same
+	// name would overwrite each other.
+	seen := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		if seen[col.name] {
+			return nil, fmt.Errorf("column name %s specified more than once; use an alias", col.name)
+		}
+		seen[col.name] = true
 	}
+	return columns, nil
+}
 
-	return result, nil
+// resolveOrder maps ORDER BY terms onto the keys of the rows being sorted.
+// A term may name a result column, e.g. by its alias, or a column in
+// scope, which check may reject.
+func resolveOrder(sc *scope, terms []OrderTerm, columns []outputColumn, check func(key string) error) ([]OrderTerm, error) {
+	resolved := make([]OrderTerm, 0, len(terms))
+
+next:
+	for _, term := range terms {
+		if term.Agg != nil {
+			agg, err := bindAggregate(sc, *term.Agg)
+			if err != nil {
+				return nil, err
+			}
+			term.Column, term.Agg = agg.String(), &agg
+			resolved = append(resolved, term)
+			continue
+		}
+
+		for _, col := range columns {
+			if col.name == term.Column {
+				term.Column = col.source
+				resolved = append(resolved, term)
+				continue next
+			}
+		}
+
+		key, _, err := sc.resolve(term.Column)
+		if err != nil {
+			return nil, err
+		}
+		if err := check(key); err != nil {
+			return nil, err
+		}
+		term.Column = key
+		resolved = append(resolved, term)
+	}
+	return resolved, nil
 }
@@ -0,0 +1,146 @@
+package engine
+
+import (
+	"fmt"
+
+	"fastabiz-mini-rdbms/mini-db/core"
+	"fastabiz-mini-rdbms/mini-db/storage"
+)
+
+// SelectCompound runs SELECTs combined with UNION, UNION ALL, INTERSECT and
+// EXCEPT. Every SELECT must produce the same number of columns, with
+// pairwise comparable types; the result takes the first SELECT's column
+// names. Apart from UNION ALL the operators return distinct rows.
+func (e *Engine) SelectCompound(cmd CompoundSelectCommand) (*core.Result, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(cmd.Selects) == 0 || len(cmd.Ops) != len(cmd.Selects)-1 {
+		return nil, fmt.Errorf("compound select needs one operator between each pair of SELECTs")
+	}
+
+	branches := make([]*rowSet, len(cmd.Selects))
+	for i, sel := range cmd.Selects {
+		rs, err := e.query(sel)
+		if err != nil {
+			return nil, err
+		}
+		branches[i] = rs
+	}
+
+	columns, err := unionColumns(branches, cmd.Ops)
+	if err != nil {
+		return nil, err
+	}
+	for _, rs := range branches {
+		if err := rs.conform(columns); err != nil {
+			return nil, err
+		}
+	}
+
+	combined := branches[0]
+	for i, op := range cmd.Ops {
+		combined.rows = combineRows(op, combined.rows, branches[i+1].rows, combined.names())
+	}
+
+	limit := -1
+	if cmd.Limit != nil {
+		limit = *cmd.Limit
+	}
+	if err := combined.finish(cmd.OrderBy, cmd.Offset, limit); err != nil {
+		return nil, err
+	}
+	return combined.result(), nil
+}
+
+// unionColumns checks that the branches line up and works out the type of
+// each result column: numbers widen to DECIMAL, or FLOAT if any branch has
+// a FLOAT, and a DATE meeting a TIMESTAMP becomes a TIMESTAMP.
+func unionColumns(branches []*rowSet, ops []SetOp) ([]outputColumn, error) {
+	columns := append([]outputColumn(nil), branches[0].columns...)
+
+	for i, rs := range branches[1:] {
+		if len(rs.columns) != len(columns) {
+			return nil, fmt.Errorf("each %s query must have the same number of columns: %d and %d",
+				ops[i], len(columns), len(rs.columns))
+		}
+
+		for j, col := range rs.columns {
+			typ, ok := unionType(columns[j].typ, col.typ)
+			if !ok {
+				return nil, fmt.Errorf("%s column %d: cannot combine %s with %s", ops[i], j+1, columns[j].typ, col.typ)
+			}
+			columns[j].typ = typ
+		}
+	}
+	return columns, nil
+}
+
+func unionType(a, b core.DataType) (core.DataType, bool) {
+	switch {
+	case a == b:
+		return a, true
+	case !core.Comparable(a, b):
+		return "", false
+	case a == core.FloatType || b == core.FloatType:
+		return core.FloatType, true
+	case core.IsNumeric(a):
+		return core.DecimalType, true
+	}
+	return core.TimestampType, true
+}
+
+// conform renames the rows' columns to the given result columns, position
+// by position, and converts the values to their types.
+func (rs *rowSet) conform(columns []outputColumn) error {
+	for i, row := range rs.rows {
+		out := make(storage.Row, len(columns))
+		for j, col := range columns {
+			val, err := core.Coerce(row[rs.columns[j].name], col.typ)
+			if err != nil {
+				return err
+			}
+			out[col.name] = val
+		}
+		rs.rows[i] = out
+	}
+	rs.columns = columns
+	return nil
+}
+
+// combineRows applies one set operator. Rows are compared on every column,
+// with NULLs equal to each other as in DISTINCT.
+func combineRows(op SetOp, left, right []storage.Row, cols []string) []storage.Row {
+	switch op {
+	case SetUnionAll:
+		return append(left, right...)
+	case SetUnion:
+		return distinctRows(append(left, right...), cols)
+	}
+
+	inRight := make(map[string]bool, len(right))
+	for _, row := range right {
+		inRight[groupKey(row, cols)] = true
+	}
+
+	keep := op == SetIntersect
+	var out []storage.Row
+	for _, row := range distinctRows(left, cols) {
+		if inRight[groupKey(row, cols)] == keep {
+			out = append(out, row)
+		}
+	}
+	return out
+}
+
+// distinctRows filters rows the way distinctOp does.
+func distinctRows(rows []storage.Row, cols []string) []storage.Row {
+	d := distinctOp{cols: cols, seen: make(map[string]bool, len(rows))}
+	kept := rows[:0]
+	for _, row := range rows {
+		if d.first(row) {
+			kept = append(kept, row)
+		}
+	}
+	return kept
+}
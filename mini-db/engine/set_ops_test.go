@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSetOperations(t *testing.T) {
+	e := NewEngine()
+	mustRun(t, e,
+		"CREATE TABLE a (id INT PRIMARY KEY, v TEXT, n INT)",
+		"INSERT INTO a (id, v, n) VALUES (1, 'x', 1)",
+		"INSERT INTO a (id, v, n) VALUES (2, 'y', 2)",
+		"INSERT INTO a (id, v, n) VALUES (3, 'y', 2)",
+		"INSERT INTO a (id, v, n) VALUES (4, NULL, NULL)",
+		"INSERT INTO a (id, v, n) VALUES (5, NULL, NULL)",
+		"CREATE TABLE b (id INT PRIMARY KEY, w TEXT, f FLOAT)",
+		"INSERT INTO b (id, w, f) VALUES (1, 'y', 2)",
+		"INSERT INTO b (id, w, f) VALUES (2, 'z', 3.5)",
+		"INSERT INTO b (id, w, f) VALUES (3, NULL, NULL)",
+	)
+
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"SELECT DISTINCT v FROM a ORDER BY v", []string{"<nil>", "x", "y"}},
+		{"SELECT DISTINCT v, n FROM a ORDER BY v DESC", []string{"y 2", "x 1", "<nil> <nil>"}},
+		{"SELECT DISTINCT v FROM a ORDER BY v LIMIT 2 OFFSET 1", []string{"x", "y"}},
+		{"SELECT DISTINCT n FROM a WHERE n > 1", []string{"2"}},
+
+		{"SELECT v FROM a UNION SELECT w FROM b ORDER BY v", []string{"<nil>", "x", "y", "z"}},
+		{"SELECT v FROM a UNION ALL SELECT w FROM b ORDER BY v LIMIT 4", []string{"<nil>", "<nil>", "<nil>", "x"}},
+		{"SELECT v FROM a INTERSECT SELECT w FROM b ORDER BY v", []string{"<nil>", "y"}},
+		{"SELECT v FROM a EXCEPT SELECT w FROM b", []string{"x"}},
+		{"SELECT w FROM b EXCEPT SELECT v FROM a", []string{"z"}},
+		{"SELECT v FROM a UNION SELECT w FROM b EXCEPT SELECT v FROM a WHERE id = 1 ORDER BY v DESC", []string{"z", "y", "<nil>"}},
+		{"SELECT v, n FROM a WHERE id < 3 UNION SELECT w, f FROM b WHERE id = 1 ORDER BY v", []string{"x 1", "y 2"}},
+		{"SELECT n FROM a UNION SELECT f FROM b ORDER BY n DESC LIMIT 2", []string{"3.5", "2"}},
+	}
+	for _, tt := range tests {
+		if got := query(t, e, tt.sql); !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+
+	if got := query(t, e, "SELECT v FROM a UNION ALL SELECT w FROM b"); len(got) != 8 {
+		t.Errorf("UNION ALL returned %d rows, want 8", len(got))
+	}
+
+	for _, sql := range []string{
+		"SELECT v, n FROM a UNION SELECT w FROM b",
+		"SELECT v FROM a UNION SELECT f FROM b",
+		"SELECT v FROM a INTERSECT SELECT nope FROM b",
+		"SELECT v FROM a UNION SELECT w FROM b ORDER BY w",
+		"SELECT v FROM a UNION w",
+		"SELECT v FROM a EXCEPT ALL SELECT w FROM b",
+	} {
+		mustFail(t, e, sql)
+	}
+}
@@ -0,0 +1,225 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"fastabiz-mini-rdbms/mini-db/storage"
+)
+
+const (
+	snapshotPrefix = "snapshot-"
+	snapshotSuffix = ".db"
+)
+
+// snapshot is the on-disk image of every table as of LSN. Indexes are
+// stored as their definitions only; they are rebuilt from the rows on
+// load.
+//
+// Disk tables only record their schema: their rows already live in the
+// table's heap file, which is flushed before the snapshot is written.
+type snapshot struct {
+	LSN    uint64
+	Tables []tableSnapshot
+}
+
+type tableSnapshot struct {
+	Name        string
+	Columns     []storage.Column
+	PrimaryKey  []string
+	Engine      storage.EngineKind
+	File        string
+	Rows        map[storage.RowID]storage.Row
+	NextRowID   storage.RowID
+	AutoInc     int
+	Indexes     []CreateIndexCommand
+	ForeignKeys []storage.ForeignKey
+	Checks      []storage.CheckConstraint
+}
+
+// createCommand is the schema of a snapshot table, as the command that
+// builds the table again. The columns' PRIMARY KEY marks are dropped, as
+// the key is given in full by PrimaryKey.
+func (ts tableSnapshot) createCommand() CreateTableCommand {
+	return CreateTableCommand{
+		TableName:   ts.Name,
+		Columns:     unmarkPrimary(ts.Columns),
+		PrimaryKey:  ts.PrimaryKey,
+		ForeignKeys: ts.ForeignKeys,
+		Checks:      ts.Checks,
+		Engine:      ts.Engine,
+	}
+}
+
+func snapshotPath(dir string, lsn uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%s%020d%s", snapshotPrefix, lsn, snapshotSuffix))
+}
+
+// snapshotFiles lists the snapshot files in dir, newest first.
+func snapshotFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, snapshotPrefix+"*"+snapshotSuffix))
+	if err != nil {
+		return nil, err
+	}
+	// The zero-padded LSN in the name makes lexical order match LSN order.
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+	return files, nil
+}
+
+// writeSnapshot captures the current tables as of lsn. The file is written
+// under a temporary name and renamed into place, so a crash mid-write
+// never leaves a half-written snapshot behind.
+func (e *Engine) writeSnapshot(lsn uint64) error {
+	snap := snapshot{LSN: lsn}
+	for _, table := range e.Tables {
+		ts := tableSnapshot{
+			Name:        table.Name,
+			Columns:     table.Columns,
+			PrimaryKey:  table.PrimaryKey,
+			Engine:      table.Engine,
+			File:        table.File,
+			AutoInc:     table.AutoInc,
+			ForeignKeys: table.ForeignKeys,
+			Checks:      table.Checks,
+		}
+		for _, ix := range table.Indexes {
+			if ix.Constraint {
+				continue // made again by newTable
+			}
+			ts.Indexes = append(ts.Indexes, CreateIndexCommand{
+				IndexName: ix.Name,
+				TableName: table.Name,
+				Columns:   ix.Columns,
+				Unique:    ix.Unique,
+			})
+		}
+
+		if mem, ok := table.Store.(*storage.MemStore); ok {
+			ts.NextRowID = mem.NextRowID()
+			ts.Rows = make(map[storage.RowID]storage.Row, mem.Count())
+			err := storage.ForEach(mem, func(id storage.RowID, row storage.Row) error {
+				ts.Rows[id] = row
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		} else if err := table.Store.Flush(); err != nil {
+			return err
+		}
+
+		snap.Tables = append(snap.Tables, ts)
+	}
+
+	path := snapshotPath(e.dir, lsn)
+	tmp := path + ".tmp"
+
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	if err := gob.NewEncoder(w).Encode(&snap); err != nil {
+		file.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return syncDir(e.dir)
+}
+
+// loadSnapshot restores the newest snapshot in dir, if any, and returns
+// the LSN it was taken at.
+func (e *Engine) loadSnapshot() (uint64, error) {
+	files, err := snapshotFiles(e.dir)
+	if err != nil || len(files) == 0 {
+		return 0, err
+	}
+
+	file, err := os.Open(files[0])
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var snap snapshot
+	if err := gob.NewDecoder(bufio.NewReader(file)).Decode(&snap); err != nil {
+		return 0, fmt.Errorf("snapshot %s: %w", filepath.Base(files[0]), err)
+	}
+	// Disk stores roll back to the state they had at this checkpoint.
+	e.checkpointLSN = snap.LSN
+
+	for _, ts := range snap.Tables {
+		table, err := newTable(ts.createCommand())
+		if err != nil {
+			return 0, err
+		}
+		table.File = ts.File
+		if err := e.openStore(table); err != nil {
+			return 0, err
+		}
+
+		if mem, ok := table.Store.(*storage.MemStore); ok {
+			for rowID, row := range ts.Rows {
+				if err := mem.Insert(rowID, row, snap.LSN); err != nil {
+					return 0, err
+				}
+			}
+			mem.SetNextRowID(ts.NextRowID)
+		}
+		table.AutoInc = ts.AutoInc
+		for _, cmd := range ts.Indexes {
+			table.Indexes = append(table.Indexes, newSecondaryIndex(cmd))
+		}
+
+		e.Tables[table.Name] = table
+	}
+
+	return snap.LSN, nil
+}
+
+// removeSnapshotsBefore deletes snapshots older than lsn once a newer one
+// is safely on disk.
+func (e *Engine) removeSnapshotsBefore(lsn uint64) error {
+	files, err := snapshotFiles(e.dir)
+	if err != nil {
+		return err
+	}
+
+	keep := snapshotPath(e.dir, lsn)
+	for _, f := range files {
+		if f < keep {
+			if err := os.Remove(f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
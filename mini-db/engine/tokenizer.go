@@ -31,10 +31,17 @@ const (
 
 	// Operators
 	EQ     TokenType = "="
+	NEQ    TokenType = "<>"
+	LT     TokenType = "<"
+	LTE    TokenType = "<="
+	GT     TokenType = ">"
+	GTE    TokenType = ">="
+	MINUS  TokenType = "-"
 	COMMA  TokenType = ","
 	STAR   TokenType = "*"
 	LPAREN TokenType = "("
 	RPAREN TokenType = ")"
+	SEMI   TokenType = ";"
 
 	// Keywords
 	SELECT TokenType = "SELECT"
@@ -51,6 +58,41 @@ const (
 	JOIN   TokenType = "JOIN"
 	ON     TokenType = "ON"
 	DOT    TokenType = "DOT"
+
+	NULL  TokenType = "NULL"
+	TRUE  TokenType = "TRUE"
+	FALSE TokenType = "FALSE"
+	IS    TokenType = "IS"
+	NOT   TokenType = "NOT"
+
+	AND     TokenType = "AND"
+	OR      TokenType = "OR"
+	IN      TokenType = "IN"
+	BETWEEN TokenType = "BETWEEN"
+	LIKE    TokenType = "LIKE"
+
+	DISTINCT TokenType = "DISTINCT"
+	AS       TokenType = "AS"
+	GROUP    TokenType = "GROUP"
+	HAVING   TokenType = "HAVING"
+
+	INNER TokenType = "INNER"
+	OUTER TokenType = "OUTER"
+	CROSS TokenType = "CROSS"
+
+	UNION     TokenType = "UNION"
+	INTERSECT TokenType = "INTERSECT"
+	EXCEPT    TokenType = "EXCEPT"
+
+	ORDER TokenType = "ORDER"
+	BY    TokenType = "BY"
+	ASC   TokenType = "ASC"
+	DESC  TokenType = "DESC"
+	LIMIT TokenType = "LIMIT"
+
+	EXPLAIN TokenType = "EXPLAIN"
+	DROP    TokenType = "DROP"
+	ALTER   TokenType = "ALTER"
 )
 
 var keywords = map[string]TokenType{
@@ -68,6 +110,41 @@ var keywords = map[string]TokenType{
 	"join":   JOIN,
 	"on":     ON,
 	"dot":    DOT,
+
+	"null":  NULL,
+	"true":  TRUE,
+	"false": FALSE,
+	"is":    IS,
+	"not":   NOT,
+
+	"and":     AND,
+	"or":      OR,
+	"in":      IN,
+	"between": BETWEEN,
+	"like":    LIKE,
+
+	"distinct": DISTINCT,
+	"as":       AS,
+	"group":    GROUP,
+	"having":   HAVING,
+
+	"inner": INNER,
+	"outer": OUTER,
+	"cross": CROSS,
+
+	"union":     UNION,
+	"intersect": INTERSECT,
+	"except":    EXCEPT,
+
+	"order": ORDER,
+	"by":    BY,
+	"asc":   ASC,
+	"desc":  DESC,
+	"limit": LIMIT,
+
+	"explain": EXPLAIN,
+	"drop":    DROP,
+	"alter":   ALTER,
 }
 
 func NewTokenizer(input string) *Tokenizer {
@@ -96,7 +173,6 @@ func Tokenize(input string) ([]Token, error) {
 	return tokens, nil
 }
 
-
 func (t *Tokenizer) NextToken() Token {
 	t.skipWhitespace()
 
@@ -105,6 +181,43 @@ func (t *Tokenizer) NextToken() Token {
 		tok := Token{Type: EQ, Literal: "="}
 		t.readChar()
 		return tok
+	case '<':
+		t.readChar()
+		switch t.ch {
+		case '=':
+			t.readChar()
+			return Token{Type: LTE, Literal: "<="}
+		case '>':
+			t.readChar()
+			return Token{Type: NEQ, Literal: "<>"}
+		}
+		return Token{Type: LT, Literal: "<"}
+	case '>':
+		t.readChar()
+		if t.ch == '=' {
+			t.readChar()
+			return Token{Type: GTE, Literal: ">="}
+		}
+		return Token{Type: GT, Literal: ">"}
+	case '!':
+		t.readChar()
+		if t.ch == '=' {
+			t.readChar()
+			return Token{Type: NEQ, Literal: "!="}
+		}
+		return Token{Type: ILLEGAL, Literal: "!"}
+	case ';':
+		tok := Token{Type: SEMI, Literal: ";"}
+		t.readChar()
+		return tok
+	case '-':
+		tok := Token{Type: MINUS, Literal: "-"}
+		t.readChar()
+		return tok
+	case '.':
+		tok := Token{Type: DOT, Literal: "."}
+		t.readChar()
+		return tok
 	case ',':
 		tok := Token{Type: COMMA, Literal: ","}
 		t.readChar()
@@ -163,26 +276,39 @@ func (t *Tokenizer) readIdentifier() string {
 	return t.input[start : t.pos-1]
 }
 
+// readNumber reads an integer or a decimal number such as 12.50.
 func (t *Tokenizer) readNumber() string {
 	start := t.pos - 1
 	for isDigit(t.ch) {
 		t.readChar()
 	}
+	if t.ch == '.' && t.pos < len(t.input) && isDigit(t.input[t.pos]) {
+		t.readChar() // consume '.'
+		for isDigit(t.ch) {
+			t.readChar()
+		}
+	}
 	return t.input[start : t.pos-1]
 }
 
 func (t *Tokenizer) readString() Token {
 	t.readChar() // skip opening quote
-	start := t.pos - 1
 
-	for t.ch != '\'' && t.ch != 0 {
+	// A doubled quote stands for a quote: 'it''s'.
+	var lit strings.Builder
+	for t.ch != 0 {
+		if t.ch == '\'' {
+			if t.pos >= len(t.input) || t.input[t.pos] != '\'' {
+				break
+			}
+			t.readChar()
+		}
+		lit.WriteByte(t.ch)
 		t.readChar()
 	}
-
-	lit := t.input[start : t.pos-1]
 	t.readChar() // skip closing quote
 
-	return Token{Type: STRING, Literal: lit}
+	return Token{Type: STRING, Literal: lit.String()}
 }
 
 func lookupIdent(ident string) TokenType {
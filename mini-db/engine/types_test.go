@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTypedValues(t *testing.T) {
+	e := NewEngine()
+	mustRun(t, e,
+		"CREATE TABLE t (id INT PRIMARY KEY, s TEXT)",
+		"INSERT INTO t (id, s) VALUES (1, 'a')",
+		"INSERT INTO t (id, s) VALUES ('2', 'b')",
+	)
+
+	for _, sql := range []string{
+		"INSERT INTO t (id, s) VALUES ('abc', 'c')",
+		"INSERT INTO t (id, s) VALUES (3, 4)",
+		"INSERT INTO t (id, x) VALUES (3, 'c')",
+		"INSERT INTO t (id, s) VALUES ('1', 'c')",
+		"UPDATE t SET s = 5 WHERE id = 1",
+		"SELECT id FROM t WHERE id = 'x'",
+		"DELETE FROM t WHERE s = 1",
+	} {
+		mustFail(t, e, sql)
+	}
+
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"SELECT id, s FROM t WHERE id = 2", []string{"2 b"}},
+		{"SELECT id, s FROM t WHERE id = '1'", []string{"1 a"}},
+		{"SELECT id FROM t WHERE s = 'b'", []string{"2"}},
+	}
+	for _, tt := range tests {
+		if got := query(t, e, tt.sql); !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestColumnTypes(t *testing.T) {
+	dir := t.TempDir()
+	e := openEngine(t, dir)
+	mustRun(t, e,
+		"CREATE TABLE t (id INT PRIMARY KEY, b BOOLEAN, f FLOAT, d DECIMAL(5,2), dt DATE, ts TIMESTAMP) ENGINE = disk",
+		"INSERT INTO t (id, b, f, d, dt, ts) VALUES (1, TRUE, 2.5, 123.456, DATE '2024-01-31', TIMESTAMP '2024-01-31 10:30:00')",
+		"INSERT INTO t (id, b, f, d, dt, ts) VALUES (2, 'f', -1, '-0.005', '2024-02-29', '2024-02-29')",
+		"INSERT INTO t (id, b, f, d) VALUES (3, false, '1e3', 7)",
+	)
+
+	for _, sql := range []string{
+		"INSERT INTO t (id) VALUES (9223372036854775808)",
+		"INSERT INTO t (id) VALUES (1.5)",
+		"INSERT INTO t (id, b) VALUES (4, 'maybe')",
+		"INSERT INTO t (id, b) VALUES (4, 1)",
+		"INSERT INTO t (id, f) VALUES (4, 'x')",
+		"INSERT INTO t (id, d) VALUES (4, 1000)",
+		"INSERT INTO t (id, d) VALUES (4, 999.995)",
+		"INSERT INTO t (id, dt) VALUES (4, '2023-02-29')",
+		"INSERT INTO t (id, ts) VALUES (4, 'noon')",
+		"INSERT INTO t (id, ts) VALUES (4, 5)",
+		"UPDATE t SET d = 12345 WHERE id = 1",
+		"CREATE TABLE u (d DECIMAL(20,2))",
+		"CREATE TABLE u (d DECIMAL(2,3))",
+	} {
+		mustFail(t, e, sql)
+	}
+
+	const sql = "SELECT id, b, f, d, dt, ts FROM t ORDER BY id"
+	want := []string{
+		"1 true 2.5 123.46 2024-01-31 2024-01-31 10:30:00",
+		"2 false -1 -0.01 2024-02-29 2024-02-29 00:00:00",
+		"3 false 1000 7.00 <nil> <nil>",
+	}
+	checkRecovered(t, e, sql, want)
+	e.Close()
+
+	e = openEngine(t, dir)
+	defer e.Close()
+	checkRecovered(t, e, sql, want)
+
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"SELECT id FROM t WHERE b = TRUE", []string{"1"}},
+		{"SELECT id FROM t WHERE f = 1000", []string{"3"}},
+		{"SELECT id FROM t WHERE d = 7", []string{"3"}},
+		{"SELECT id FROM t WHERE d = '123.46'", []string{"1"}},
+		{"SELECT id FROM t WHERE dt = '2024-01-31'", []string{"1"}},
+		{"SELECT id FROM t WHERE ts = DATE '2024-02-29'", []string{"2"}},
+	}
+	for _, tt := range tests {
+		if got := query(t, e, tt.sql); !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
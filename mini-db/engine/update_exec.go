@@ -6,52 +6,67 @@ import (
 )
 
 func (e *Engine) Update(cmd UpdateCommand) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	table, ok := e.Tables[cmd.TableName]
 	if !ok {
 		return 0, errors.New("table does not exist")
 	}
 
 	// Prevent Primary Key update
-	if _, exists := cmd.Set[table.PrimaryKey]; exists {
-		return 0, errors.New("cannot update primary key")
+	for _, name := range table.PrimaryKey {
+		if _, exists := cmd.Set[name]; exists {
+			return 0, errors.New("cannot update primary key")
+		}
 	}
 
-	if cmd.Where != nil &&
-		cmd.Where.Column == table.PrimaryKey &&
-		table.PKIndex != nil {
-		return e.deleteByPk(table, cmd.Where.Value), nil
+	set, err := bindValues(table, cmd.Set)
+	if err != nil {
+		return 0, err
+	}
+	where, err := bindWhere(tableScope(table, ""), cmd.Where)
+	if err != nil {
+		return 0, err
 	}
 
-	return e.updateByScan(table, cmd), nil
-}
+	var changes []rowChange
+	err = forEachMatch(table, where, func(rowID storage.RowID, row storage.Row) error {
+		changes = append(changes, updateChange(table, rowID, row, set))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
 
-func (e *Engine) updateByPK(table *storage.Table, cmd UpdateCommand) int {
-  rowID, ok := table.PKIndex.Get(cmd.Where.Value)
-  if !ok {
-    return 0
-  }
+	if len(changes) == 0 {
+		return 0, nil
+	}
+	n := len(changes)
 
-  row := table.Rows[storage.RowID(rowID)]
-  for col, val := range cmd.Set {
-    row[col] = val
-  }
+	if changes, err = e.cascade(changes); err != nil {
+		return 0, err
+	}
+	if err := e.checkChanges(changes); err != nil {
+		return 0, err
+	}
 
-  table.Rows[storage.RowID(rowID)] = row
-  return 1
+	if err := e.commit(&logRecord{Kind: recWrite, Changes: changes}); err != nil {
+		return 0, err
+	}
+	return n, nil
 }
 
-func (e *Engine) updateByScan(table *storage.Table, cmd UpdateCommand) int {
-	updated := 0
-
-	for rowID, row := range table.Rows {
-		if row[cmd.Where.Column] == cmd.Where.Value {
-			for col, val := range cmd.Set {
-				row[col] = val
-			}
-			table.Rows[rowID] = row
-			updated++
-		}
+// updateChange builds the new row image without touching the stored row,
+// so nothing changes until the record has been logged.
+func updateChange(table *storage.Table, rowID storage.RowID, row storage.Row, set map[string]any) rowChange {
+	updated := make(storage.Row, len(row))
+	for col, val := range row {
+		updated[col] = val
+	}
+	for col, val := range set {
+		updated[col] = val
 	}
 
-	return updated
-}
\ No newline at end of file
+	return rowChange{Kind: changeUpdate, Table: table.Name, RowID: rowID, Row: updated}
+}
@@ -0,0 +1,179 @@
+package engine
+
+import (
+	"fastabiz-mini-rdbms/mini-db/index"
+	"fastabiz-mini-rdbms/mini-db/storage"
+)
+
+// matches reports whether row passes the filter. Predicates follow SQL's
+// three-valued logic and UNKNOWN is treated like false, as a WHERE clause
+// does; so `col = NULL` never matches and IS NULL must be used instead.
+func matches(where Expr, row storage.Row) (bool, error) {
+	if where == nil {
+		return true, nil
+	}
+
+	val, err := where.Eval(row)
+	if err != nil {
+		return false, err
+	}
+	val, err = truth(val)
+	return val == true, err
+}
+
+// forEachMatch calls fn for every row of table that satisfies where. When
+// the expression pins the columns of an index to a few values, or bounds
+// its leading columns, the rows are fetched through that index instead of
+// scanning the table; either way the whole expression is checked against
+// each row.
+func forEachMatch(table *storage.Table, where Expr, fn func(storage.RowID, storage.Row) error) error {
+	filter := func(rowID storage.RowID, row storage.Row) error {
+		ok, err := matches(where, row)
+		if err != nil || !ok {
+			return err
+		}
+		return fn(rowID, row)
+	}
+
+	path, _, ok := chooseAccess(table, where, nil, false)
+	if !ok {
+		return storage.ForEach(table.Store, filter)
+	}
+
+	it := path.open()
+	for {
+		rowID, ok := it.next()
+		if !ok {
+			return nil
+		}
+		row, ok, err := table.Store.Get(storage.RowID(rowID))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if err := filter(storage.RowID(rowID), row); err != nil {
+			return err
+		}
+	}
+}
+
+// maxIndexKeys caps the keys a lookup on a multi-column index may expand
+// to, e.g. a IN (1, 2, 3) AND b IN (4, 5, 6) gives nine.
+const maxIndexKeys = 1000
+
+// chooseIndex picks the index of table that narrows where down to the
+// fewest keys, the primary key winning ties. Every column of the index has
+// to be limited to a finite set of values (see columnValues). ok is false
+// when the table has to be scanned.
+func chooseIndex(table *storage.Table, where Expr) (best tableIndex, keys []index.Key, ok bool) {
+	for _, ix := range tableIndexes(table) {
+		ixKeys, usable := indexKeys(table, ix.columns, where)
+		if usable && (!ok || len(ixKeys) < len(keys)) {
+			best, keys, ok = ix, ixKeys, true
+		}
+	}
+	return best, keys, ok
+}
+
+// indexKeys returns the keys of an index over cols that a row must have to
+// satisfy where: every combination of the values its columns may take.
+func indexKeys(table *storage.Table, cols []string, where Expr) ([]index.Key, bool) {
+	combos := []storage.Row{{}}
+	for _, col := range cols {
+		vals, ok := columnValues(table.ColumnMap[col], where)
+		if !ok || len(combos)*len(vals) > maxIndexKeys {
+			return nil, false
+		}
+
+		next := make([]storage.Row, 0, len(combos)*len(vals))
+		for _, combo := range combos {
+			for _, val := range vals {
+				row := make(storage.Row, len(combo)+1)
+				for k, v := range combo {
+					row[k] = v
+				}
+				row[col] = val
+				next = append(next, row)
+			}
+		}
+		combos = next
+	}
+
+	keys := make([]index.Key, len(combos))
+	for i, combo := range combos {
+		keys[i] = indexKey(combo, cols)
+	}
+	return keys, true
+}
+
+// columnValues returns the values col must have in a row that satisfies
+// where, if the expression limits them to a finite set: `col = v`,
+// `col IN (...)`, either side of an AND, or an OR whose sides both
+// qualify. ok is false otherwise.
+func columnValues(col storage.Column, where Expr) (vals []any, ok bool) {
+	switch x := where.(type) {
+	case *BinaryExpr:
+		switch x.Op {
+		case EQ:
+			if isColumn(col, x.Left) {
+				return literalValues(col, x.Right)
+			}
+			if isColumn(col, x.Right) {
+				return literalValues(col, x.Left)
+			}
+
+		case AND:
+			if vals, ok := columnValues(col, x.Left); ok {
+				return vals, true
+			}
+			return columnValues(col, x.Right)
+
+		case OR:
+			left, ok := columnValues(col, x.Left)
+			if !ok {
+				return nil, false
+			}
+			right, ok := columnValues(col, x.Right)
+			if !ok {
+				return nil, false
+			}
+			return append(left, right...), true
+		}
+
+	case *InExpr:
+		if x.Not || !isColumn(col, x.Expr) {
+			return nil, false
+		}
+		return literalValues(col, x.List...)
+	}
+
+	return nil, false
+}
+
+func isColumn(col storage.Column, expr Expr) bool {
+	ref, ok := expr.(*ColumnRef)
+	return ok && ref.Name == col.Name
+}
+
+// literalValues turns constant operands into values of col's type. A value
+// that cannot be in col (NULL, or 1.5 against an INT column) matches no row
+// and is dropped; an operand that is not a constant makes the lookup
+// unusable.
+func literalValues(col storage.Column, exprs ...Expr) ([]any, bool) {
+	var vals []any
+	for _, expr := range exprs {
+		lit, ok := expr.(*Literal)
+		if !ok {
+			return nil, false
+		}
+		if lit.Value == nil {
+			continue
+		}
+		if val, ok := keyValue(col, lit.Value); ok {
+			vals = append(vals, val)
+		}
+	}
+	return vals, true
+}
@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNulls(t *testing.T) {
+	e := NewEngine()
+	mustRun(t, e,
+		"CREATE TABLE t (id INT PRIMARY KEY, s TEXT, n INT)",
+		"INSERT INTO t (id, s, n) VALUES (1, 'a', 10)",
+		"INSERT INTO t (id) VALUES (2)",
+		"INSERT INTO t (id, s, n) VALUES (3, NULL, 30)",
+		"INSERT INTO t (id, s, n) VALUES (4, 'd', NULL)",
+	)
+	mustFail(t, e, "INSERT INTO t (id, s) VALUES (NULL, 'x')")
+	mustFail(t, e, "INSERT INTO t (s) VALUES ('x')")
+
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"SELECT id, s, n FROM t WHERE id = 2", []string{"2 <nil> <nil>"}},
+		{"SELECT id FROM t WHERE s IS NULL ORDER BY id", []string{"2", "3"}},
+		{"SELECT id FROM t WHERE s IS NOT NULL ORDER BY id", []string{"1", "4"}},
+		{"SELECT id FROM t WHERE n IS NULL ORDER BY id", []string{"2", "4"}},
+		{"SELECT id FROM t WHERE s = NULL", []string{}},
+		{"SELECT id FROM t WHERE n = 30", []string{"3"}},
+		{"SELECT id FROM t WHERE id = NULL", []string{}},
+	}
+	for _, tt := range tests {
+		if got := query(t, e, tt.sql); !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+
+	// A NULL never equals anything, so these write nothing.
+	mustRun(t, e,
+		"UPDATE t SET n = 0 WHERE s = NULL",
+		"DELETE FROM t WHERE n = NULL",
+		"UPDATE t SET s = NULL WHERE id = 1",
+		"DELETE FROM t WHERE n IS NULL",
+	)
+	if got, want := query(t, e, "SELECT id, s, n FROM t ORDER BY id"), []string{"1 <nil> 10", "3 <nil> 30"}; !slices.Equal(got, want) {
+		t.Errorf("after writes: %q, want %q", got, want)
+	}
+}
+
+func TestWhereExpressions(t *testing.T) {
+	e := NewEngine()
+	mustRun(t, e,
+		"CREATE TABLE t (id INT PRIMARY KEY, s TEXT, n INT)",
+		"INSERT INTO t (id, s, n) VALUES (1, 'apple', 10)",
+		"INSERT INTO t (id, s, n) VALUES (2, 'banana', 20)",
+		"INSERT INTO t (id, s, n) VALUES (3, NULL, 30)",
+		"INSERT INTO t (id, s, n) VALUES (4, 'cherry', NULL)",
+		"INSERT INTO t (id, s, n) VALUES (5, 'a_b%', 50)",
+	)
+
+	tests := []struct {
+		where string
+		want  []string
+	}{
+		{"n > 15 AND n < 40", []string{"2", "3"}},
+		{"n < 15 OR s = 'cherry'", []string{"1", "4"}},
+		{"n <> 20", []string{"1", "3", "5"}},
+		{"n != 20", []string{"1", "3", "5"}},
+		{"NOT n = 10", []string{"2", "3", "5"}},
+		{"(id = 1 OR id = 2) AND n >= 20", []string{"2"}},
+		{"id = 1 OR n = 30", []string{"1", "3"}},
+		{"id = 1 AND n = 20", []string{}},
+		{"id <= 2", []string{"1", "2"}},
+
+		{"n IN (10, 30)", []string{"1", "3"}},
+		{"n IN (10, NULL)", []string{"1"}},
+		{"n NOT IN (10, 20)", []string{"3", "5"}},
+		{"n NOT IN (10, NULL)", []string{}},
+		{"NOT (n IN (10, NULL))", []string{}},
+		{"s IN ('apple', 'cherry')", []string{"1", "4"}},
+
+		{"n BETWEEN 20 AND 30", []string{"2", "3"}},
+		{"n NOT BETWEEN 20 AND 30", []string{"1", "5"}},
+		{"n BETWEEN NULL AND 30", []string{}},
+		{"n NOT BETWEEN NULL AND 30", []string{"5"}},
+
+		{"s LIKE 'a%'", []string{"1", "5"}},
+		{"s LIKE '_a%'", []string{"2"}},
+		{"s LIKE '%e%'", []string{"1", "4"}},
+		{"s LIKE 'apple'", []string{"1"}},
+		{"s NOT LIKE '%an%'", []string{"1", "4", "5"}},
+		{"s LIKE NULL", []string{}},
+
+		{"s IS NULL OR n IS NULL", []string{"3", "4"}},
+		{"NOT (s IS NULL OR n IS NULL)", []string{"1", "2", "5"}},
+		{"n = 30 OR s = 'x'", []string{"3"}},
+		{"NOT (n = 30 OR s = 'x')", []string{"1", "2", "5"}},
+	}
+	for _, tt := range tests {
+		sql := "SELECT id FROM t WHERE " + tt.where + " ORDER BY id"
+		if got := query(t, e, sql); !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %q, want %q", sql, got, tt.want)
+		}
+	}
+
+	for _, sql := range []string{
+		"SELECT id FROM t WHERE s LIKE 5",
+		"SELECT id FROM t WHERE n < 'abc'",
+		"SELECT id FROM t WHERE n NOT 5",
+		"SELECT id FROM t WHERE (n = 1",
+		"SELECT id FROM t WHERE x = 1",
+	} {
+		mustFail(t, e, sql)
+	}
+
+	mustRun(t, e,
+		"UPDATE t SET s = 'x' WHERE n BETWEEN 10 AND 20",
+		"DELETE FROM t WHERE id IN (1, 4) OR s LIKE 'a_b%'",
+	)
+	if got, want := query(t, e, "SELECT id, s, n FROM t ORDER BY id"), []string{"2 x 20", "3 <nil> 30"}; !slices.Equal(got, want) {
+		t.Errorf("after writes: %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,302 @@
+package index
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"sort"
+)
+
+// maxEntries is the most entries a leaf, or separators an internal node,
+// holds before it splits. Nodes other than the root keep at least half
+// that many.
+const maxEntries = 64
+
+const minEntries = maxEntries / 2
+
+// entry is a key and a row holding it. Entries order by key and then by
+// row ID, so the rows sharing a key of a non-unique index are entries of
+// their own.
+type entry struct {
+	key   Key
+	rowID int
+}
+
+func compareEntries(a, b entry) int {
+	if c := CompareKeys(a.key, b.key); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.rowID, b.rowID)
+}
+
+// node is a B+tree node. Leaves hold the entries and are chained in key
+// order. An internal node holds separators: children[i] holds the entries
+// below entries[i], and children[i+1] those from it on.
+type node struct {
+	leaf       bool
+	entries    []entry
+	children   []*node
+	prev, next *node
+}
+
+// BTree is an ordered index. Besides key lookups it serves range scans,
+// scans on a prefix of a multi-column key and scans in key order, which
+// let queries skip sorting.
+type BTree struct {
+	unique bool
+	root   *node
+}
+
+func NewBTree(unique bool) *BTree {
+	return &BTree{unique: unique, root: &node{leaf: true}}
+}
+
+// Insert adds an entry. A unique index refuses a second row for a key
+// unless the key holds a NULL, which equals nothing.
+func (t *BTree) Insert(key any, rowID int) error {
+	k := asKey(key)
+	if t.unique && !k.HasNull() {
+		if _, exists := t.Get(k); exists {
+			return errors.New("duplicate key")
+		}
+	}
+
+	if sep, right := t.insert(t.root, entry{k, rowID}); right != nil {
+		t.root = &node{entries: []entry{sep}, children: []*node{t.root, right}}
+	}
+	return nil
+}
+
+// insert adds e below n. If n splits, it returns the new right half and
+// the separator for it.
+func (t *BTree) insert(n *node, e entry) (entry, *node) {
+	i := childIndex(n, e)
+	if n.leaf {
+		n.entries = slices.Insert(n.entries, i, e)
+	} else {
+		sep, right := t.insert(n.children[i], e)
+		if right == nil {
+			return entry{}, nil
+		}
+		n.entries = slices.Insert(n.entries, i, sep)
+		n.children = slices.Insert(n.children, i+1, right)
+	}
+
+	if len(n.entries) <= maxEntries {
+		return entry{}, nil
+	}
+	return n.split()
+}
+
+// childIndex counts the entries of n that sort at or before e: where e
+// goes in a leaf, and the child holding it in an internal node.
+func childIndex(n *node, e entry) int {
+	return sort.Search(len(n.entries), func(i int) bool {
+		return compareEntries(n.entries[i], e) > 0
+	})
+}
+
+func (n *node) split() (entry, *node) {
+	mid := len(n.entries) / 2
+
+	if n.leaf {
+		right := &node{leaf: true, entries: slices.Clone(n.entries[mid:]), prev: n, next: n.next}
+		if n.next != nil {
+			n.next.prev = right
+		}
+		n.next = right
+		n.entries = n.entries[:mid:mid]
+		return right.entries[0], right
+	}
+
+	// The middle separator moves up to the parent.
+	sep := n.entries[mid]
+	right := &node{entries: slices.Clone(n.entries[mid+1:]), children: slices.Clone(n.children[mid+1:])}
+	n.entries = n.entries[:mid:mid]
+	n.children = n.children[: mid+1 : mid+1]
+	return sep, right
+}
+
+func (t *BTree) Get(key any) (int, bool) {
+	_, rowID, ok := t.Scan(Range{Low: asKey(key), High: asKey(key)}, false).Next()
+	return rowID, ok
+}
+
+func (t *BTree) Lookup(key any) []int {
+	var rowIDs []int
+	it := t.Scan(Range{Low: asKey(key), High: asKey(key)}, false)
+	for {
+		_, rowID, ok := it.Next()
+		if !ok {
+			return rowIDs
+		}
+		rowIDs = append(rowIDs, rowID)
+	}
+}
+
+func (t *BTree) Delete(key any, rowID int) {
+	t.delete(t.root, entry{asKey(key), rowID})
+	if !t.root.leaf && len(t.root.entries) == 0 {
+		t.root = t.root.children[0]
+	}
+}
+
+// delete removes e from below n, reporting whether it was there.
+func (t *BTree) delete(n *node, e entry) bool {
+	if n.leaf {
+		i, found := slices.BinarySearchFunc(n.entries, e, compareEntries)
+		if found {
+			n.entries = slices.Delete(n.entries, i, i+1)
+		}
+		return found
+	}
+
+	i := childIndex(n, e)
+	if !t.delete(n.children[i], e) {
+		return false
+	}
+	if len(n.children[i].entries) < minEntries {
+		n.rebalance(i)
+	}
+	return true
+}
+
+// rebalance refills the child i of n, which has dropped below minEntries,
+// by borrowing from a sibling or else merging with one.
+func (n *node) rebalance(i int) {
+	if i > 0 && len(n.children[i-1].entries) > minEntries {
+		n.borrowLeft(i)
+		return
+	}
+	if i+1 < len(n.children) && len(n.children[i+1].entries) > minEntries {
+		n.borrowRight(i)
+		return
+	}
+	if i > 0 {
+		i--
+	}
+	n.merge(i)
+}
+
+func (n *node) borrowLeft(i int) {
+	left, child := n.children[i-1], n.children[i]
+	last := len(left.entries) - 1
+
+	if child.leaf {
+		child.entries = slices.Insert(child.entries, 0, left.entries[last])
+		n.entries[i-1] = child.entries[0]
+	} else {
+		child.entries = slices.Insert(child.entries, 0, n.entries[i-1])
+		child.children = slices.Insert(child.children, 0, left.children[last+1])
+		n.entries[i-1] = left.entries[last]
+		left.children = left.children[:last+1]
+	}
+	left.entries = left.entries[:last]
+}
+
+func (n *node) borrowRight(i int) {
+	child, right := n.children[i], n.children[i+1]
+
+	if child.leaf {
+		child.entries = append(child.entries, right.entries[0])
+		right.entries = slices.Delete(right.entries, 0, 1)
+		n.entries[i] = right.entries[0]
+		return
+	}
+	child.entries = append(child.entries, n.entries[i])
+	child.children = append(child.children, right.children[0])
+	n.entries[i] = right.entries[0]
+	right.entries = slices.Delete(right.entries, 0, 1)
+	right.children = slices.Delete(right.children, 0, 1)
+}
+
+// merge folds child i+1 of n into child i.
+func (n *node) merge(i int) {
+	left, right := n.children[i], n.children[i+1]
+
+	if left.leaf {
+		left.entries = append(left.entries, right.entries...)
+		left.next = right.next
+		if right.next != nil {
+			right.next.prev = left
+		}
+	} else {
+		left.entries = append(append(left.entries, n.entries[i]), right.entries...)
+		left.children = append(left.children, right.children...)
+	}
+	n.entries = slices.Delete(n.entries, i, i+1)
+	n.children = slices.Delete(n.children, i+1, i+2)
+}
+
+// Scan seeks to the first entry of r, or the last when desc, and then
+// follows the leaf chain until it leaves the range.
+func (t *BTree) Scan(r Range, desc bool) Iterator {
+	// The entries that satisfy one bound form a run at that end of the
+	// tree, so the separators tell which child the run starts in.
+	start := r.aboveLow
+	if desc {
+		start = func(k Key) bool { return !r.belowHigh(k) }
+	}
+
+	n := t.root
+	for !n.leaf {
+		n = n.children[sort.Search(len(n.entries), func(i int) bool { return start(n.entries[i].key) })]
+	}
+	pos := sort.Search(len(n.entries), func(i int) bool { return start(n.entries[i].key) })
+	if desc {
+		pos--
+	}
+	return &btreeIterator{r: r, desc: desc, leaf: n, pos: pos}
+}
+
+type btreeIterator struct {
+	r    Range
+	desc bool
+	leaf *node
+	pos  int
+}
+
+func (it *btreeIterator) Next() (Key, int, bool) {
+	for it.leaf != nil {
+		if it.pos < 0 || it.pos >= len(it.leaf.entries) {
+			it.step()
+			continue
+		}
+
+		e := it.leaf.entries[it.pos]
+		if it.desc {
+			it.pos--
+		} else {
+			it.pos++
+		}
+
+		// Entries before the start of the range may still come up at the
+		// edge of a leaf; the first entry past its end finishes the scan.
+		near, far := it.r.aboveLow, it.r.belowHigh
+		if it.desc {
+			near, far = far, near
+		}
+		if !near(e.key) {
+			continue
+		}
+		if !far(e.key) {
+			it.leaf = nil
+			break
+		}
+		return e.key, e.rowID, true
+	}
+	return nil, 0, false
+}
+
+// step moves to the neighbouring leaf in scan order.
+func (it *btreeIterator) step() {
+	if it.desc {
+		it.leaf = it.leaf.prev
+		if it.leaf != nil {
+			it.pos = len(it.leaf.entries) - 1
+		}
+		return
+	}
+	it.leaf = it.leaf.next
+	it.pos = 0
+}
@@ -0,0 +1,242 @@
+package index
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// checkTree verifies the B+tree invariants: sorted nodes, separators that
+// split their children, every leaf at the same depth, non-root nodes at
+// least half full and a leaf chain visiting every entry in order.
+func checkTree(t *testing.T, tree *BTree) []entry {
+	t.Helper()
+
+	leafDepth := -1
+	var walk func(n *node, depth int, low, high *entry)
+	walk = func(n *node, depth int, low, high *entry) {
+		if n != tree.root && len(n.entries) < minEntries {
+			t.Fatalf("node at depth %d holds %d entries, want at least %d", depth, len(n.entries), minEntries)
+		}
+		if len(n.entries) > maxEntries {
+			t.Fatalf("node at depth %d holds %d entries, want at most %d", depth, len(n.entries), maxEntries)
+		}
+		for i, e := range n.entries {
+			if i > 0 && compareEntries(n.entries[i-1], e) >= 0 {
+				t.Fatalf("node entries out of order at %d", i)
+			}
+			if low != nil && compareEntries(e, *low) < 0 || high != nil && compareEntries(e, *high) >= 0 {
+				t.Fatalf("entry %v outside its separators", e)
+			}
+		}
+
+		if n.leaf {
+			if leafDepth >= 0 && depth != leafDepth {
+				t.Fatalf("leaves at depths %d and %d", leafDepth, depth)
+			}
+			leafDepth = depth
+			return
+		}
+		if len(n.children) != len(n.entries)+1 {
+			t.Fatalf("internal node with %d separators has %d children", len(n.entries), len(n.children))
+		}
+		for i, child := range n.children {
+			lo, hi := low, high
+			if i > 0 {
+				lo = &n.entries[i-1]
+			}
+			if i < len(n.entries) {
+				hi = &n.entries[i]
+			}
+			walk(child, depth+1, lo, hi)
+		}
+	}
+	walk(tree.root, 0, nil, nil)
+
+	first := tree.root
+	for !first.leaf {
+		first = first.children[0]
+	}
+	var all []entry
+	for n := first; n != nil; n = n.next {
+		if n.next != nil && n.next.prev != n {
+			t.Fatal("leaf chain is not linked both ways")
+		}
+		all = append(all, n.entries...)
+	}
+	if !slices.IsSortedFunc(all, compareEntries) {
+		t.Fatal("leaf chain out of order")
+	}
+	return all
+}
+
+func scanAll(it Iterator) []entry {
+	var got []entry
+	for key, rowID, ok := it.Next(); ok; key, rowID, ok = it.Next() {
+		got = append(got, entry{key, rowID})
+	}
+	return got
+}
+
+func TestBTreeRandom(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	tree := NewBTree(false)
+	var model []entry
+
+	for i := 0; i < 20000; i++ {
+		if len(model) == 0 || rng.IntN(3) > 0 {
+			e := entry{Key{int64(rng.IntN(2000))}, i}
+			if err := tree.Insert(e.key, e.rowID); err != nil {
+				t.Fatal(err)
+			}
+			model = append(model, e)
+		} else {
+			j := rng.IntN(len(model))
+			tree.Delete(model[j].key, model[j].rowID)
+			model = slices.Delete(model, j, j+1)
+		}
+	}
+	slices.SortFunc(model, compareEntries)
+
+	if got := checkTree(t, tree); !slices.EqualFunc(got, model, entriesEqual) {
+		t.Fatalf("tree holds %d entries, want %d", len(got), len(model))
+	}
+
+	// Delete everything again so the tree merges back down to a leaf.
+	for _, e := range model {
+		tree.Delete(e.key, e.rowID)
+	}
+	if got := checkTree(t, tree); len(got) != 0 || !tree.root.leaf {
+		t.Fatalf("emptied tree still holds %d entries", len(got))
+	}
+}
+
+func entriesEqual(a, b entry) bool { return compareEntries(a, b) == 0 }
+
+func TestBTreeRange(t *testing.T) {
+	tree := NewBTree(true)
+	for i := 0; i < 1000; i++ {
+		if err := tree.Insert(int64(i), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		r        Range
+		from, to int // expected keys, from <= k < to
+	}{
+		{Range{}, 0, 1000},
+		{Range{Low: Key{int64(100)}, High: Key{int64(200)}}, 100, 201},
+		{Range{Low: Key{int64(100)}, High: Key{int64(200)}, LowExclusive: true, HighExclusive: true}, 101, 200},
+		{Range{Low: Key{int64(990)}}, 990, 1000},
+		{Range{High: Key{int64(5)}, HighExclusive: true}, 0, 5},
+		{Range{Low: Key{int64(5000)}}, 0, 0},
+	}
+	for _, tt := range tests {
+		for _, desc := range []bool{false, true} {
+			var want []int
+			for k := tt.from; k < tt.to; k++ {
+				want = append(want, k)
+			}
+			if desc {
+				slices.Reverse(want)
+			}
+
+			var got []int
+			for _, e := range scanAll(tree.Scan(tt.r, desc)) {
+				got = append(got, e.rowID)
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("Scan(%+v, desc=%v) returned %d rows, want %d", tt.r, desc, len(got), len(want))
+			}
+		}
+	}
+}
+
+func TestBTreePrefix(t *testing.T) {
+	tree := NewBTree(false)
+	id := 0
+	for _, city := range []string{"ams", "nbo", "nyc"} {
+		for age := int64(20); age < 80; age++ {
+			if err := tree.Insert(Key{city, age}, id); err != nil {
+				t.Fatal(err)
+			}
+			id++
+		}
+	}
+
+	got := scanAll(tree.Scan(Range{Low: Key{"nbo"}, High: Key{"nbo"}}, false))
+	if len(got) != 60 || got[0].key[1] != int64(20) || got[59].key[1] != int64(79) {
+		t.Fatalf("prefix scan for nbo returned %d entries", len(got))
+	}
+	for _, e := range got {
+		if e.key[0] != "nbo" {
+			t.Fatalf("prefix scan for nbo returned %v", e.key)
+		}
+	}
+
+	got = scanAll(tree.Scan(Range{Low: Key{"nbo", int64(30)}, High: Key{"nbo", int64(40)}, HighExclusive: true}, true))
+	if len(got) != 10 || got[0].key[1] != int64(39) {
+		t.Fatalf("backward range scan returned %v", got)
+	}
+}
+
+func TestBTreeUnique(t *testing.T) {
+	tree := NewBTree(true)
+	if err := tree.Insert(int64(1), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.Insert(int64(1), 2); err == nil {
+		t.Fatal("second row for a unique key was accepted")
+	}
+
+	// NULL equals nothing, so it may repeat.
+	for rowID := 3; rowID < 5; rowID++ {
+		if err := tree.Insert(Key{nil}, rowID); err != nil {
+			t.Fatalf("NULL key: %v", err)
+		}
+	}
+
+	if rowID, ok := tree.Get(int64(1)); !ok || rowID != 1 {
+		t.Fatalf("Get(1) = %d, %v", rowID, ok)
+	}
+	tree.Delete(int64(1), 1)
+	if _, ok := tree.Get(int64(1)); ok {
+		t.Fatal("deleted key still found")
+	}
+}
+
+func TestCompareKeys(t *testing.T) {
+	tests := []struct {
+		a, b Key
+		want int
+	}{
+		{Key{nil}, Key{int64(0)}, -1},
+		{Key{int64(1)}, Key{int64(1), "a"}, -1},
+		{Key{int64(2)}, Key{int64(1), "a"}, 1},
+		{Key{"a", int64(1)}, Key{"a", int64(1)}, 0},
+		{Key{int64(1)}, Key{1.5}, -1},
+	}
+	for _, tt := range tests {
+		if got := cmp.Compare(CompareKeys(tt.a, tt.b), 0); got != tt.want {
+			t.Errorf("CompareKeys(%v, %v) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func ExampleBTree_Scan() {
+	tree := NewBTree(false)
+	for i, name := range []string{"cy", "al", "bo", "al"} {
+		tree.Insert(name, i)
+	}
+	it := tree.Scan(Range{Low: Key{"al"}, High: Key{"bo"}}, false)
+	for key, rowID, ok := it.Next(); ok; key, rowID, ok = it.Next() {
+		fmt.Println(key[0], rowID)
+	}
+	// Output:
+	// al 1
+	// al 3
+	// bo 2
+}
@@ -1,11 +1,109 @@
 package index
 
+import (
+	"strings"
+
+	"fastabiz-mini-rdbms/mini-db/core"
+)
+
+// Index maps keys to the row IDs of the rows holding them. A unique index
+// holds at most one row per key; others may hold several.
 type Index interface {
 	Insert(key any, rowID int) error
 	Get(key any) (int, bool)
-	Delete(key any)
+	Lookup(key any) []int
+	Delete(key any, rowID int)
+
+	// Scan walks the entries within r in key order, backwards if desc.
+	Scan(r Range, desc bool) Iterator
+}
+
+// Iterator steps through the entries of a scan. The index must not be
+// changed while one is in use.
+type Iterator interface {
+	Next() (key Key, rowID int, ok bool)
+}
+
+// Key is an index key: the values of the indexed columns, in index order.
+// Keys order column by column, with NULL before every other value.
+type Key []any
+
+func (k Key) HasNull() bool {
+	for _, v := range k {
+		if v == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Range bounds a scan. A bound may be a prefix of the index key: over
+// (city, age), Low {"nyc"} starts at the first key for "nyc" and High
+// {"nyc"} ends after the last one. A nil bound leaves that end open.
+type Range struct {
+	Low, High     Key
+	LowExclusive  bool
+	HighExclusive bool
+}
+
+func (r Range) aboveLow(k Key) bool {
+	if r.Low == nil {
+		return true
+	}
+	c := comparePrefix(k, r.Low)
+	return c > 0 || c == 0 && !r.LowExclusive
+}
+
+func (r Range) belowHigh(k Key) bool {
+	if r.High == nil {
+		return true
+	}
+	c := comparePrefix(k, r.High)
+	return c < 0 || c == 0 && !r.HighExclusive
 }
 
-// Insert → update index
-// Delete → remove from index
-// Lookup → O(1)
+// CompareKeys orders two keys; a key sorts before any longer key it is a
+// prefix of.
+func CompareKeys(a, b Key) int {
+	if c := comparePrefix(a, b[:min(len(a), len(b))]); c != 0 {
+		return c
+	}
+	return len(a) - len(b)
+}
+
+// comparePrefix compares the first len(prefix) values of k with prefix.
+func comparePrefix(k, prefix Key) int {
+	for i, p := range prefix {
+		if c := compareValues(k[i], p); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareValues(a, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+
+	c, err := core.Compare(a, b)
+	if err != nil {
+		// The values of one column share a type, so this only orders
+		// stray keys consistently.
+		return strings.Compare(core.TypeName(a), core.TypeName(b))
+	}
+	return c
+}
+
+// asKey accepts a single value for a one-column key.
+func asKey(key any) Key {
+	if k, ok := key.(Key); ok {
+		return k
+	}
+	return Key{key}
+}
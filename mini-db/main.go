@@ -1,11 +1,29 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
 	"fastabiz-mini-rdbms/mini-db/engine"
 	"fastabiz-mini-rdbms/mini-db/repl"
 )
 
 func main() {
-	db := engine.NewEngine()
+	dataDir := flag.String("data", "data", "directory holding the write-ahead log and snapshots")
+	poolPages := flag.Int("pages", engine.DefaultBufferPoolPages, "buffer pool size in 4 KiB pages")
+	checkpointEvery := flag.Duration("checkpoint", 5*time.Minute, "interval between background checkpoints (0 disables)")
+	flag.Parse()
+
+	db, err := engine.OpenWithOptions(*dataDir, engine.Options{BufferPoolPages: *poolPages})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "open database:", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	db.StartCheckpointer(*checkpointEvery)
+
 	repl.New(db).Run()
 }
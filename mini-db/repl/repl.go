@@ -3,11 +3,12 @@ package repl
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
+	"fastabiz-mini-rdbms/mini-db/core"
 	"fastabiz-mini-rdbms/mini-db/engine"
-	"fastabiz-mini-rdbms/mini-db/storage"
 )
 
 type REPL struct {
@@ -22,13 +23,17 @@ func (r *REPL) Run() {
 	reader := bufio.NewReader(os.Stdin)
 
 	fmt.Println("Fastabiz Mini RDBMS")
-	fmt.Println("Type 'exit' to quit")
+	fmt.Println("Type 'exit' to quit, 'stats' for buffer pool counters")
 	fmt.Println()
 
 	for {
 		fmt.Print("fastabiz> ")
 
 		input, err := reader.ReadString('\n')
+		if err == io.EOF {
+			fmt.Println()
+			return
+		}
 		if err != nil {
 			fmt.Println("read error:", err)
 			continue
@@ -43,6 +48,10 @@ func (r *REPL) Run() {
 			fmt.Println("bye 👋")
 			return
 		}
+		if input == "stats" {
+			r.printStats()
+			continue
+		}
 
 		r.handleInput(input)
 	}
@@ -78,6 +87,24 @@ func (r *REPL) execute(cmd any) error {
 		}
 		fmt.Println("OK")
 
+	case *engine.CreateIndexCommand:
+		if err := r.engine.CreateIndex(*c); err != nil {
+			return err
+		}
+		fmt.Println("OK")
+
+	case *engine.DropIndexCommand:
+		if err := r.engine.DropIndex(*c); err != nil {
+			return err
+		}
+		fmt.Println("OK")
+
+	case *engine.AlterTableCommand:
+		if err := r.engine.AlterTable(*c); err != nil {
+			return err
+		}
+		fmt.Println("OK")
+
 	case *engine.InsertCommand:
 		err := r.engine.Insert(*c)
 		if err != nil {
@@ -86,11 +113,27 @@ func (r *REPL) execute(cmd any) error {
 		fmt.Println("OK")
 
 	case *engine.SelectCommand:
-		rows, err := r.engine.Select(*c)
+		result, err := r.engine.Select(*c)
+		if err != nil {
+			return err
+		}
+		printRows(result)
+
+	case *engine.CompoundSelectCommand:
+		result, err := r.engine.SelectCompound(*c)
 		if err != nil {
 			return err
 		}
-		printRows(rows)
+		printRows(result)
+
+	case *engine.ExplainCommand:
+		lines, err := r.engine.Explain(*c)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			fmt.Println(line)
+		}
 
 	case *engine.DeleteCommand:
 		n, err := r.engine.Delete(c)
@@ -106,6 +149,12 @@ func (r *REPL) execute(cmd any) error {
 		}
 		fmt.Printf("%d row(s) updated\n", n)
 
+	case *engine.CheckpointCommand:
+		if err := r.engine.Checkpoint(); err != nil {
+			return err
+		}
+		fmt.Println("OK")
+
 	default:
 		return fmt.Errorf("unknown command")
 	}
@@ -113,16 +162,40 @@ func (r *REPL) execute(cmd any) error {
 	return nil
 }
 
-func printRows(rows []storage.Row) {
-	if len(rows) == 0 {
+func printRows(result *core.Result) {
+	if len(result.Rows) == 0 {
 		fmt.Println("(0 rows)")
 		return
 	}
-	for _, row := range rows {
+	for _, row := range result.Rows {
 		fmt.Print("{ ")
-		for k, v := range row {
-			fmt.Printf("%s:%v ", k, v)
+		for _, col := range result.Columns {
+			fmt.Printf("%s:%s ", col, formatValue(row[col]))
 		}
 		fmt.Println("}")
 	}
 }
+
+func (r *REPL) printStats() {
+	stats, ok := r.engine.BufferPoolStats()
+	if !ok {
+		fmt.Println("no buffer pool (in-memory engine)")
+		return
+	}
+
+	hitRate := 0.0
+	if total := stats.Hits + stats.Misses; total > 0 {
+		hitRate = 100 * float64(stats.Hits) / float64(total)
+	}
+
+	fmt.Printf("buffer pool: %d/%d pages resident\n", stats.Resident, stats.Capacity)
+	fmt.Printf("  hits: %d  misses: %d  hit rate: %.1f%%\n", stats.Hits, stats.Misses, hitRate)
+	fmt.Printf("  evictions: %d  page writes: %d\n", stats.Evictions, stats.Writes)
+}
+
+func formatValue(v any) string {
+	if v == nil {
+		return "NULL"
+	}
+	return fmt.Sprint(v)
+}
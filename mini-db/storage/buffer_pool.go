@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"errors"
+	"sync"
+)
+
+// MinBufferPoolPages is the smallest useful pool: a heap operation can pin
+// a row's home page and the page its moved copy lives on at the same time.
+const MinBufferPoolPages = 4
+
+var ErrNoFreeFrames = errors.New("buffer pool: every page is pinned")
+
+// PageFile is the backing store the buffer pool reads pages from and
+// writes dirty pages back to.
+type PageFile interface {
+	ReadPage(pid PageID, p *Page) error
+	WritePage(pid PageID, p *Page) error
+}
+
+// LogFlusher makes the write-ahead log durable up to an LSN. The pool calls
+// it before writing a dirty page, so no change reaches disk ahead of the
+// log record describing it.
+type LogFlusher interface {
+	FlushTo(lsn uint64) error
+}
+
+type BufferPoolStats struct {
+	Capacity  int
+	Resident  int
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Writes    uint64
+}
+
+type pageKey struct {
+	file PageFile
+	pid  PageID
+}
+
+type frame struct {
+	key   pageKey
+	page  *Page
+	used  bool
+	pins  int
+	dirty bool
+	ref   bool // clock reference bit
+}
+
+// BufferPool caches a fixed number of pages shared by every heap file,
+// evicting unpinned pages with the clock algorithm.
+type BufferPool struct {
+	mu     sync.Mutex
+	frames []frame
+	lookup map[pageKey]int
+	hand   int
+	log    LogFlusher
+
+	stats BufferPoolStats
+}
+
+func NewBufferPool(pages int, log LogFlusher) *BufferPool {
+	if pages < MinBufferPoolPages {
+		pages = MinBufferPoolPages
+	}
+
+	bp := &BufferPool{
+		frames: make([]frame, pages),
+		lookup: make(map[pageKey]int),
+		log:    log,
+	}
+	for i := range bp.frames {
+		bp.frames[i].page = &Page{}
+	}
+	bp.stats.Capacity = pages
+	return bp
+}
+
+// Fetch returns page pid of file, pinned. Every Fetch must be matched by
+// an Unpin; the page must not be used after that.
+func (bp *BufferPool) Fetch(file PageFile, pid PageID) (*Page, error) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	key := pageKey{file, pid}
+	if i, ok := bp.lookup[key]; ok {
+		f := &bp.frames[i]
+		f.pins++
+		f.ref = true
+		bp.stats.Hits++
+		return f.page, nil
+	}
+	bp.stats.Misses++
+
+	i, err := bp.victim()
+	if err != nil {
+		return nil, err
+	}
+
+	f := &bp.frames[i]
+	if err := file.ReadPage(pid, f.page); err != nil {
+		return nil, err
+	}
+
+	*f = frame{key: key, page: f.page, used: true, pins: 1, ref: true}
+	bp.lookup[key] = i
+	bp.stats.Resident++
+	return f.page, nil
+}
+
+func (bp *BufferPool) Unpin(file PageFile, pid PageID, dirty bool) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	i, ok := bp.lookup[pageKey{file, pid}]
+	if !ok {
+		return
+	}
+
+	f := &bp.frames[i]
+	if f.pins > 0 {
+		f.pins--
+	}
+	if dirty {
+		f.dirty = true
+	}
+}
+
+// Flush writes every dirty page of file back to it.
+func (bp *BufferPool) Flush(file PageFile) error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	for i := range bp.frames {
+		f := &bp.frames[i]
+		if f.used && f.key.file == file {
+			if err := bp.write(f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Drop flushes and forgets every page of file, e.g. before it is closed.
+func (bp *BufferPool) Drop(file PageFile) error {
+	if err := bp.Flush(file); err != nil {
+		return err
+	}
+
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	for i := range bp.frames {
+		f := &bp.frames[i]
+		if f.used && f.key.file == file {
+			delete(bp.lookup, f.key)
+			*f = frame{page: f.page}
+			bp.stats.Resident--
+		}
+	}
+	return nil
+}
+
+func (bp *BufferPool) Stats() BufferPoolStats {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	return bp.stats
+}
+
+// victim finds a frame to load a page into, evicting an unpinned page if
+// the pool is full.
+func (bp *BufferPool) victim() (int, error) {
+	for i := range bp.frames {
+		if !bp.frames[i].used {
+			return i, nil
+		}
+	}
+
+	// Two sweeps: the first may only be clearing reference bits.
+	for n := 0; n < 2*len(bp.frames); n++ {
+		i := bp.hand
+		bp.hand = (bp.hand + 1) % len(bp.frames)
+
+		f := &bp.frames[i]
+		if f.pins > 0 {
+			continue
+		}
+		if f.ref {
+			f.ref = false
+			continue
+		}
+
+		if err := bp.write(f); err != nil {
+			return 0, err
+		}
+		delete(bp.lookup, f.key)
+		f.used = false
+		bp.stats.Resident--
+		bp.stats.Evictions++
+		return i, nil
+	}
+
+	return 0, ErrNoFreeFrames
+}
+
+// write flushes f if it is dirty, honouring the write-ahead rule.
+func (bp *BufferPool) write(f *frame) error {
+	if !f.dirty {
+		return nil
+	}
+	if bp.log != nil {
+		if err := bp.log.FlushTo(f.page.LSN()); err != nil {
+			return err
+		}
+	}
+	if err := f.key.file.WritePage(f.key.pid, f.page); err != nil {
+		return err
+	}
+	f.dirty = false
+	bp.stats.Writes++
+	return nil
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+// memFile is a PageFile held in memory that records the order of writes.
+type memFile struct {
+	pages  map[PageID]Page
+	writes []PageID
+	log    *fakeLog
+}
+
+func newMemFile(log *fakeLog) *memFile {
+	return &memFile{pages: make(map[PageID]Page), log: log}
+}
+
+func (f *memFile) ReadPage(pid PageID, p *Page) error {
+	if page, ok := f.pages[pid]; ok {
+		*p = page
+	} else {
+		*p = *NewPage()
+	}
+	return nil
+}
+
+func (f *memFile) WritePage(pid PageID, p *Page) error {
+	if f.log != nil && p.LSN() > f.log.flushed {
+		return errors.New("page written ahead of its log record")
+	}
+	f.pages[pid] = *p
+	f.writes = append(f.writes, pid)
+	return nil
+}
+
+type fakeLog struct {
+	flushed uint64
+}
+
+func (l *fakeLog) FlushTo(lsn uint64) error {
+	l.flushed = max(l.flushed, lsn)
+	return nil
+}
+
+func fetch(t *testing.T, bp *BufferPool, f PageFile, pid PageID) *Page {
+	t.Helper()
+	p, err := bp.Fetch(f, pid)
+	if err != nil {
+		t.Fatalf("Fetch(%d): %v", pid, err)
+	}
+	return p
+}
+
+func TestBufferPoolCaches(t *testing.T) {
+	bp := NewBufferPool(MinBufferPoolPages, nil)
+	f := newMemFile(nil)
+
+	for pid := PageID(0); pid < 4; pid++ {
+		fetch(t, bp, f, pid)
+		bp.Unpin(f, pid, false)
+	}
+	fetch(t, bp, f, 2)
+	bp.Unpin(f, 2, false)
+
+	s := bp.Stats()
+	if s.Hits != 1 || s.Misses != 4 || s.Evictions != 0 || s.Resident != 4 {
+		t.Fatalf("stats after 5 fetches of 4 pages: %+v", s)
+	}
+
+	// A fifth page evicts one of the others; clean pages are not written.
+	fetch(t, bp, f, 4)
+	bp.Unpin(f, 4, false)
+	if s := bp.Stats(); s.Evictions != 1 || s.Resident != 4 || s.Writes != 0 {
+		t.Fatalf("stats after an eviction: %+v", s)
+	}
+}
+
+func TestBufferPoolWritesBack(t *testing.T) {
+	log := &fakeLog{}
+	bp := NewBufferPool(MinBufferPoolPages, log)
+	f := newMemFile(log)
+
+	// Dirty more pages than fit, each changed by a later log record, so
+	// eviction has to write pages back and flush the log first.
+	for pid := PageID(0); pid < 10; pid++ {
+		p := fetch(t, bp, f, pid)
+		p.Put(0, []byte{byte(pid)})
+		p.SetLSN(uint64(pid) + 1)
+		bp.Unpin(f, pid, true)
+	}
+	if err := bp.Flush(f); err != nil {
+		t.Fatal(err)
+	}
+	if log.flushed != 10 {
+		t.Fatalf("log flushed to %d, want 10", log.flushed)
+	}
+
+	if err := bp.Drop(f); err != nil {
+		t.Fatal(err)
+	}
+	if s := bp.Stats(); s.Resident != 0 || s.Writes != 10 {
+		t.Fatalf("stats after Drop: %+v", s)
+	}
+	for pid := PageID(0); pid < 10; pid++ {
+		p := fetch(t, bp, f, pid)
+		checkRecord(t, p, 0, []byte{byte(pid)})
+		bp.Unpin(f, pid, false)
+	}
+}
+
+func TestBufferPoolAllPinned(t *testing.T) {
+	bp := NewBufferPool(MinBufferPoolPages, nil)
+	f := newMemFile(nil)
+	for pid := PageID(0); pid < MinBufferPoolPages; pid++ {
+		fetch(t, bp, f, pid)
+	}
+
+	if _, err := bp.Fetch(f, MinBufferPoolPages); !errors.Is(err, ErrNoFreeFrames) {
+		t.Fatalf("Fetch with every page pinned returned %v", err)
+	}
+
+	// A pinned page is still found, and unpinning one frees a frame.
+	fetch(t, bp, f, 1)
+	bp.Unpin(f, 1, false)
+	bp.Unpin(f, 1, false)
+	fetch(t, bp, f, MinBufferPoolPages)
+}
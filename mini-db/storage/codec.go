@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"fastabiz-mini-rdbms/mini-db/core"
+)
+
+// Value tags used by the row encoding.
+const (
+	tagNull byte = iota
+	tagInt
+	tagText
+	tagBool
+	tagFloat
+	tagDecimal
+	tagDate
+	tagTimestamp
+)
+
+var errShortRow = errors.New("encoded row is truncated")
+
+// EncodeRow serialises a row for storage in a page. Columns are written in
+// name order so equal rows always encode to equal bytes.
+func EncodeRow(row Row) ([]byte, error) {
+	names := make([]string, 0, len(row))
+	for name := range row {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := binary.AppendUvarint(nil, uint64(len(names)))
+	for _, name := range names {
+		buf = appendString(buf, name)
+
+		var err error
+		buf, err = appendValue(buf, row[name])
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", name, err)
+		}
+	}
+
+	return buf, nil
+}
+
+func DecodeRow(data []byte) (Row, error) {
+	n, data, err := readUvarint(data)
+	if err != nil {
+		return nil, err
+	}
+
+	row := make(Row, n)
+	for i := uint64(0); i < n; i++ {
+		var name string
+		name, data, err = readString(data)
+		if err != nil {
+			return nil, err
+		}
+
+		var val any
+		val, data, err = readValue(data)
+		if err != nil {
+			return nil, err
+		}
+		row[name] = val
+	}
+
+	return row, nil
+}
+
+func appendValue(buf []byte, val any) ([]byte, error) {
+	switch v := val.(type) {
+	case nil:
+		return append(buf, tagNull), nil
+	case int64:
+		return binary.AppendVarint(append(buf, tagInt), v), nil
+	case int:
+		return binary.AppendVarint(append(buf, tagInt), int64(v)), nil
+	case string:
+		return appendString(append(buf, tagText), v), nil
+	case bool:
+		if v {
+			return append(buf, tagBool, 1), nil
+		}
+		return append(buf, tagBool, 0), nil
+	case float64:
+		return binary.LittleEndian.AppendUint64(append(buf, tagFloat), math.Float64bits(v)), nil
+	case core.Decimal:
+		buf = binary.AppendVarint(append(buf, tagDecimal), v.Unscaled)
+		return binary.AppendUvarint(buf, uint64(v.Scale)), nil
+	case core.Date:
+		return binary.AppendVarint(append(buf, tagDate), int64(v)), nil
+	case core.Timestamp:
+		return binary.AppendVarint(append(buf, tagTimestamp), int64(v)), nil
+	default:
+		return nil, fmt.Errorf("cannot encode value of type %T", val)
+	}
+}
+
+func readValue(data []byte) (any, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, errShortRow
+	}
+	tag, data := data[0], data[1:]
+
+	switch tag {
+	case tagNull:
+		return nil, data, nil
+	case tagInt:
+		v, n := binary.Varint(data)
+		if n <= 0 {
+			return nil, nil, errShortRow
+		}
+		return v, data[n:], nil
+	case tagText:
+		return readStringValue(data)
+	case tagBool:
+		if len(data) < 1 {
+			return nil, nil, errShortRow
+		}
+		return data[0] == 1, data[1:], nil
+	case tagFloat:
+		if len(data) < 8 {
+			return nil, nil, errShortRow
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), data[8:], nil
+	case tagDecimal:
+		v, n := binary.Varint(data)
+		if n <= 0 {
+			return nil, nil, errShortRow
+		}
+		scale, rest, err := readUvarint(data[n:])
+		if err != nil {
+			return nil, nil, err
+		}
+		return core.NewDecimal(v, int(scale)), rest, nil
+	case tagDate, tagTimestamp:
+		v, n := binary.Varint(data)
+		if n <= 0 {
+			return nil, nil, errShortRow
+		}
+		if tag == tagDate {
+			return core.Date(v), data[n:], nil
+		}
+		return core.Timestamp(v), data[n:], nil
+	default:
+		return nil, nil, fmt.Errorf("unknown value tag %d", tag)
+	}
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func readString(data []byte) (string, []byte, error) {
+	n, data, err := readUvarint(data)
+	if err != nil {
+		return "", nil, err
+	}
+	if uint64(len(data)) < n {
+		return "", nil, errShortRow
+	}
+	return string(data[:n]), data[n:], nil
+}
+
+func readStringValue(data []byte) (any, []byte, error) {
+	s, rest, err := readString(data)
+	return s, rest, err
+}
+
+func readUvarint(data []byte) (uint64, []byte, error) {
+	v, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, nil, errShortRow
+	}
+	return v, data[n:], nil
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"fastabiz-mini-rdbms/mini-db/core"
+)
+
+func TestRowRoundTrip(t *testing.T) {
+	row := Row{
+		"null":  nil,
+		"int":   int64(-42),
+		"text":  "héllo",
+		"empty": "",
+		"bool":  true,
+		"float": 2.5,
+		"dec":   core.NewDecimal(-12345, 2),
+		"date":  core.Date(19000),
+		"ts":    core.Timestamp(1700000000000000),
+	}
+
+	data, err := EncodeRow(row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeRow(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, row) {
+		t.Fatalf("decoded %v, want %v", got, row)
+	}
+
+	// Column order does not depend on map iteration.
+	again, _ := EncodeRow(got)
+	if !bytes.Equal(again, data) {
+		t.Fatal("re-encoding a row changed its bytes")
+	}
+
+	for n := range len(data) {
+		if _, err := DecodeRow(data[:n]); err == nil {
+			t.Fatalf("decoding %d of %d bytes succeeded", n, len(data))
+		}
+	}
+}
+
+func TestEncodeRowRejectsUnknownTypes(t *testing.T) {
+	if _, err := EncodeRow(Row{"c": []int{1}}); err == nil {
+		t.Fatal("encoded a slice value")
+	}
+}
@@ -1,12 +1,57 @@
 package storage
 
-import "fastabiz-mini-rdbms/mini-db/core"
+import (
+	"fmt"
+
+	"fastabiz-mini-rdbms/mini-db/core"
+)
 
 type Column struct {
 	Name    string
 	Type    core.DataType
 	Primary bool
 	Unique  bool
+	NotNull bool
+
+	// Precision and Scale apply to DECIMAL(p, s) columns only.
+	Precision int
+	Scale     int
+
+	// Default fills the column when an INSERT leaves it out; without one
+	// it is NULL. An AutoIncrement primary key takes the table's AutoInc
+	// counter instead.
+	Default       *Default
+	AutoIncrement bool
+}
+
+// Default is a column's DEFAULT clause: a constant, or with Func set the
+// time of the insert (CURRENT_TIMESTAMP or CURRENT_DATE).
+type Default struct {
+	Value any
+	Func  string
+}
+
+// Coerce converts v to this column's type, including the precision and
+// scale of a DECIMAL column.
+func (c Column) Coerce(v any) (any, error) {
+	val, err := core.Coerce(v, c.Type)
+	if err != nil || c.Type != core.DecimalType || val == nil {
+		return val, err
+	}
+
+	d, err := val.(core.Decimal).Rescale(c.Scale)
+	if err != nil || d.Digits() > c.Precision {
+		return nil, fmt.Errorf("value %v does not fit in DECIMAL(%d,%d)", v, c.Precision, c.Scale)
+	}
+	return d, nil
+}
+
+// TypeString is the column type as written in CREATE TABLE.
+func (c Column) TypeString() string {
+	if c.Type == core.DecimalType {
+		return fmt.Sprintf("DECIMAL(%d,%d)", c.Precision, c.Scale)
+	}
+	return string(c.Type)
 }
 
 // Enforces schema rules
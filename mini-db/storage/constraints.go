@@ -0,0 +1,39 @@
+package storage
+
+// RefAction is what happens to the rows referencing a row when that row
+// is deleted or its referenced key changes.
+type RefAction string
+
+const (
+	RestrictAction RefAction = "RESTRICT"
+	CascadeAction  RefAction = "CASCADE"
+	SetNullAction  RefAction = "SET NULL"
+)
+
+// CheckConstraint is a CHECK (expr) clause, kept as the SQL text of expr.
+// A row violates it when expr is false; NULL passes. Column is the column
+// a column-level check was declared on.
+type CheckConstraint struct {
+	Name   string
+	Column string
+	Expr   string
+}
+
+// Predicate is a condition a row is checked against: the bound expression
+// of a CHECK constraint.
+type Predicate interface {
+	Eval(row Row) (any, error)
+}
+
+// ForeignKey requires the values of Columns, unless one of them is NULL,
+// to match RefColumns in some row of RefTable. RefColumns must be covered
+// by a unique index of RefTable: its primary key, a UNIQUE column or a
+// unique index.
+type ForeignKey struct {
+	Name       string
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+	OnDelete   RefAction
+	OnUpdate   RefAction
+}
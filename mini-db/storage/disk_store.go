@@ -0,0 +1,89 @@
+package storage
+
+// DiskStore keeps rows in a heap file, so a table can be larger than
+// memory: only the pages in the buffer pool are resident.
+type DiskStore struct {
+	heap *HeapFile
+}
+
+func OpenDiskStore(path string, pool *BufferPool, checkpointLSN uint64) (*DiskStore, error) {
+	heap, err := OpenHeapFile(path, pool, checkpointLSN)
+	if err != nil {
+		return nil, err
+	}
+	return &DiskStore{heap: heap}, nil
+}
+
+func (d *DiskStore) Allocate(row Row) (RowID, error) {
+	return d.heap.Reserve(row)
+}
+
+func (d *DiskStore) Check(id RowID, row Row) error {
+	return d.heap.Check(id, row)
+}
+
+func (d *DiskStore) Get(id RowID) (Row, bool, error) {
+	return d.heap.Get(id)
+}
+
+// Scan reads one page at a time, so memory use stays at a page's worth of
+// rows however large the table is.
+func (d *DiskStore) Scan() RowIterator {
+	return &diskIterator{heap: d.heap}
+}
+
+func (d *DiskStore) Insert(id RowID, row Row, lsn uint64) error {
+	return d.heap.Put(id, row, lsn)
+}
+
+func (d *DiskStore) Update(id RowID, row Row, lsn uint64) error {
+	return d.heap.Put(id, row, lsn)
+}
+
+func (d *DiskStore) Delete(id RowID, lsn uint64) error {
+	return d.heap.Delete(id, lsn)
+}
+
+func (d *DiskStore) Count() int {
+	return d.heap.Count()
+}
+
+func (d *DiskStore) Flush() error {
+	return d.heap.Flush()
+}
+
+func (d *DiskStore) Checkpoint(lsn uint64) error {
+	return d.heap.Checkpoint(lsn)
+}
+
+func (d *DiskStore) Close() error {
+	return d.heap.Close()
+}
+
+type diskIterator struct {
+	heap *HeapFile
+	page PageID
+	ids  []RowID
+	rows []Row
+	pos  int
+	err  error
+}
+
+func (it *diskIterator) Next() (RowID, Row, bool) {
+	for it.pos >= len(it.ids) {
+		if it.err != nil || int(it.page) >= it.heap.NumPages() {
+			return 0, nil, false
+		}
+
+		it.ids, it.rows, it.err = it.heap.scanPage(it.page)
+		it.pos = 0
+		it.page++
+	}
+
+	id, row := it.ids[it.pos], it.rows[it.pos]
+	it.pos++
+	return id, row, true
+}
+
+func (it *diskIterator) Err() error   { return it.err }
+func (it *diskIterator) Close() error { return nil }
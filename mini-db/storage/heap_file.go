@@ -0,0 +1,596 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Every record in a heap page starts with one of these flags.
+//
+// A row that grows too large for its page is moved to another page and
+// its home slot keeps a small forwarding stub, so RowIDs stay stable for
+// the lifetime of the row. The moved record remembers its home slot, which
+// lets scans skip it and lets readers reject stale forwards.
+const (
+	recRow     byte = iota // an ordinary row
+	recForward             // stub pointing at the moved row
+	recMoved               // row living away from its home slot
+)
+
+const (
+	forwardSize = 1 + 8
+	// maxRowSize is the largest encoded row that fits on an empty page.
+	maxRowSize = PageSize - pageHeaderSize - slotSize - 1 - 8
+)
+
+var ErrRowTooLarge = errors.New("row too large for a page")
+
+// HeapFile stores rows in fixed-size slotted pages of a single file. Pages
+// are read and written through a shared buffer pool.
+type HeapFile struct {
+	file     *os.File
+	pool     *BufferPool
+	numPages int
+	free     []int // free-space map: bytes reclaimable on each page
+	count    int
+
+	// Page images as of the last checkpoint, see page_images.go.
+	images    *os.File
+	imagesEnd int64
+	basePages int // pages the file had at the checkpoint
+	saved     map[PageID]bool
+}
+
+// OpenHeapFile opens the heap file at path as it was at the checkpoint
+// taken at checkpointLSN, rolling back any page written since then. The
+// WAL records after the checkpoint are then replayed over it.
+func OpenHeapFile(path string, pool *BufferPool, checkpointLSN uint64) (*HeapFile, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	h := &HeapFile{file: file, pool: pool, numPages: int(info.Size() / PageSize)}
+	if err := h.openImages(path+imagesSuffix, checkpointLSN); err != nil {
+		h.closeFiles()
+		return nil, err
+	}
+
+	// Rebuild the free-space map and row count from the pages themselves.
+	for pid := 0; pid < h.numPages; pid++ {
+		p, err := h.fetch(PageID(pid))
+		if err != nil {
+			h.closeFiles()
+			return nil, err
+		}
+		h.free = append(h.free, p.reclaimable())
+		for slot := 0; slot < p.NumSlots(); slot++ {
+			if rec, ok := p.Get(slot); ok && rec[0] != recMoved {
+				h.count++
+			}
+		}
+		h.release(PageID(pid), p, false)
+	}
+
+	return h, nil
+}
+
+func (h *HeapFile) Count() int {
+	return h.count
+}
+
+func (h *HeapFile) NumPages() int {
+	return h.numPages
+}
+
+// Reserve picks the RowID a new row will be stored under. Nothing is
+// written until Put is called with that RowID.
+func (h *HeapFile) Reserve(row Row) (RowID, error) {
+	data, err := EncodeRow(row)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) > maxRowSize {
+		return 0, ErrRowTooLarge
+	}
+
+	pid := h.findPage(recordSpace(1+len(data))+slotSize, -1)
+	if pid == PageID(h.numPages) {
+		return NewRowID(pid, 0), nil
+	}
+
+	p, err := h.fetch(pid)
+	if err != nil {
+		return 0, err
+	}
+	slot := p.FreeSlot()
+	h.release(pid, p, false)
+
+	return NewRowID(pid, slot), nil
+}
+
+// Insert stores row in a new slot and returns its RowID.
+func (h *HeapFile) Insert(row Row, lsn uint64) (RowID, error) {
+	id, err := h.Reserve(row)
+	if err != nil {
+		return 0, err
+	}
+	return id, h.Put(id, row, lsn)
+}
+
+// Check reports the error Put(id, row) would fail with, without writing
+// anything.
+func (h *HeapFile) Check(id RowID, row Row) error {
+	data, err := EncodeRow(row)
+	if err != nil {
+		return err
+	}
+	if len(data) > maxRowSize {
+		return ErrRowTooLarge
+	}
+
+	pid, slot := id.Page(), id.Slot()
+	if int(pid) >= h.numPages {
+		return nil // a new, empty page
+	}
+
+	p, err := h.fetch(pid)
+	if err != nil {
+		return err
+	}
+	defer h.release(pid, p, false)
+
+	// A record already in the slot is charged enough for a forwarding stub,
+	// and a row that does not fit on the page moves elsewhere. A new record
+	// needs room for at least the stub.
+	if _, ok := p.Get(slot); ok {
+		return nil
+	}
+	need := recordSpace(forwardSize) + max(0, slot+1-p.NumSlots())*slotSize
+	if p.reclaimable() < need {
+		return fmt.Errorf("page %d is full", pid)
+	}
+	return nil
+}
+
+// Put stores row under id, inserting or replacing.
+func (h *HeapFile) Put(id RowID, row Row, lsn uint64) error {
+	data, err := EncodeRow(row)
+	if err != nil {
+		return err
+	}
+	if len(data) > maxRowSize {
+		return ErrRowTooLarge
+	}
+
+	pid, slot := id.Page(), id.Slot()
+	if err := h.extendTo(pid); err != nil {
+		return err
+	}
+
+	p, err := h.fetch(pid)
+	if err != nil {
+		return err
+	}
+
+	rec, exists := p.Get(slot)
+	if exists {
+		switch rec[0] {
+		case recForward:
+			target := RowID(binary.LittleEndian.Uint64(rec[1:]))
+			if ok, err := h.putMoved(target, id, data, lsn); err != nil || ok {
+				h.release(pid, p, false)
+				return err
+			}
+			// The row outgrew the page it had moved to; drop that copy and
+			// place it again below.
+			if err := h.deleteMoved(target, id, lsn); err != nil {
+				h.release(pid, p, false)
+				return err
+			}
+
+		case recMoved:
+			// Another row's moved copy sits in this home slot; push it out
+			// of the way first.
+			if err := h.relocate(p, pid, slot, lsn); err != nil {
+				h.release(pid, p, false)
+				return err
+			}
+			exists = false
+		}
+	}
+
+	if p.Put(slot, append([]byte{recRow}, data...)) {
+		return h.written(pid, p, lsn, exists)
+	}
+
+	target, err := h.storeMoved(id, data, pid, lsn)
+	if err != nil {
+		h.release(pid, p, false)
+		return err
+	}
+
+	stub := make([]byte, forwardSize)
+	stub[0] = recForward
+	binary.LittleEndian.PutUint64(stub[1:], uint64(target))
+	if !p.Put(slot, stub) {
+		h.release(pid, p, false)
+		return fmt.Errorf("page %d is full", pid)
+	}
+
+	return h.written(pid, p, lsn, exists)
+}
+
+func (h *HeapFile) Get(id RowID) (Row, bool, error) {
+	pid, slot := id.Page(), id.Slot()
+	if int(pid) >= h.numPages {
+		return nil, false, nil
+	}
+
+	p, err := h.fetch(pid)
+	if err != nil {
+		return nil, false, err
+	}
+	defer h.release(pid, p, false)
+
+	rec, ok := p.Get(slot)
+	if !ok {
+		return nil, false, nil
+	}
+	return h.decode(id, rec)
+}
+
+// Delete removes the row stored under id. Deleting a missing row is not
+// an error.
+func (h *HeapFile) Delete(id RowID, lsn uint64) error {
+	pid, slot := id.Page(), id.Slot()
+	if int(pid) >= h.numPages {
+		return nil
+	}
+
+	p, err := h.fetch(pid)
+	if err != nil {
+		return err
+	}
+
+	rec, ok := p.Get(slot)
+	if !ok || rec[0] == recMoved {
+		h.release(pid, p, false)
+		return nil
+	}
+
+	if rec[0] == recForward {
+		target := RowID(binary.LittleEndian.Uint64(rec[1:]))
+		if err := h.deleteMoved(target, id, lsn); err != nil {
+			h.release(pid, p, false)
+			return err
+		}
+	}
+
+	p.Delete(slot)
+	h.count--
+	p.SetLSN(lsn)
+	h.free[pid] = p.reclaimable()
+	h.release(pid, p, true)
+	return nil
+}
+
+// Scan calls fn for every row in page and slot order.
+func (h *HeapFile) Scan(fn func(RowID, Row) error) error {
+	for pid := PageID(0); int(pid) < h.numPages; pid++ {
+		ids, rows, err := h.scanPage(pid)
+		if err != nil {
+			return err
+		}
+		for i := range ids {
+			if err := fn(ids[i], rows[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// scanPage decodes every row whose home slot is on page pid. The page is
+// unpinned before returning, so callers may modify the file while they
+// work through the result.
+func (h *HeapFile) scanPage(pid PageID) ([]RowID, []Row, error) {
+	p, err := h.fetch(pid)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer h.release(pid, p, false)
+
+	var ids []RowID
+	var rows []Row
+
+	for slot := 0; slot < p.NumSlots(); slot++ {
+		rec, ok := p.Get(slot)
+		if !ok || rec[0] == recMoved {
+			continue
+		}
+
+		id := NewRowID(pid, slot)
+		row, ok, err := h.decode(id, rec)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			ids = append(ids, id)
+			rows = append(rows, row)
+		}
+	}
+
+	return ids, rows, nil
+}
+
+// Flush writes the file's dirty pages and fsyncs it.
+func (h *HeapFile) Flush() error {
+	if err := h.pool.Flush(h); err != nil {
+		return err
+	}
+	return h.file.Sync()
+}
+
+// Checkpoint makes the file's current contents the state recovery from
+// the checkpoint at lsn starts from. The file must have been flushed
+// first.
+func (h *HeapFile) Checkpoint(lsn uint64) error {
+	return h.resetImages(lsn)
+}
+
+func (h *HeapFile) Close() error {
+	if err := h.pool.Drop(h); err != nil {
+		h.closeFiles()
+		return err
+	}
+	if err := h.file.Sync(); err != nil {
+		h.closeFiles()
+		return err
+	}
+	if err := h.images.Close(); err != nil {
+		h.file.Close()
+		return err
+	}
+	return h.file.Close()
+}
+
+func (h *HeapFile) closeFiles() {
+	if h.images != nil {
+		h.images.Close()
+	}
+	h.file.Close()
+}
+
+// ReadPage and WritePage make HeapFile the PageFile behind its pages in
+// the buffer pool.
+func (h *HeapFile) ReadPage(pid PageID, p *Page) error {
+	n, err := h.file.ReadAt(p.Bytes(), int64(pid)*PageSize)
+	if err == io.EOF {
+		// Past the end of the file: the page was never written, so it is
+		// empty.
+		clear(p.Bytes()[n:])
+		return nil
+	}
+	return err
+}
+
+// WritePage saves the page's checkpoint image the first time it is
+// overwritten after a checkpoint.
+func (h *HeapFile) WritePage(pid PageID, p *Page) error {
+	if int(pid) < h.basePages && !h.saved[pid] {
+		if err := h.saveImage(pid); err != nil {
+			return err
+		}
+	}
+	_, err := h.file.WriteAt(p.Bytes(), int64(pid)*PageSize)
+	return err
+}
+
+// decode turns the record found at home slot id into a row, following a
+// forwarding stub if there is one.
+func (h *HeapFile) decode(id RowID, rec []byte) (Row, bool, error) {
+	switch rec[0] {
+	case recRow:
+		row, err := DecodeRow(rec[1:])
+		return row, err == nil, err
+
+	case recForward:
+		target := RowID(binary.LittleEndian.Uint64(rec[1:]))
+		tp, err := h.fetch(target.Page())
+		if err != nil {
+			return nil, false, err
+		}
+		defer h.release(target.Page(), tp, false)
+
+		moved, ok := tp.Get(target.Slot())
+		if !ok || moved[0] != recMoved || RowID(binary.LittleEndian.Uint64(moved[1:])) != id {
+			return nil, false, nil
+		}
+		row, err := DecodeRow(moved[9:])
+		return row, err == nil, err
+	}
+
+	return nil, false, nil
+}
+
+// findPage returns the first page with at least need reclaimable bytes,
+// skipping skip, or the id of a page that does not exist yet.
+func (h *HeapFile) findPage(need int, skip PageID) PageID {
+	for pid, free := range h.free {
+		if PageID(pid) != skip && free >= need {
+			return PageID(pid)
+		}
+	}
+	return PageID(h.numPages)
+}
+
+// storeMoved writes data as the moved copy of home on some page other than
+// the home page and returns where it went.
+func (h *HeapFile) storeMoved(home RowID, data []byte, homePage PageID, lsn uint64) (RowID, error) {
+	rec := make([]byte, 9+len(data))
+	rec[0] = recMoved
+	binary.LittleEndian.PutUint64(rec[1:], uint64(home))
+	copy(rec[9:], data)
+
+	pid := h.findPage(len(rec)+slotSize, homePage)
+	if err := h.extendTo(pid); err != nil {
+		return 0, err
+	}
+
+	p, err := h.fetch(pid)
+	if err != nil {
+		return 0, err
+	}
+
+	slot := p.FreeSlot()
+	if !p.Put(slot, rec) {
+		h.release(pid, p, false)
+		return 0, fmt.Errorf("page %d is full", pid)
+	}
+
+	p.SetLSN(lsn)
+	h.free[pid] = p.reclaimable()
+	h.release(pid, p, true)
+	return NewRowID(pid, slot), nil
+}
+
+// putMoved overwrites the moved copy at target in place. It reports false
+// if the new data no longer fits there.
+func (h *HeapFile) putMoved(target, home RowID, data []byte, lsn uint64) (bool, error) {
+	pid := target.Page()
+	if int(pid) >= h.numPages {
+		return false, nil
+	}
+
+	p, err := h.fetch(pid)
+	if err != nil {
+		return false, err
+	}
+
+	moved, ok := p.Get(target.Slot())
+	if !ok || moved[0] != recMoved || RowID(binary.LittleEndian.Uint64(moved[1:])) != home {
+		h.release(pid, p, false)
+		return false, nil
+	}
+
+	rec := make([]byte, 9+len(data))
+	rec[0] = recMoved
+	binary.LittleEndian.PutUint64(rec[1:], uint64(home))
+	copy(rec[9:], data)
+
+	if !p.Put(target.Slot(), rec) {
+		h.release(pid, p, false)
+		return false, nil
+	}
+
+	p.SetLSN(lsn)
+	h.free[pid] = p.reclaimable()
+	h.release(pid, p, true)
+	return true, nil
+}
+
+// deleteMoved removes the moved copy at target if it still belongs to home.
+func (h *HeapFile) deleteMoved(target, home RowID, lsn uint64) error {
+	pid := target.Page()
+	if int(pid) >= h.numPages {
+		return nil
+	}
+
+	p, err := h.fetch(pid)
+	if err != nil {
+		return err
+	}
+
+	moved, ok := p.Get(target.Slot())
+	if !ok || moved[0] != recMoved || RowID(binary.LittleEndian.Uint64(moved[1:])) != home {
+		h.release(pid, p, false)
+		return nil
+	}
+
+	p.Delete(target.Slot())
+	p.SetLSN(lsn)
+	h.free[pid] = p.reclaimable()
+	h.release(pid, p, true)
+	return nil
+}
+
+// relocate moves the moved copy held in slot of page p somewhere else and
+// repoints its home stub. The slot is left empty.
+func (h *HeapFile) relocate(p *Page, pid PageID, slot int, lsn uint64) error {
+	moved, _ := p.Get(slot)
+	home := RowID(binary.LittleEndian.Uint64(moved[1:]))
+	data := append([]byte(nil), moved[9:]...)
+
+	p.Delete(slot)
+
+	target, err := h.storeMoved(home, data, pid, lsn)
+	if err != nil {
+		return err
+	}
+
+	stub := make([]byte, forwardSize)
+	stub[0] = recForward
+	binary.LittleEndian.PutUint64(stub[1:], uint64(target))
+
+	if home.Page() == pid {
+		if !p.Put(home.Slot(), stub) {
+			return fmt.Errorf("page %d is full", pid)
+		}
+		return nil
+	}
+
+	hp, err := h.fetch(home.Page())
+	if err != nil {
+		return err
+	}
+	if !hp.Put(home.Slot(), stub) {
+		h.release(home.Page(), hp, false)
+		return fmt.Errorf("page %d is full", home.Page())
+	}
+	hp.SetLSN(lsn)
+	h.release(home.Page(), hp, true)
+	return nil
+}
+
+// written finishes a Put on page p.
+func (h *HeapFile) written(pid PageID, p *Page, lsn uint64, replaced bool) error {
+	if !replaced {
+		h.count++
+	}
+	p.SetLSN(lsn)
+	h.free[pid] = p.reclaimable()
+	h.release(pid, p, true)
+	return nil
+}
+
+// extendTo makes sure page pid exists, appending empty pages as needed.
+func (h *HeapFile) extendTo(pid PageID) error {
+	for h.numPages <= int(pid) {
+		p := NewPage()
+		if _, err := h.file.WriteAt(p.Bytes(), int64(h.numPages)*PageSize); err != nil {
+			return err
+		}
+		h.free = append(h.free, p.reclaimable())
+		h.numPages++
+	}
+	return nil
+}
+
+func (h *HeapFile) fetch(pid PageID) (*Page, error) {
+	return h.pool.Fetch(h, pid)
+}
+
+// release unpins a fetched page, marking it dirty if it was modified.
+func (h *HeapFile) release(pid PageID, p *Page, dirty bool) {
+	h.pool.Unpin(h, pid, dirty)
+}
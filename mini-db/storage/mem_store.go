@@ -0,0 +1,92 @@
+package storage
+
+import "sort"
+
+// MemStore keeps rows in a Go map. RowIDs are handed out sequentially.
+type MemStore struct {
+	rows      map[RowID]Row
+	nextRowID RowID
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{rows: make(map[RowID]Row)}
+}
+
+func (m *MemStore) Allocate(Row) (RowID, error) {
+	return m.nextRowID, nil
+}
+
+func (m *MemStore) Get(id RowID) (Row, bool, error) {
+	row, ok := m.rows[id]
+	return row, ok, nil
+}
+
+// Scan iterates in RowID order (insertion order) over the rows present
+// when Scan was called.
+func (m *MemStore) Scan() RowIterator {
+	ids := make([]RowID, 0, len(m.rows))
+	for id := range m.rows {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return &memIterator{store: m, ids: ids}
+}
+
+func (m *MemStore) Insert(id RowID, row Row, _ uint64) error {
+	m.rows[id] = row
+	if id >= m.nextRowID {
+		m.nextRowID = id + 1
+	}
+	return nil
+}
+
+func (m *MemStore) Update(id RowID, row Row, _ uint64) error {
+	m.rows[id] = row
+	return nil
+}
+
+func (m *MemStore) Delete(id RowID, _ uint64) error {
+	delete(m.rows, id)
+	return nil
+}
+
+func (m *MemStore) Count() int {
+	return len(m.rows)
+}
+
+func (m *MemStore) Check(RowID, Row) error { return nil }
+
+func (m *MemStore) Flush() error            { return nil }
+func (m *MemStore) Checkpoint(uint64) error { return nil }
+func (m *MemStore) Close() error            { return nil }
+
+// NextRowID and SetNextRowID let snapshots carry the RowID counter.
+func (m *MemStore) NextRowID() RowID {
+	return m.nextRowID
+}
+
+func (m *MemStore) SetNextRowID(id RowID) {
+	m.nextRowID = id
+}
+
+type memIterator struct {
+	store *MemStore
+	ids   []RowID
+	pos   int
+}
+
+func (it *memIterator) Next() (RowID, Row, bool) {
+	for it.pos < len(it.ids) {
+		id := it.ids[it.pos]
+		it.pos++
+		// Skip rows deleted since the scan started.
+		if row, ok := it.store.rows[id]; ok {
+			return id, row, true
+		}
+	}
+	return 0, nil, false
+}
+
+func (it *memIterator) Err() error   { return nil }
+func (it *memIterator) Close() error { return nil }
@@ -0,0 +1,210 @@
+package storage
+
+import "encoding/binary"
+
+const PageSize = 4096
+
+// Slotted page layout:
+//
+//	| lsn uint64 | numSlots uint16 | dataStart uint16 | slot array ... free ... record data |
+//
+// The slot array grows forward from the header, record data grows backward
+// from the end of the page. A slot is (offset uint16, length uint16); an
+// offset of 0 marks an empty slot that can be reused.
+const (
+	pageHeaderSize = 12
+	slotSize       = 4
+)
+
+type PageID int
+
+type Page struct {
+	data [PageSize]byte
+}
+
+func NewPage() *Page {
+	p := &Page{}
+	p.setDataStart(PageSize)
+	return p
+}
+
+func (p *Page) Bytes() []byte {
+	return p.data[:]
+}
+
+// LSN is the log sequence number of the last change applied to the page.
+func (p *Page) LSN() uint64 {
+	return binary.LittleEndian.Uint64(p.data[0:8])
+}
+
+func (p *Page) SetLSN(lsn uint64) {
+	binary.LittleEndian.PutUint64(p.data[0:8], lsn)
+}
+
+func (p *Page) NumSlots() int {
+	return int(binary.LittleEndian.Uint16(p.data[8:10]))
+}
+
+func (p *Page) setNumSlots(n int) {
+	binary.LittleEndian.PutUint16(p.data[8:10], uint16(n))
+}
+
+func (p *Page) dataStart() int {
+	v := int(binary.LittleEndian.Uint16(p.data[10:12]))
+	if v == 0 {
+		return PageSize // PageSize itself does not fit in a uint16
+	}
+	return v
+}
+
+func (p *Page) setDataStart(off int) {
+	if off == PageSize {
+		off = 0
+	}
+	binary.LittleEndian.PutUint16(p.data[10:12], uint16(off))
+}
+
+func (p *Page) slot(i int) (offset, length int) {
+	base := pageHeaderSize + i*slotSize
+	offset = int(binary.LittleEndian.Uint16(p.data[base : base+2]))
+	length = int(binary.LittleEndian.Uint16(p.data[base+2 : base+4]))
+	return offset, length
+}
+
+func (p *Page) setSlot(i, offset, length int) {
+	base := pageHeaderSize + i*slotSize
+	binary.LittleEndian.PutUint16(p.data[base:base+2], uint16(offset))
+	binary.LittleEndian.PutUint16(p.data[base+2:base+4], uint16(length))
+}
+
+// FreeSpace is the number of contiguous bytes between the slot array and
+// the record data.
+func (p *Page) FreeSpace() int {
+	return p.dataStart() - pageHeaderSize - p.NumSlots()*slotSize
+}
+
+// recordSpace is the room a record of n bytes is charged on a page. No
+// record is charged less than a forwarding stub, so a row that outgrows its
+// page can always leave a stub behind in its slot.
+func recordSpace(n int) int {
+	return max(n, forwardSize)
+}
+
+// reclaimable is the free space the page would have after compaction.
+func (p *Page) reclaimable() int {
+	used := 0
+	for i := 0; i < p.NumSlots(); i++ {
+		if off, length := p.slot(i); off != 0 {
+			used += recordSpace(length)
+		}
+	}
+	return PageSize - pageHeaderSize - p.NumSlots()*slotSize - used
+}
+
+// Get returns the record stored in slot i.
+func (p *Page) Get(i int) ([]byte, bool) {
+	if i < 0 || i >= p.NumSlots() {
+		return nil, false
+	}
+	off, length := p.slot(i)
+	if off == 0 {
+		return nil, false
+	}
+	return p.data[off : off+length], true
+}
+
+// FreeSlot returns the first empty slot, or NumSlots if every slot is in use.
+func (p *Page) FreeSlot() int {
+	for i := 0; i < p.NumSlots(); i++ {
+		if off, _ := p.slot(i); off == 0 {
+			return i
+		}
+	}
+	return p.NumSlots()
+}
+
+// Put stores rec in slot i, replacing whatever was there and growing the
+// slot array if needed. It reports false, leaving the page untouched, when
+// rec does not fit even after compaction.
+func (p *Page) Put(i int, rec []byte) bool {
+	if i < 0 || len(rec) == 0 || len(rec) > PageSize {
+		return false
+	}
+
+	oldLen, extraSlots := 0, 0
+	if i < p.NumSlots() {
+		if off, length := p.slot(i); off != 0 {
+			oldLen = recordSpace(length)
+		}
+	} else {
+		extraSlots = i + 1 - p.NumSlots()
+	}
+
+	need := recordSpace(len(rec)) + extraSlots*slotSize
+	if p.reclaimable()+oldLen < need {
+		return false
+	}
+
+	// Release the old record first so compaction can reuse its bytes.
+	if i < p.NumSlots() {
+		p.setSlot(i, 0, 0)
+	}
+	if p.FreeSpace() < need {
+		p.compact()
+	}
+
+	for s := p.NumSlots(); s <= i; s++ {
+		p.setSlot(s, 0, 0)
+	}
+	if extraSlots > 0 {
+		p.setNumSlots(i + 1)
+	}
+
+	off := p.dataStart() - len(rec)
+	copy(p.data[off:], rec)
+	p.setDataStart(off)
+	p.setSlot(i, off, len(rec))
+	return true
+}
+
+// Delete empties slot i. Trailing empty slots are dropped so the slot
+// array does not grow without bound.
+func (p *Page) Delete(i int) {
+	if i < 0 || i >= p.NumSlots() {
+		return
+	}
+	p.setSlot(i, 0, 0)
+
+	n := p.NumSlots()
+	for n > 0 {
+		if off, _ := p.slot(n - 1); off != 0 {
+			break
+		}
+		n--
+	}
+	p.setNumSlots(n)
+
+	if n == 0 {
+		p.setDataStart(PageSize)
+	}
+}
+
+// compact moves every live record to the end of the page so all free space
+// is contiguous. Slot numbers do not change.
+func (p *Page) compact() {
+	var buf [PageSize]byte
+	end := PageSize
+
+	for i := 0; i < p.NumSlots(); i++ {
+		off, length := p.slot(i)
+		if off == 0 {
+			continue
+		}
+		end -= length
+		copy(buf[end:], p.data[off:off+length])
+		p.setSlot(i, end, length)
+	}
+
+	copy(p.data[end:], buf[end:])
+	p.setDataStart(end)
+}
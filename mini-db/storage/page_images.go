@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// A heap file keeps, next to itself, the images its pages had at the last
+// checkpoint. A page's image is saved the first time the page is written
+// after the checkpoint, and fsynced before the page itself is overwritten.
+// Opening the file again rolls it back to the checkpoint, so the WAL
+// records after it replay over exactly the pages they were first applied
+// to, however much of the later work had already reached the file.
+//
+// Image file layout:
+//
+//	| crc32 uint32 | pages uint32 | lsn uint64 | entry ... |
+//
+// where lsn is the checkpoint, pages the length of the heap file at that
+// point, and every entry is
+//
+//	| crc32 uint32 | pid uint32 | page [PageSize]byte |
+//
+// An entry that was only partially written before a crash fails its
+// checksum; its page had not been overwritten yet, so it is simply dropped.
+const (
+	imagesSuffix     = ".ckpt"
+	imagesHeaderSize = 16
+	imageEntrySize   = 8 + PageSize
+)
+
+// openImages opens the image file at path. If it holds the images of the
+// checkpoint at checkpointLSN the heap file is rolled back to them;
+// otherwise the heap file already is in that state and a new set of
+// images is started.
+func (h *HeapFile) openImages(path string, checkpointLSN uint64) error {
+	images, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	h.images = images
+	h.saved = make(map[PageID]bool)
+
+	var hdr [imagesHeaderSize]byte
+	if _, err := images.ReadAt(hdr[:], 0); err != nil && err != io.EOF {
+		return err
+	} else if err == nil && binary.LittleEndian.Uint32(hdr[0:4]) == crc32.ChecksumIEEE(hdr[4:]) &&
+		binary.LittleEndian.Uint64(hdr[8:16]) == checkpointLSN {
+		return h.restoreImages(int(binary.LittleEndian.Uint32(hdr[4:8])))
+	}
+
+	// A missing or torn header means the file was just created, or a
+	// checkpoint was cut short after the heap file had been flushed.
+	return h.resetImages(checkpointLSN)
+}
+
+// restoreImages writes every saved image back and cuts the heap file to the
+// pages it had at the checkpoint.
+func (h *HeapFile) restoreImages(pages int) error {
+	entry := make([]byte, imageEntrySize)
+	off := int64(imagesHeaderSize)
+
+	for {
+		if _, err := h.images.ReadAt(entry, off); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return err
+		}
+		if binary.LittleEndian.Uint32(entry[0:4]) != crc32.ChecksumIEEE(entry[4:]) {
+			break
+		}
+
+		pid := PageID(binary.LittleEndian.Uint32(entry[4:8]))
+		if _, err := h.file.WriteAt(entry[8:], int64(pid)*PageSize); err != nil {
+			return err
+		}
+		h.saved[pid] = true
+		off += imageEntrySize
+	}
+
+	if err := h.file.Truncate(int64(pages) * PageSize); err != nil {
+		return err
+	}
+	if err := h.file.Sync(); err != nil {
+		return err
+	}
+	// Drop a torn entry so new images are appended on a clean boundary.
+	if err := h.images.Truncate(off); err != nil {
+		return err
+	}
+
+	h.numPages = pages
+	h.basePages = pages
+	h.imagesEnd = off
+	return nil
+}
+
+// resetImages drops the saved images and makes the current contents of
+// the heap file the checkpoint state for lsn.
+func (h *HeapFile) resetImages(lsn uint64) error {
+	if err := h.images.Truncate(0); err != nil {
+		return err
+	}
+
+	var hdr [imagesHeaderSize]byte
+	binary.LittleEndian.PutUint32(hdr[4:8], uint32(h.numPages))
+	binary.LittleEndian.PutUint64(hdr[8:16], lsn)
+	binary.LittleEndian.PutUint32(hdr[0:4], crc32.ChecksumIEEE(hdr[4:]))
+
+	if _, err := h.images.WriteAt(hdr[:], 0); err != nil {
+		return err
+	}
+	if err := h.images.Sync(); err != nil {
+		return err
+	}
+
+	h.basePages = h.numPages
+	h.imagesEnd = imagesHeaderSize
+	clear(h.saved)
+	return nil
+}
+
+// saveImage appends the on-disk image of page pid to the image file and
+// fsyncs it, so the page can then be overwritten.
+func (h *HeapFile) saveImage(pid PageID) error {
+	entry := make([]byte, imageEntrySize)
+	binary.LittleEndian.PutUint32(entry[4:8], uint32(pid))
+	if n, err := h.file.ReadAt(entry[8:], int64(pid)*PageSize); err == io.EOF {
+		clear(entry[8+n:])
+	} else if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(entry[0:4], crc32.ChecksumIEEE(entry[4:]))
+
+	if _, err := h.images.WriteAt(entry, h.imagesEnd); err != nil {
+		return err
+	}
+	if err := h.images.Sync(); err != nil {
+		return err
+	}
+
+	h.imagesEnd += imageEntrySize
+	h.saved[pid] = true
+	return nil
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+)
+
+func checkRecord(t *testing.T, p *Page, i int, want []byte) {
+	t.Helper()
+	got, ok := p.Get(i)
+	if want == nil {
+		if ok {
+			t.Fatalf("slot %d holds %q, want it empty", i, got)
+		}
+		return
+	}
+	if !ok || !bytes.Equal(got, want) {
+		t.Fatalf("slot %d holds %q, %v; want %q", i, got, ok, want)
+	}
+}
+
+func TestPagePutGetDelete(t *testing.T) {
+	p := NewPage()
+	a, b, c := []byte("alpha"), []byte("bravo"), []byte("charlie")
+
+	for i, rec := range [][]byte{a, b, c} {
+		if slot := p.FreeSlot(); slot != i {
+			t.Fatalf("FreeSlot() = %d, want %d", slot, i)
+		}
+		if !p.Put(i, rec) {
+			t.Fatalf("Put(%d) refused on an empty page", i)
+		}
+	}
+	checkRecord(t, p, 1, b)
+
+	p.Delete(1)
+	checkRecord(t, p, 1, nil)
+	if slot := p.FreeSlot(); slot != 1 {
+		t.Fatalf("FreeSlot() = %d after deleting slot 1", slot)
+	}
+
+	// Putting past the end grows the slot array with empty slots.
+	if !p.Put(5, a) || p.NumSlots() != 6 {
+		t.Fatalf("Put(5) left %d slots", p.NumSlots())
+	}
+	checkRecord(t, p, 4, nil)
+
+	// Deleting the last slots drops them, an empty page starts over.
+	p.Delete(5)
+	if p.NumSlots() != 3 {
+		t.Fatalf("NumSlots() = %d after deleting the trailing slot", p.NumSlots())
+	}
+	p.Delete(0)
+	p.Delete(2)
+	if p.NumSlots() != 0 || p.FreeSpace() != PageSize-pageHeaderSize {
+		t.Fatalf("empty page has %d slots and %d free bytes", p.NumSlots(), p.FreeSpace())
+	}
+}
+
+func TestPageCompacts(t *testing.T) {
+	p := NewPage()
+	rec := bytes.Repeat([]byte("r"), 1000)
+	for i := 0; i < 4; i++ {
+		if !p.Put(i, rec) {
+			t.Fatalf("Put(%d) refused", i)
+		}
+	}
+	if p.Put(4, rec) {
+		t.Fatal("fifth 1000-byte record fit in a page")
+	}
+
+	// Freeing two records in the middle leaves holes that only fit a
+	// larger record once the page is compacted.
+	p.Delete(1)
+	p.Delete(2)
+	big := bytes.Repeat([]byte("b"), 1900)
+	if !p.Put(1, big) {
+		t.Fatal("Put refused a record that fits after compaction")
+	}
+	checkRecord(t, p, 0, rec)
+	checkRecord(t, p, 1, big)
+	checkRecord(t, p, 3, rec)
+
+	// Growing a record in place may reuse its own bytes.
+	bigger := bytes.Repeat([]byte("c"), 1900+p.reclaimable())
+	if !p.Put(1, bigger) {
+		t.Fatal("Put refused to grow a record into the page's free space")
+	}
+	if p.Put(1, append(bigger, 'x')) {
+		t.Fatal("Put accepted a record one byte too large")
+	}
+	checkRecord(t, p, 1, bigger)
+}
+
+// TestPageStubSpace checks every record keeps room for a forwarding stub,
+// so a row shrunk to nothing can always move off its page again.
+func TestPageStubSpace(t *testing.T) {
+	p := NewPage()
+	n := 0
+	for p.Put(n, []byte{1}) {
+		n++
+	}
+	if want := (PageSize - pageHeaderSize) / (slotSize + forwardSize); n != want {
+		t.Fatalf("page took %d one-byte records, want %d", n, want)
+	}
+	for i := 0; i < n; i++ {
+		if !p.Put(i, make([]byte, forwardSize)) {
+			t.Fatalf("slot %d cannot take a stub", i)
+		}
+	}
+}
@@ -6,3 +6,19 @@ type RowID int
 type DataType int
 
 type JoinedRow map[string]any
+
+// RowIDs of heap-file tables pack the page number and the slot within the
+// page into one integer.
+const slotBits = 16
+
+func NewRowID(page PageID, slot int) RowID {
+	return RowID(int(page)<<slotBits | slot)
+}
+
+func (id RowID) Page() PageID {
+	return PageID(int(id) >> slotBits)
+}
+
+func (id RowID) Slot() int {
+	return int(id) & (1<<slotBits - 1)
+}
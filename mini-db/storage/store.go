@@ -0,0 +1,70 @@
+package storage
+
+// EngineKind selects the TableStore backing a table, as chosen with
+// CREATE TABLE ... ENGINE = memory|disk.
+type EngineKind string
+
+const (
+	MemoryEngine EngineKind = "memory"
+	DiskEngine   EngineKind = "disk"
+)
+
+func ParseEngineKind(s string) (EngineKind, bool) {
+	switch EngineKind(s) {
+	case MemoryEngine, DiskEngine:
+		return EngineKind(s), true
+	}
+	return "", false
+}
+
+// TableStore holds the rows of one table. The executors only ever reach
+// rows through it, so any backend can sit behind a storage.Table.
+//
+// Writes take the RowID up front (see Allocate) and the LSN of the WAL
+// record they belong to. Recovery replays the WAL records written after the
+// last checkpoint, in order, over the store as it was at that checkpoint:
+// a store that outlives the process has to return to that state when it is
+// opened again.
+type TableStore interface {
+	// Allocate picks the RowID the next inserted row will get. It does not
+	// store anything.
+	Allocate(row Row) (RowID, error)
+	// Check reports the error storing row under id would fail with, so a
+	// statement can be refused before it is logged. It stores nothing.
+	Check(id RowID, row Row) error
+
+	Get(id RowID) (Row, bool, error)
+	Scan() RowIterator
+	Insert(id RowID, row Row, lsn uint64) error
+	Update(id RowID, row Row, lsn uint64) error
+	Delete(id RowID, lsn uint64) error
+	Count() int
+
+	// Flush makes every applied change durable in the store itself.
+	Flush() error
+	// Checkpoint is called, after Flush, once the snapshot taken at lsn
+	// is durable.
+	Checkpoint(lsn uint64) error
+	Close() error
+}
+
+// RowIterator walks the rows of a store. Next reports false once the rows
+// are exhausted or an error occurred; check Err afterwards.
+type RowIterator interface {
+	Next() (RowID, Row, bool)
+	Err() error
+	Close() error
+}
+
+// ForEach calls fn for every row of store, stopping at the first error.
+func ForEach(store TableStore, fn func(RowID, Row) error) error {
+	it := store.Scan()
+	defer it.Close()
+
+	for id, row, ok := it.Next(); ok; id, row, ok = it.Next() {
+		if err := fn(id, row); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
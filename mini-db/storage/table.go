@@ -3,18 +3,35 @@ package storage
 import "fastabiz-mini-rdbms/mini-db/index"
 
 type Table struct {
-	Name       string
-	Columns    []Column
-	ColumnMap  map[string]Column
-	Rows       map[RowID]Row
-	NextRowID  int
-
+	Name      string
+	Columns   []Column
+	ColumnMap map[string]Column
+	Engine    EngineKind
+	Store     TableStore
+	File      string // heap file of a disk table, in the data directory
 
-	PrimaryKey string
-	PKIndex	 index.Index
+	PrimaryKey []string
+	PKIndex    index.Index
 	AutoInc    int
+
+	Indexes     []*SecondaryIndex
+	ForeignKeys []ForeignKey
+	Checks      []CheckConstraint
+	CheckExprs  []Predicate // Checks, parsed and bound when the table is built
+}
+
+// SecondaryIndex is an index created with CREATE INDEX on one or more
+// columns, or the index behind a UNIQUE column, which is marked as a
+// constraint: it belongs to the table's schema and is not dropped on its
+// own.
+type SecondaryIndex struct {
+	Name       string
+	Columns    []string
+	Unique     bool
+	Constraint bool
+	Index      index.Index
 }
 
-// map[RowID]Row = fast access
+// Store hides how rows are kept (map, heap file, ...)
 // Indexes mirrors real DB internal catalogs
 // AutoInc gives you PK generation cheaply
@@ -0,0 +1,194 @@
+package wal
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+)
+
+// Record layout on disk:
+//
+//	| length uint32 | crc32 uint32 | lsn uint64 | payload [length]byte |
+//
+// The checksum covers the lsn and the payload, so a record that was only
+// partially written before a crash is detected and dropped on Open.
+const (
+	headerSize    = 16
+	maxRecordSize = 64 << 20
+)
+
+type Log struct {
+	mu         sync.Mutex
+	file       *os.File
+	path       string
+	lastLSN    uint64
+	flushedLSN uint64
+}
+
+// Open opens (or creates) the log at path. A torn record at the tail of
+// the file is truncated away so new appends start on a clean boundary.
+func Open(path string) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Log{file: file, path: path}
+
+	end, err := l.scan(func(lsn uint64, _ []byte) error {
+		l.lastLSN = lsn
+		return nil
+	})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if err := file.Truncate(end); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(end, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	l.flushedLSN = l.lastLSN
+
+	return l, nil
+}
+
+// Append writes payload as a new record and fsyncs it before returning,
+// so a record is durable once Append succeeds.
+func (l *Log) Append(payload []byte) (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lsn := l.lastLSN + 1
+
+	buf := make([]byte, headerSize+len(payload))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint64(buf[8:16], lsn)
+	copy(buf[headerSize:], payload)
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(buf[8:]))
+
+	if _, err := l.file.Write(buf); err != nil {
+		return 0, err
+	}
+	l.lastLSN = lsn
+
+	if err := l.file.Sync(); err != nil {
+		return 0, err
+	}
+	l.flushedLSN = lsn
+	return lsn, nil
+}
+
+// FlushTo makes sure every record up to lsn is on stable storage. Append
+// already syncs, so this only has work to do if that sync failed.
+func (l *Log) FlushTo(lsn uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if lsn <= l.flushedLSN {
+		return nil
+	}
+	if err := l.file.Sync(); err != nil {
+		return err
+	}
+	l.flushedLSN = l.lastLSN
+	return nil
+}
+
+// Replay calls fn for every record in the log, oldest first. It runs
+// during startup, before anything is appended, and does not take the lock:
+// fn may apply records whose pages get flushed, which calls FlushTo.
+func (l *Log) Replay(fn func(lsn uint64, payload []byte) error) error {
+	_, err := l.scan(fn)
+	return err
+}
+
+func (l *Log) LastLSN() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lastLSN
+}
+
+// Truncate discards every record in the log. LSNs keep counting from
+// where they were, so records appended afterwards still sort after
+// anything a checkpoint has already captured.
+func (l *Log) Truncate() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// Advance moves the LSN counter forward to at least lsn. It is used after
+// a restart on a truncated log, where the file alone no longer tells how
+// far the LSNs had got.
+func (l *Log) Advance(lsn uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if lsn > l.lastLSN {
+		l.lastLSN = lsn
+		l.flushedLSN = lsn
+	}
+}
+
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// scan walks the file from the start and returns the offset just past the
+// last intact record. Reading stops quietly at the first torn record.
+func (l *Log) scan(fn func(lsn uint64, payload []byte) error) (int64, error) {
+	var offset int64
+	header := make([]byte, headerSize)
+
+	for {
+		if _, err := l.file.ReadAt(header, offset); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return offset, nil
+			}
+			return offset, err
+		}
+
+		length := binary.LittleEndian.Uint32(header[0:4])
+		sum := binary.LittleEndian.Uint32(header[4:8])
+		lsn := binary.LittleEndian.Uint64(header[8:16])
+
+		if length > maxRecordSize {
+			return offset, nil
+		}
+
+		body := make([]byte, 8+int(length))
+		copy(body, header[8:16])
+		if _, err := l.file.ReadAt(body[8:], offset+headerSize); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return offset, nil
+			}
+			return offset, err
+		}
+
+		if crc32.ChecksumIEEE(body) != sum {
+			return offset, nil
+		}
+
+		if err := fn(lsn, body[8:]); err != nil {
+			return offset, err
+		}
+
+		offset += headerSize + int64(length)
+	}
+}
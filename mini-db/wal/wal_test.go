@@ -0,0 +1,187 @@
+package wal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+type record struct {
+	lsn     uint64
+	payload string
+}
+
+func openLog(t *testing.T, path string) *Log {
+	t.Helper()
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func appendAll(t *testing.T, l *Log, payloads ...string) {
+	t.Helper()
+	for _, p := range payloads {
+		if _, err := l.Append([]byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func replayAll(t *testing.T, l *Log) []record {
+	t.Helper()
+	var got []record
+	err := l.Replay(func(lsn uint64, payload []byte) error {
+		got = append(got, record{lsn, string(payload)})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func checkReplay(t *testing.T, l *Log, want ...record) {
+	t.Helper()
+	if got := replayAll(t, l); !slices.Equal(got, want) {
+		t.Fatalf("replayed %v, want %v", got, want)
+	}
+}
+
+func TestAppendReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.log")
+	l := openLog(t, path)
+	appendAll(t, l, "one", "", "three")
+	checkReplay(t, l, record{1, "one"}, record{2, ""}, record{3, "three"})
+	l.Close()
+
+	l = openLog(t, path)
+	if lsn := l.LastLSN(); lsn != 3 {
+		t.Fatalf("LastLSN after reopen = %d, want 3", lsn)
+	}
+	appendAll(t, l, "four")
+	checkReplay(t, l, record{1, "one"}, record{2, ""}, record{3, "three"}, record{4, "four"})
+}
+
+func TestReplayError(t *testing.T) {
+	l := openLog(t, filepath.Join(t.TempDir(), "wal.log"))
+	appendAll(t, l, "a", "b", "c")
+
+	var seen int
+	err := l.Replay(func(lsn uint64, _ []byte) error {
+		seen++
+		if lsn == 2 {
+			return fmt.Errorf("bad record")
+		}
+		return nil
+	})
+	if err == nil || seen != 2 {
+		t.Fatalf("Replay stopped after %d records with %v", seen, err)
+	}
+}
+
+// TestTornTail damages the last record in the ways a crash can leave it,
+// and checks Open drops it so the next append starts on a clean boundary.
+func TestTornTail(t *testing.T) {
+	const last = int64(headerSize + len("second"))
+	tests := []struct {
+		name   string
+		damage func(t *testing.T, f *os.File, size int64)
+	}{
+		{"short header", func(t *testing.T, f *os.File, size int64) {
+			truncate(t, f, size-last+headerSize/2)
+		}},
+		{"short payload", func(t *testing.T, f *os.File, size int64) {
+			truncate(t, f, size-2)
+		}},
+		{"bad checksum", func(t *testing.T, f *os.File, size int64) {
+			flip(t, f, size-1)
+		}},
+		{"bad lsn", func(t *testing.T, f *os.File, size int64) {
+			flip(t, f, size-last+8)
+		}},
+		{"huge length", func(t *testing.T, f *os.File, size int64) {
+			if _, err := f.WriteAt([]byte{0xff, 0xff, 0xff, 0xff}, size-last); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "wal.log")
+			l := openLog(t, path)
+			appendAll(t, l, "first", "second")
+			l.Close()
+
+			f, err := os.OpenFile(path, os.O_RDWR, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			info, err := f.Stat()
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.damage(t, f, info.Size())
+			f.Close()
+
+			l = openLog(t, path)
+			checkReplay(t, l, record{1, "first"})
+			appendAll(t, l, "again")
+			l.Close()
+
+			l = openLog(t, path)
+			checkReplay(t, l, record{1, "first"}, record{2, "again"})
+		})
+	}
+}
+
+func truncate(t *testing.T, f *os.File, size int64) {
+	t.Helper()
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func flip(t *testing.T, f *os.File, off int64) {
+	t.Helper()
+	b := make([]byte, 1)
+	if _, err := f.ReadAt(b, off); err != nil {
+		t.Fatal(err)
+	}
+	b[0] ^= 0x01
+	if _, err := f.WriteAt(b, off); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTruncateKeepsCounting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.log")
+	l := openLog(t, path)
+	appendAll(t, l, "a", "b")
+	if err := l.Truncate(); err != nil {
+		t.Fatal(err)
+	}
+	checkReplay(t, l)
+	appendAll(t, l, "c")
+	checkReplay(t, l, record{3, "c"})
+
+	// An empty log forgets its LSNs; a restart moves the counter past
+	// the checkpoint it recovered from.
+	if err := l.Truncate(); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	l = openLog(t, path)
+	l.Advance(10)
+	l.Advance(5)
+	appendAll(t, l, "d")
+	checkReplay(t, l, record{11, "d"})
+	if err := l.FlushTo(11); err != nil {
+		t.Fatal(err)
+	}
+}